@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gossie/modelling-service/domain"
+)
+
+// apiTokenPrefix starts every API token, so that leaked tokens are easy to recognize.
+const apiTokenPrefix = "mmpat_"
+
+// useRecordingInterval limits how often the use of a token is written to the database.
+const useRecordingInterval = time.Minute
+
+// ApiTokens authenticates scripts with the personal API tokens of their users.
+type ApiTokens struct {
+	repository domain.ApiTokenRepository
+	sessions   *Sessions
+	now        func() time.Time
+}
+
+// NewApiTokens creates the authenticator. Requests without an Authorization header are authenticated by the sessions.
+func NewApiTokens(repository domain.ApiTokenRepository, sessions *Sessions) *ApiTokens {
+	return &ApiTokens{
+		repository: repository,
+		sessions:   sessions,
+		now:        time.Now,
+	}
+}
+
+// Create returns a new token of the user. The token is shown once, only its hash is stored.
+func (t *ApiTokens) Create(ctx context.Context, email, name string, scopes []string, lifetime time.Duration) (string, error) {
+	secret, hash, err := newRefreshSecret()
+	if err != nil {
+		return "", err
+	}
+	tokenId, err := t.repository.SaveApiToken(ctx, email, name, scopes, hash, t.now().Add(lifetime))
+	if err != nil {
+		return "", err
+	}
+	slog.InfoContext(ctx, fmt.Sprintf("created API token %v %q of %v with the scopes %v", tokenId, name, email, scopes))
+	return fmt.Sprintf("%v%v_%v", apiTokenPrefix, tokenId, secret), nil
+}
+
+// Authenticate returns the user of the bearer token of the request. GET requests need a token with the read scope,
+// all other requests the write scope.
+func (t *ApiTokens) Authenticate(w http.ResponseWriter, r *http.Request) (string, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return t.sessions.Authenticate(w, r)
+	}
+	scheme, value, _ := strings.Cut(header, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("%w: the authorization scheme %v is not supported", domain.ErrUnauthenticated, scheme)
+	}
+
+	token, err := t.find(r.Context(), strings.TrimSpace(value))
+	if err != nil {
+		return "", err
+	}
+
+	required := domain.ScopeWrite
+	if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+		required = domain.ScopeRead
+	}
+	if !slices.Contains(token.Scopes, required) {
+		slog.InfoContext(r.Context(), fmt.Sprintf("API token %v of %v lacks the scope %v", token.Id, token.UserEmail, required))
+		return "", fmt.Errorf("%w: the API token lacks the scope %v", domain.ErrForbidden, required)
+	}
+
+	now := t.now()
+	if now.Sub(token.LastUsedAt) >= useRecordingInterval {
+		err = t.repository.RecordApiTokenUse(r.Context(), token.Id, now, remoteHost(r))
+		if err != nil {
+			slog.WarnContext(r.Context(), fmt.Sprintf("could not record the use of API token %v: %v", token.Id, err.Error()))
+		}
+	}
+	return token.UserEmail, nil
+}
+
+// Revalidate returns the user of a request that was authenticated before, as long as its token or session is still
+// active. It neither renews sessions nor records the use of the token.
+func (t *ApiTokens) Revalidate(r *http.Request) (string, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return t.sessions.Revalidate(r)
+	}
+	_, value, _ := strings.Cut(header, " ")
+	token, err := t.find(r.Context(), strings.TrimSpace(value))
+	if err != nil {
+		return "", err
+	}
+	return token.UserEmail, nil
+}
+
+// find returns the active token, its secret has to match the stored hash.
+func (t *ApiTokens) find(ctx context.Context, value string) (domain.ApiToken, error) {
+	id, secret, found := strings.Cut(strings.TrimPrefix(value, apiTokenPrefix), "_")
+	tokenId, err := strconv.Atoi(id)
+	if !strings.HasPrefix(value, apiTokenPrefix) || !found || err != nil {
+		return domain.ApiToken{}, fmt.Errorf("%w: the API token is malformed", domain.ErrUnauthenticated)
+	}
+
+	token, err := t.repository.FindApiToken(ctx, tokenId)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.ApiToken{}, fmt.Errorf("%w: unknown API token %v", domain.ErrUnauthenticated, tokenId)
+	}
+	if err != nil {
+		return domain.ApiToken{}, err
+	}
+	switch {
+	case subtle.ConstantTimeCompare([]byte(hashOf(secret)), []byte(token.TokenHash)) != 1:
+		return domain.ApiToken{}, fmt.Errorf("%w: wrong secret for API token %v", domain.ErrUnauthenticated, tokenId)
+	case !token.RevokedAt.IsZero():
+		return domain.ApiToken{}, fmt.Errorf("%w: API token %v was revoked", domain.ErrUnauthenticated, tokenId)
+	case !t.now().Before(token.ExpiresAt):
+		return domain.ApiToken{}, fmt.Errorf("%w: API token %v expired", domain.ErrUnauthenticated, tokenId)
+	}
+	return token, nil
+}
+
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
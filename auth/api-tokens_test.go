@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gossie/modelling-service/domain"
+	"github.com/gossie/modelling-service/persistence"
+)
+
+func newTestApiTokens(t *testing.T) (*ApiTokens, *time.Time) {
+	t.Helper()
+	repos := persistence.NewMemoryRepositories(user)
+	sessions, now := newTestSessions(t)
+	sessions.repository = repos.Sessions
+	tokens := NewApiTokens(repos.ApiTokens, sessions)
+	tokens.now = func() time.Time { return *now }
+	return tokens, now
+}
+
+func createToken(t *testing.T, tokens *ApiTokens, scopes ...string) string {
+	t.Helper()
+	token, err := tokens.Create(context.Background(), user, "ci", scopes, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func send(tokens *ApiTokens, method, authorization string) (string, error) {
+	request := httptest.NewRequest(method, "/models/1/export", nil)
+	if authorization != "" {
+		request.Header.Set("Authorization", authorization)
+	}
+	return tokens.Authenticate(httptest.NewRecorder(), request)
+}
+
+func TestApiTokensNeedTheScopeOfTheRequest(t *testing.T) {
+	tokens, _ := newTestApiTokens(t)
+	reader := createToken(t, tokens, domain.ScopeRead)
+	writer := createToken(t, tokens, domain.ScopeRead, domain.ScopeWrite)
+	if !strings.HasPrefix(reader, apiTokenPrefix) {
+		t.Errorf("expected the token to start with %v, got %v", apiTokenPrefix, reader)
+	}
+
+	tests := []struct {
+		token    string
+		method   string
+		expected error
+	}{
+		{reader, http.MethodGet, nil},
+		{reader, http.MethodPost, domain.ErrForbidden},
+		{reader, http.MethodDelete, domain.ErrForbidden},
+		{writer, http.MethodGet, nil},
+		{writer, http.MethodPatch, nil},
+	}
+	for _, test := range tests {
+		email, err := send(tokens, test.method, "Bearer "+test.token)
+		if !errors.Is(err, test.expected) || (test.expected == nil && email != user) {
+			t.Errorf("expected %v for %v with %v, got %v, %v", test.expected, test.method, test.token, email, err)
+		}
+	}
+}
+
+func TestOnlyActiveApiTokensAreAccepted(t *testing.T) {
+	tokens, now := newTestApiTokens(t)
+	token := createToken(t, tokens, domain.ScopeRead)
+	revoked := createToken(t, tokens, domain.ScopeRead)
+	revokedId, _ := strconv.Atoi(strings.Split(strings.TrimPrefix(revoked, apiTokenPrefix), "_")[0])
+	err := tokens.repository.RevokeApiToken(context.Background(), user, revokedId, *now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, secret, _ := strings.Cut(strings.TrimPrefix(token, apiTokenPrefix), "_")
+
+	tests := []struct {
+		name          string
+		authorization string
+	}{
+		{"revoked", "Bearer " + revoked},
+		{"wrong secret", "Bearer " + token[:len(token)-4] + "AAAA"},
+		{"secret of another token", "Bearer " + apiTokenPrefix + strconv.Itoa(revokedId+1000) + "_" + secret},
+		{"malformed", "Bearer not-a-token"},
+		{"other scheme", "Basic " + token},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if email, err := send(tokens, http.MethodGet, test.authorization); !errors.Is(err, domain.ErrUnauthenticated) {
+				t.Errorf("expected ErrUnauthenticated, got %v, %v", email, err)
+			}
+		})
+	}
+
+	*now = now.Add(time.Hour)
+	if email, err := send(tokens, http.MethodGet, "Bearer "+token); !errors.Is(err, domain.ErrUnauthenticated) {
+		t.Errorf("expected the expired token to be rejected, got %v, %v", email, err)
+	}
+}
+
+func TestTheUseOfApiTokensIsRecorded(t *testing.T) {
+	tokens, now := newTestApiTokens(t)
+	token := createToken(t, tokens, domain.ScopeRead)
+
+	if _, err := send(tokens, http.MethodGet, "Bearer "+token); err != nil {
+		t.Fatal(err)
+	}
+	used, err := tokens.repository.FindApiTokensByUser(context.Background(), user)
+	if err != nil || len(used) != 1 {
+		t.Fatalf("expected one token, got %v, %v", used, err)
+	}
+	if !used[0].LastUsedAt.Equal(now.UTC()) || used[0].LastUsedFrom != "192.0.2.1" {
+		t.Errorf("expected the use from 192.0.2.1 at %v, got %v", *now, used[0])
+	}
+	if used[0].TokenHash == "" || strings.Contains(token, used[0].TokenHash) {
+		t.Errorf("expected only the hash of the token to be stored, got %v", used[0].TokenHash)
+	}
+}
+
+func TestRequestsWithoutApiTokenUseTheSession(t *testing.T) {
+	tokens, _ := newTestApiTokens(t)
+	cookies := login(t, tokens.sessions)
+
+	request := httptest.NewRequest(http.MethodPost, "/models", nil)
+	request.AddCookie(cookies[AccessTokenCookie])
+	email, err := tokens.Authenticate(httptest.NewRecorder(), request)
+	if err != nil || email != user {
+		t.Errorf("expected the user of the session, got %v, %v", email, err)
+	}
+}
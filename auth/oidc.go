@@ -0,0 +1,396 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gossie/modelling-service/config"
+	"github.com/gossie/modelling-service/domain"
+)
+
+// loginCookie keeps the state, the nonce and the PKCE code verifier of a login while the user is at the provider.
+const loginCookie = "oidcLogin"
+
+const (
+	loginLifetime = 10 * time.Minute
+	// keysRefreshInterval limits how often an unknown key ID makes the provider fetch the keys of the identity provider
+	keysRefreshInterval = time.Minute
+)
+
+// discovery is the part of the OpenID configuration that the login needs.
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksUri               string `json:"jwks_uri"`
+}
+
+type idClaims struct {
+	jwt.RegisteredClaims
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// Provider logs users in through an OpenID Connect identity provider with the authorization code flow and PKCE. Users
+// are created on their first login, the groups of the ID token give them access to models.
+type Provider struct {
+	cfg           config.Oidc
+	client        *http.Client
+	users         domain.UserRepository
+	models        domain.ModelRepository
+	sessions      *Sessions
+	modelsByGroup map[string][]int
+	now           func() time.Time
+
+	mu            sync.Mutex
+	discovery     *discovery
+	keys          map[string]*rsa.PublicKey
+	keysFetchedAt time.Time
+}
+
+// NewProvider creates the provider, the identity provider is contacted on the first login. The models should be the
+// repository that the permissions are cached with, so that the new users see their models right away.
+func NewProvider(cfg config.Oidc, client *http.Client, users domain.UserRepository, models domain.ModelRepository, sessions *Sessions) *Provider {
+	return &Provider{
+		cfg:           cfg,
+		client:        client,
+		users:         users,
+		models:        models,
+		sessions:      sessions,
+		modelsByGroup: cfg.ModelsByGroup(),
+		now:           time.Now,
+	}
+}
+
+// StartLogin returns the URL of the identity provider that the browser is sent to. The state, the nonce and the code
+// verifier are kept in a cookie until the user comes back.
+func (p *Provider) StartLogin(ctx context.Context, w http.ResponseWriter) (string, error) {
+	d, err := p.loadDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := randomString()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := randomString()
+	if err != nil {
+		return "", err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     loginCookie,
+		Value:    strings.Join([]string{state, nonce, verifier}, "."),
+		Path:     "/oidc",
+		MaxAge:   int(loginLifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   p.sessions.cfg.SecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientId},
+		"redirect_uri":          {p.cfg.RedirectUrl},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return d.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// FinishLogin handles the callback of the identity provider. It exchanges the code for an ID token, provisions the
+// user and starts a session. It returns domain.ErrUnauthenticated if the login was refused or tampered with.
+func (p *Provider) FinishLogin(w http.ResponseWriter, r *http.Request) (string, error) {
+	http.SetCookie(w, &http.Cookie{Name: loginCookie, Path: "/oidc", MaxAge: -1, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+
+	cookie, err := r.Cookie(loginCookie)
+	if err != nil {
+		return "", fmt.Errorf("%w: the login was not started or took too long", domain.ErrUnauthenticated)
+	}
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("%w: the login cookie is malformed", domain.ErrUnauthenticated)
+	}
+	state, nonce, verifier := parts[0], parts[1], parts[2]
+
+	query := r.URL.Query()
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state)) != 1 {
+		return "", fmt.Errorf("%w: the state does not match", domain.ErrUnauthenticated)
+	}
+	if refusal := query.Get("error"); refusal != "" {
+		return "", fmt.Errorf("%w: the identity provider refused the login with %v %v", domain.ErrUnauthenticated, refusal, query.Get("error_description"))
+	}
+
+	idToken, err := p.exchange(r.Context(), query.Get("code"), verifier)
+	if err != nil {
+		return "", err
+	}
+	claims, err := p.verify(r.Context(), idToken, nonce)
+	if err != nil {
+		return "", err
+	}
+
+	email := claims.Email
+	if _, err = p.users.SaveUser(r.Context(), email); err != nil {
+		return "", fmt.Errorf("could not provision %v: %w", email, err)
+	}
+	if err = p.syncModels(r.Context(), email, groupsOf(idToken, p.cfg.GroupsClaim)); err != nil {
+		return "", err
+	}
+	return email, p.sessions.Login(r.Context(), w, email)
+}
+
+// syncModels gives the user access to the models of the groups and takes back the models of groups the user left.
+func (p *Provider) syncModels(ctx context.Context, email string, groups []string) error {
+	modelIds := make([]int, 0)
+	for _, group := range groups {
+		for _, modelId := range p.modelsByGroup[group] {
+			if !slices.Contains(modelIds, modelId) {
+				modelIds = append(modelIds, modelId)
+			}
+		}
+	}
+	changed, err := p.models.SyncGroupGrants(ctx, email, modelIds)
+	if err != nil {
+		return fmt.Errorf("could not update the models of %v: %w", email, err)
+	}
+	if len(changed) > 0 {
+		slog.InfoContext(ctx, fmt.Sprintf("the groups %v changed the access of %v to the models %v", groups, email, changed))
+	}
+	return nil
+}
+
+func (p *Provider) exchange(ctx context.Context, code, verifier string) (string, error) {
+	if code == "" {
+		return "", fmt.Errorf("%w: the callback has no code", domain.ErrUnauthenticated)
+	}
+	d, err := p.loadDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectUrl},
+		"code_verifier": {verifier},
+	}
+	if p.cfg.ClientSecret == "" {
+		form.Set("client_id", p.cfg.ClientId)
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		request.SetBasicAuth(url.QueryEscape(p.cfg.ClientId), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	response, err := p.client.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("could not exchange the code: %w", err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("could not read the tokens: %w", err)
+	}
+	if response.StatusCode == http.StatusBadRequest || response.StatusCode == http.StatusUnauthorized {
+		return "", fmt.Errorf("%w: the identity provider rejected the code with %v: %s", domain.ErrUnauthenticated, response.StatusCode, body)
+	}
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("could not exchange the code, the identity provider answered %v", response.StatusCode)
+	}
+
+	var tokens struct {
+		IdToken string `json:"id_token"`
+	}
+	if err = json.Unmarshal(body, &tokens); err != nil || tokens.IdToken == "" {
+		return "", fmt.Errorf("the identity provider sent no ID token: %v", err)
+	}
+	return tokens.IdToken, nil
+}
+
+// verify checks the signature, the audience, the nonce and the verified email address of the ID token.
+func (p *Provider) verify(ctx context.Context, idToken, nonce string) (*idClaims, error) {
+	d, err := p.loadDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &idClaims{}
+	_, err = jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		keyId, _ := token.Header["kid"].(string)
+		return p.key(ctx, keyId)
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(d.Issuer),
+		jwt.WithAudience(p.cfg.ClientId),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithTimeFunc(p.now),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: the ID token is not valid: %v", domain.ErrUnauthenticated, err)
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("%w: the nonce of the ID token does not match", domain.ErrUnauthenticated)
+	}
+	// an ID token without the claim does not vouch for the address, anyone could have entered it at the provider
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, fmt.Errorf("%w: the ID token has no verified email address", domain.ErrUnauthenticated)
+	}
+	return claims, nil
+}
+
+// groupsOf reads the groups from the already verified ID token, the claim is a list of strings or a single string.
+func groupsOf(idToken, claim string) []string {
+	if claim == "" {
+		return nil
+	}
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(idToken, claims); err != nil {
+		return nil
+	}
+	switch groups := claims[claim].(type) {
+	case string:
+		return []string{groups}
+	case []any:
+		result := make([]string, 0, len(groups))
+		for _, group := range groups {
+			if name, ok := group.(string); ok {
+				result = append(result, name)
+			}
+		}
+		return result
+	default:
+		return nil
+	}
+}
+
+func (p *Provider) loadDiscovery(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+	d := &discovery{}
+	if err := p.getJson(ctx, p.cfg.DiscoveryUrl, d); err != nil {
+		return nil, fmt.Errorf("could not load the OpenID configuration: %w", err)
+	}
+	if d.Issuer == "" || d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JwksUri == "" {
+		return nil, errors.New("the OpenID configuration lacks the issuer or an endpoint")
+	}
+	p.discovery = d
+	return d, nil
+}
+
+// key returns the public key with the ID and fetches the keys again if the provider rotated them.
+func (p *Provider) key(ctx context.Context, keyId string) (*rsa.PublicKey, error) {
+	d, err := p.loadDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[keyId]; ok {
+		return key, nil
+	}
+	if p.keys != nil && p.now().Sub(p.keysFetchedAt) < keysRefreshInterval {
+		return nil, fmt.Errorf("unknown key ID %q", keyId)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err = p.getJson(ctx, d.JwksUri, &set); err != nil {
+		return nil, fmt.Errorf("could not load the keys of the identity provider: %w", err)
+	}
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil || len(e) > 4 {
+			slog.WarnContext(ctx, fmt.Sprintf("skipping the malformed key %v of the identity provider", k.Kid))
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	p.keys = keys
+	p.keysFetchedAt = p.now()
+
+	key, ok := keys[keyId]
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %q", keyId)
+	}
+	return key, nil
+}
+
+func (p *Provider) getJson(ctx context.Context, url string, target any) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+	response, err := p.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%v answered %v", url, response.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(target)
+}
+
+func randomString() (string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(random), nil
+}
@@ -0,0 +1,318 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gossie/modelling-service/config"
+	"github.com/gossie/modelling-service/domain"
+	"github.com/gossie/modelling-service/middleware"
+	"github.com/gossie/modelling-service/persistence"
+)
+
+const (
+	clientId     = "model-maker"
+	clientSecret = "client-secret"
+	redirectUrl  = "https://models.example.com/oidc/callback"
+	employee     = "employee@example.com"
+)
+
+// mockIdp is an identity provider that issues ID tokens for every authorization request. The fields change the
+// tokens, so that tests can tamper with them.
+type mockIdp struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu            sync.Mutex
+	requests      map[string]url.Values
+	email         string
+	emailVerified bool
+	groups        []string
+	signingKey    *rsa.PrivateKey
+	claims        func(jwt.MapClaims)
+}
+
+func newMockIdp(t *testing.T) *mockIdp {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &mockIdp{key: key, requests: make(map[string]url.Values), email: employee, emailVerified: true}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"kid": "idp-key",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("GET /authorize", idp.authorize)
+	mux.HandleFunc("POST /token", idp.token)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *mockIdp) authorize(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+
+	query := r.URL.Query()
+	code, _ := randomString()
+	idp.requests[code] = query
+	http.Redirect(w, r, query.Get("redirect_uri")+"?"+url.Values{"code": {code}, "state": {query.Get("state")}}.Encode(), http.StatusFound)
+}
+
+func (idp *mockIdp) token(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+
+	id, secret, _ := r.BasicAuth()
+	authorization, ok := idp.requests[r.FormValue("code")]
+	delete(idp.requests, r.FormValue("code"))
+	challenge := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+	switch {
+	case id != clientId || secret != clientSecret:
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	case !ok || r.FormValue("grant_type") != "authorization_code" || r.FormValue("redirect_uri") != authorization.Get("redirect_uri"):
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	case authorization.Get("code_challenge_method") != "S256" || base64.RawURLEncoding.EncodeToString(challenge[:]) != authorization.Get("code_challenge"):
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss":            idp.server.URL,
+		"aud":            authorization.Get("client_id"),
+		"sub":            "employee-1",
+		"email":          idp.email,
+		"email_verified": idp.emailVerified,
+		"groups":         idp.groups,
+		"nonce":          authorization.Get("nonce"),
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Minute).Unix(),
+	}
+	if idp.claims != nil {
+		idp.claims(claims)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "idp-key"
+	signingKey := idp.key
+	if idp.signingKey != nil {
+		signingKey = idp.signingKey
+	}
+	signed, _ := token.SignedString(signingKey)
+	_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "opaque", "token_type": "Bearer", "id_token": signed})
+}
+
+type oidcTest struct {
+	provider *Provider
+	repos    domain.Repositories
+	// models caches the permissions like the server does
+	models  *middleware.PermissionCache
+	modelId int
+}
+
+func newOidcTest(t *testing.T, idp *mockIdp) oidcTest {
+	t.Helper()
+	repos := persistence.NewMemoryRepositories(user)
+	modelId, err := repos.Models.SaveModel(context.Background(), user, domain.ModelCreationRequest{Name: "car"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwtConfig := config.Default().Jwt
+	jwtConfig.Secret = "current-secret"
+	cfg := config.Default().Oidc
+	cfg.DiscoveryUrl = idp.server.URL + "/.well-known/openid-configuration"
+	cfg.ClientId = clientId
+	cfg.ClientSecret = clientSecret
+	cfg.RedirectUrl = redirectUrl
+	cfg.GroupModels = []string{"designers=" + strconv.Itoa(modelId), "designers=999"}
+
+	sessions := NewSessions(jwtConfig, repos.Sessions)
+	models := middleware.NewPermissionCache(repos.Models, time.Minute)
+	return oidcTest{NewProvider(cfg, idp.server.Client(), repos.Users, models, sessions), repos, models, modelId}
+}
+
+// login goes through the authorization code flow like a browser, change may tamper with the callback.
+func (test oidcTest) login(t *testing.T, change func(callback *http.Request)) (string, *httptest.ResponseRecorder, error) {
+	t.Helper()
+	start := httptest.NewRecorder()
+	location, err := test.provider.StartLogin(context.Background(), start)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := *test.provider.client
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	response, err := client.Get(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	callback := httptest.NewRequest(http.MethodGet, response.Header.Get("Location"), nil)
+	for _, cookie := range start.Result().Cookies() {
+		callback.AddCookie(cookie)
+	}
+	if change != nil {
+		change(callback)
+	}
+	recorder := httptest.NewRecorder()
+	email, err := test.provider.FinishLogin(recorder, callback)
+	return email, recorder, err
+}
+
+func TestOidcLoginProvisionsTheUserAndGrantsTheModelsOfTheGroups(t *testing.T) {
+	idp := newMockIdp(t)
+	idp.groups = []string{"designers", "accounting"}
+	test := newOidcTest(t, idp)
+
+	email, recorder, err := test.login(t, nil)
+	if err != nil || email != employee {
+		t.Fatalf("expected %v to be logged in, got %v, %v", employee, email, err)
+	}
+
+	if _, err = test.repos.Users.FindByEmail(context.Background(), employee); err != nil {
+		t.Errorf("expected %v to be provisioned, got %v", employee, err)
+	}
+	if hasAccess, err := test.repos.Models.HasAccess(context.Background(), test.modelId, employee); err != nil || !hasAccess {
+		t.Errorf("expected the designers to get access to the model, got %v, %v", hasAccess, err)
+	}
+	cookies := cookiesOf(recorder)
+	if cookies[AccessTokenCookie] == nil || cookies[RefreshTokenCookie] == nil {
+		t.Errorf("expected a session, got the cookies %v", cookies)
+	}
+	if cookie := cookies[loginCookie]; cookie == nil || cookie.MaxAge >= 0 {
+		t.Errorf("expected the login cookie to be removed, got %v", cookie)
+	}
+
+	// the second login finds the user
+	if email, _, err = test.login(t, nil); err != nil || email != employee {
+		t.Errorf("expected %v to be logged in again, got %v, %v", employee, email, err)
+	}
+}
+
+func TestOidcLoginTakesBackTheModelsOfLeftGroups(t *testing.T) {
+	idp := newMockIdp(t)
+	idp.groups = []string{"designers"}
+	test := newOidcTest(t, idp)
+
+	expectAccess := func(email string, expected bool) {
+		t.Helper()
+		if hasAccess, err := test.models.HasAccess(context.Background(), test.modelId, email); err != nil || hasAccess != expected {
+			t.Errorf("expected the access of %v to be %v, got %v, %v", email, expected, hasAccess, err)
+		}
+	}
+
+	if _, _, err := test.login(t, nil); err != nil {
+		t.Fatal(err)
+	}
+	expectAccess(employee, true)
+
+	idp.groups = []string{"accounting"}
+	if _, _, err := test.login(t, nil); err != nil {
+		t.Fatal(err)
+	}
+	expectAccess(employee, false)
+	expectAccess(user, true)
+
+	idp.groups = []string{"designers"}
+	if _, _, err := test.login(t, nil); err != nil {
+		t.Fatal(err)
+	}
+	expectAccess(employee, true)
+}
+
+func TestOidcLoginRejectsForgedResponses(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		idp      func(*mockIdp)
+		callback func(*http.Request)
+	}{
+		{name: "other state", callback: func(r *http.Request) {
+			query := r.URL.Query()
+			query.Set("state", "forged")
+			r.URL.RawQuery = query.Encode()
+		}},
+		{name: "no login cookie", callback: func(r *http.Request) { r.Header.Del("Cookie") }},
+		{name: "other code verifier", callback: func(r *http.Request) {
+			cookie, _ := r.Cookie(loginCookie)
+			parts := strings.Split(cookie.Value, ".")
+			r.Header.Del("Cookie")
+			r.AddCookie(&http.Cookie{Name: loginCookie, Value: parts[0] + "." + parts[1] + ".forged"})
+		}},
+		{name: "refused by the identity provider", callback: func(r *http.Request) {
+			query := r.URL.Query()
+			query.Del("code")
+			query.Set("error", "access_denied")
+			r.URL.RawQuery = query.Encode()
+		}},
+		{name: "other signing key", idp: func(idp *mockIdp) { idp.signingKey = otherKey }},
+		{name: "other nonce", idp: func(idp *mockIdp) { idp.claims = func(c jwt.MapClaims) { c["nonce"] = "forged" } }},
+		{name: "other audience", idp: func(idp *mockIdp) { idp.claims = func(c jwt.MapClaims) { c["aud"] = "another-client" } }},
+		{name: "other issuer", idp: func(idp *mockIdp) { idp.claims = func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" } }},
+		{name: "expired", idp: func(idp *mockIdp) {
+			idp.claims = func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }
+		}},
+		{name: "unverified email", idp: func(idp *mockIdp) { idp.emailVerified = false }},
+		{name: "email not known to be verified", idp: func(idp *mockIdp) {
+			idp.claims = func(c jwt.MapClaims) { delete(c, "email_verified") }
+		}},
+		{name: "email verified as text", idp: func(idp *mockIdp) { idp.claims = func(c jwt.MapClaims) { c["email_verified"] = "true" } }},
+		{name: "no email", idp: func(idp *mockIdp) { idp.email = "" }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			idp := newMockIdp(t)
+			idp.groups = []string{"designers"}
+			if test.idp != nil {
+				test.idp(idp)
+			}
+			oidc := newOidcTest(t, idp)
+
+			email, _, err := oidc.login(t, test.callback)
+			if !errors.Is(err, domain.ErrUnauthenticated) {
+				t.Errorf("expected ErrUnauthenticated, got %v, %v", email, err)
+			}
+			if _, err = oidc.repos.Users.FindByEmail(context.Background(), employee); !errors.Is(err, domain.ErrNotFound) {
+				t.Errorf("expected no user to be provisioned, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,331 @@
+// Package auth signs users in and out of the browser. A login starts a session, the browser gets a short-lived access
+// token and a refresh token as cookies. The access token is a JWT that names its session, every request looks the
+// session up, so that a logout or a revoked session ends the access right away. The refresh token is opaque and
+// replaced whenever it is used, a replaced refresh token that is used again revokes the whole session, because it was
+// probably stolen.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gossie/modelling-service/config"
+	"github.com/gossie/modelling-service/domain"
+)
+
+const (
+	AccessTokenCookie  = "accessToken"
+	RefreshTokenCookie = "refreshToken"
+)
+
+// refreshGracePeriod is how long a replaced refresh token is still accepted for requests that were sent with it.
+const refreshGracePeriod = 30 * time.Second
+
+var signingMethod = jwt.SigningMethodHS256
+
+type accessClaims struct {
+	jwt.RegisteredClaims
+	SessionId int `json:"sid"`
+}
+
+// Sessions issues and checks the tokens of the sessions.
+type Sessions struct {
+	cfg        config.Jwt
+	keys       map[string]string
+	repository domain.SessionRepository
+	now        func() time.Time
+}
+
+func NewSessions(cfg config.Jwt, repository domain.SessionRepository) *Sessions {
+	return &Sessions{
+		cfg:        cfg,
+		keys:       cfg.Keys(),
+		repository: repository,
+		now:        time.Now,
+	}
+}
+
+// Login starts a session of the user and sets its cookies. It returns domain.ErrNotFound if there is no such user.
+func (s *Sessions) Login(ctx context.Context, w http.ResponseWriter, email string) error {
+	secret, hash, err := newRefreshSecret()
+	if err != nil {
+		return err
+	}
+	expiresAt := s.now().Add(s.cfg.RefreshTokenLifetime)
+	sessionId, err := s.repository.SaveSession(ctx, email, hash, expiresAt)
+	if err != nil {
+		return err
+	}
+	if err = s.setAccessToken(w, email, sessionId); err != nil {
+		return err
+	}
+	s.setCookie(w, RefreshTokenCookie, refreshToken(sessionId, secret), expiresAt)
+
+	deleted, err := s.repository.DeleteExpiredSessions(ctx, s.now())
+	if err != nil {
+		slog.WarnContext(ctx, fmt.Sprintf("could not delete expired sessions: %v", err.Error()))
+	} else if deleted > 0 {
+		slog.DebugContext(ctx, fmt.Sprintf("deleted %v expired sessions", deleted))
+	}
+	return nil
+}
+
+// Authenticate returns the user of the request. If the access token is missing or expired, the refresh token is used
+// and both tokens are renewed on the response.
+func (s *Sessions) Authenticate(w http.ResponseWriter, r *http.Request) (string, error) {
+	if cookie, err := r.Cookie(AccessTokenCookie); err == nil {
+		claims, err := s.verify(cookie.Value)
+		if err == nil {
+			err = s.checkSession(r.Context(), claims)
+		}
+		if err == nil {
+			return claims.Subject, nil
+		}
+		slog.DebugContext(r.Context(), fmt.Sprintf("access token is not valid: %v", err.Error()))
+	}
+
+	cookie, err := r.Cookie(RefreshTokenCookie)
+	if err != nil {
+		return "", domain.ErrUnauthenticated
+	}
+	email, err := s.refresh(r.Context(), w, cookie.Value)
+	if errors.Is(err, domain.ErrUnauthenticated) {
+		clearCookies(w)
+	}
+	return email, err
+}
+
+// Revalidate returns the user of a request that was authenticated before, as long as its session is active. Unlike
+// Authenticate it never renews the tokens, so that long-running requests like event streams, whose cookies cannot be
+// changed anymore, can check their session again. The access token may have expired in the meantime, its signature
+// still proves the session.
+func (s *Sessions) Revalidate(r *http.Request) (string, error) {
+	if cookie, err := r.Cookie(AccessTokenCookie); err == nil {
+		if claims, err := s.verify(cookie.Value, jwt.WithoutClaimsValidation()); err == nil {
+			return claims.Subject, s.checkSession(r.Context(), claims)
+		}
+	}
+
+	cookie, err := r.Cookie(RefreshTokenCookie)
+	if err != nil {
+		return "", domain.ErrUnauthenticated
+	}
+	id, secret, _ := strings.Cut(cookie.Value, ".")
+	sessionId, err := strconv.Atoi(id)
+	if err != nil {
+		return "", domain.ErrUnauthenticated
+	}
+	session, err := s.repository.FindSession(r.Context(), sessionId)
+	if errors.Is(err, domain.ErrNotFound) {
+		return "", domain.ErrUnauthenticated
+	}
+	if err != nil {
+		return "", err
+	}
+	// the refresh token of the request may have been replaced by other requests of the browser since
+	hash := hashOf(secret)
+	if !s.isActive(session) || (hash != session.RefreshTokenHash && hash != session.PreviousRefreshTokenHash) {
+		return "", domain.ErrUnauthenticated
+	}
+	return session.UserEmail, nil
+}
+
+// Logout revokes the session of the request and removes its cookies. Requests without a session are logged out, too.
+func (s *Sessions) Logout(w http.ResponseWriter, r *http.Request) error {
+	defer clearCookies(w)
+
+	sessionId := -1
+	if cookie, err := r.Cookie(AccessTokenCookie); err == nil {
+		if claims, err := s.verify(cookie.Value); err == nil {
+			sessionId = claims.SessionId
+		}
+	}
+	if cookie, err := r.Cookie(RefreshTokenCookie); err == nil && sessionId < 0 {
+		if session, err := s.findByRefreshToken(r.Context(), cookie.Value); err == nil {
+			sessionId = session.Id
+		}
+	}
+	if sessionId < 0 {
+		return nil
+	}
+	return s.repository.RevokeSession(r.Context(), sessionId, s.now())
+}
+
+func (s *Sessions) refresh(ctx context.Context, w http.ResponseWriter, token string) (string, error) {
+	session, err := s.findByRefreshToken(ctx, token)
+	if err != nil {
+		return "", err
+	}
+	_, secret, _ := strings.Cut(token, ".")
+	if hashOf(secret) != session.RefreshTokenHash {
+		// the token was replaced in the grace period, the new refresh token is on its way to the browser
+		return session.UserEmail, s.setAccessToken(w, session.UserEmail, session.Id)
+	}
+
+	newSecret, newHash, err := newRefreshSecret()
+	if err != nil {
+		return "", err
+	}
+	now := s.now()
+	expiresAt := now.Add(s.cfg.RefreshTokenLifetime)
+	err = s.repository.RotateRefreshToken(ctx, session.Id, session.RefreshTokenHash, newHash, now, expiresAt)
+	if errors.Is(err, domain.ErrConflict) {
+		// another request was faster
+		return session.UserEmail, s.setAccessToken(w, session.UserEmail, session.Id)
+	}
+	if err != nil {
+		return "", err
+	}
+	if err = s.setAccessToken(w, session.UserEmail, session.Id); err != nil {
+		return "", err
+	}
+	s.setCookie(w, RefreshTokenCookie, refreshToken(session.Id, newSecret), expiresAt)
+	return session.UserEmail, nil
+}
+
+// findByRefreshToken returns the active session of the refresh token, an older replaced token revokes the session.
+func (s *Sessions) findByRefreshToken(ctx context.Context, token string) (domain.Session, error) {
+	id, secret, found := strings.Cut(token, ".")
+	sessionId, err := strconv.Atoi(id)
+	if !found || err != nil {
+		return domain.Session{}, domain.ErrUnauthenticated
+	}
+	session, err := s.repository.FindSession(ctx, sessionId)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.Session{}, domain.ErrUnauthenticated
+	}
+	if err != nil {
+		return domain.Session{}, err
+	}
+	if !s.isActive(session) {
+		return domain.Session{}, domain.ErrUnauthenticated
+	}
+
+	hash := hashOf(secret)
+	switch {
+	case hash == session.RefreshTokenHash:
+		return session, nil
+	case hash == session.PreviousRefreshTokenHash && s.now().Sub(session.RotatedAt) < refreshGracePeriod:
+		return session, nil
+	default:
+		slog.WarnContext(ctx, fmt.Sprintf("revoking session %v of %v, a replaced refresh token was used", session.Id, session.UserEmail))
+		if err := s.repository.RevokeSession(ctx, session.Id, s.now()); err != nil {
+			return domain.Session{}, err
+		}
+		return domain.Session{}, domain.ErrUnauthenticated
+	}
+}
+
+func (s *Sessions) checkSession(ctx context.Context, claims *accessClaims) error {
+	session, err := s.repository.FindSession(ctx, claims.SessionId)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.ErrUnauthenticated
+	}
+	if err != nil {
+		return err
+	}
+	if !s.isActive(session) || session.UserEmail != claims.Subject {
+		return domain.ErrUnauthenticated
+	}
+	return nil
+}
+
+func (s *Sessions) isActive(session domain.Session) bool {
+	return session.RevokedAt.IsZero() && s.now().Before(session.ExpiresAt)
+}
+
+func (s *Sessions) setAccessToken(w http.ResponseWriter, email string, sessionId int) error {
+	now := s.now()
+	expiresAt := now.Add(s.cfg.TokenLifetime)
+	token := jwt.NewWithClaims(signingMethod, accessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   email,
+			Issuer:    s.cfg.Issuer,
+			Audience:  jwt.ClaimStrings{s.cfg.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+		SessionId: sessionId,
+	})
+	token.Header["kid"] = s.cfg.KeyId
+
+	signed, err := token.SignedString([]byte(s.cfg.Secret))
+	if err != nil {
+		return fmt.Errorf("could not sign the access token: %w", err)
+	}
+	s.setCookie(w, AccessTokenCookie, signed, expiresAt)
+	return nil
+}
+
+// verify checks the HS256 signature and the claims of the access token, its key ID selects the signing key.
+func (s *Sessions) verify(token string, options ...jwt.ParserOption) (*accessClaims, error) {
+	claims := &accessClaims{}
+	options = append([]jwt.ParserOption{
+		jwt.WithValidMethods([]string{signingMethod.Alg()}),
+		jwt.WithIssuer(s.cfg.Issuer),
+		jwt.WithAudience(s.cfg.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithTimeFunc(s.now),
+	}, options...)
+	_, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+		keyId, _ := token.Header["kid"].(string)
+		secret, ok := s.keys[keyId]
+		if !ok {
+			return nil, fmt.Errorf("unknown key ID %q", keyId)
+		}
+		return []byte(secret), nil
+	}, options...)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (s *Sessions) setCookie(w http.ResponseWriter, name, value string, expiresAt time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   s.cfg.SecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func clearCookies(w http.ResponseWriter) {
+	for _, name := range []string{AccessTokenCookie, RefreshTokenCookie} {
+		http.SetCookie(w, &http.Cookie{Name: name, Path: "/", MaxAge: -1, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+	}
+}
+
+// refreshToken is "<session ID>.<secret>", only the hash of the secret is stored.
+func refreshToken(sessionId int, secret string) string {
+	return fmt.Sprintf("%v.%v", sessionId, secret)
+}
+
+func newRefreshSecret() (string, string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", fmt.Errorf("could not create a refresh token: %w", err)
+	}
+	secret := base64.RawURLEncoding.EncodeToString(random)
+	return secret, hashOf(secret), nil
+}
+
+func hashOf(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
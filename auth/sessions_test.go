@@ -0,0 +1,248 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gossie/modelling-service/config"
+	"github.com/gossie/modelling-service/domain"
+	"github.com/gossie/modelling-service/persistence"
+)
+
+const user = "user@example.com"
+
+func newTestSessions(t *testing.T) (*Sessions, *time.Time) {
+	t.Helper()
+	cfg := config.Default().Jwt
+	cfg.Secret = "current-secret"
+	cfg.KeyId = "2"
+	cfg.RetiredKeys = []string{"1=retired-secret"}
+
+	now := time.Now()
+	s := NewSessions(cfg, persistence.NewMemoryRepositories(user).Sessions)
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+// login returns the cookies of a new session of the user.
+func login(t *testing.T, s *Sessions) map[string]*http.Cookie {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	if err := s.Login(context.Background(), recorder, user); err != nil {
+		t.Fatal(err)
+	}
+	return cookiesOf(recorder)
+}
+
+func cookiesOf(recorder *httptest.ResponseRecorder) map[string]*http.Cookie {
+	cookies := make(map[string]*http.Cookie)
+	for _, cookie := range recorder.Result().Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+	return cookies
+}
+
+func authenticate(s *Sessions, cookies ...*http.Cookie) (string, *httptest.ResponseRecorder, error) {
+	request := httptest.NewRequest(http.MethodGet, "/models", nil)
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	recorder := httptest.NewRecorder()
+	email, err := s.Authenticate(recorder, request)
+	return email, recorder, err
+}
+
+func TestLoginSetsProtectedCookies(t *testing.T) {
+	s, _ := newTestSessions(t)
+
+	cookies := login(t, s)
+
+	for _, name := range []string{AccessTokenCookie, RefreshTokenCookie} {
+		cookie, ok := cookies[name]
+		if !ok {
+			t.Fatalf("expected the cookie %v", name)
+		}
+		if !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode || cookie.Path != "/" {
+			t.Errorf("expected %v to be HttpOnly, Secure and SameSite=Lax, got %v", name, cookie)
+		}
+	}
+	email, _, err := authenticate(s, cookies[AccessTokenCookie])
+	if err != nil || email != user {
+		t.Errorf("expected %v, got %v, %v", user, email, err)
+	}
+}
+
+func TestLoginOfAnUnknownUserFails(t *testing.T) {
+	s, _ := newTestSessions(t)
+
+	err := s.Login(context.Background(), httptest.NewRecorder(), "nobody@example.com")
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestOnlyTokensOfTheServerAreAccepted(t *testing.T) {
+	s, now := newTestSessions(t)
+	access := login(t, s)[AccessTokenCookie]
+	claims, err := s.verify(access.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(method jwt.SigningMethod, keyId string, key any, change func(*accessClaims)) string {
+		c := *claims
+		if change != nil {
+			change(&c)
+		}
+		token := jwt.NewWithClaims(method, c)
+		token.Header["kid"] = keyId
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"current key", sign(jwt.SigningMethodHS256, "2", []byte("current-secret"), nil), true},
+		{"retired key", sign(jwt.SigningMethodHS256, "1", []byte("retired-secret"), nil), true},
+		{"unknown key ID", sign(jwt.SigningMethodHS256, "3", []byte("current-secret"), nil), false},
+		{"key of another ID", sign(jwt.SigningMethodHS256, "1", []byte("current-secret"), nil), false},
+		{"other HMAC algorithm", sign(jwt.SigningMethodHS512, "2", []byte("current-secret"), nil), false},
+		{"no signature", sign(jwt.SigningMethodNone, "2", jwt.UnsafeAllowNoneSignatureType, nil), false},
+		{"other issuer", sign(jwt.SigningMethodHS256, "2", []byte("current-secret"), func(c *accessClaims) { c.Issuer = "someone-else" }), false},
+		{"other audience", sign(jwt.SigningMethodHS256, "2", []byte("current-secret"), func(c *accessClaims) { c.Audience = jwt.ClaimStrings{"another-service"} }), false},
+		{"no expiration", sign(jwt.SigningMethodHS256, "2", []byte("current-secret"), func(c *accessClaims) { c.ExpiresAt = nil }), false},
+		{"expired", sign(jwt.SigningMethodHS256, "2", []byte("current-secret"), func(c *accessClaims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Second)) }), false},
+		{"other user", sign(jwt.SigningMethodHS256, "2", []byte("current-secret"), func(c *accessClaims) { c.Subject = "mallory@example.com" }), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			email, _, err := authenticate(s, &http.Cookie{Name: AccessTokenCookie, Value: test.token})
+			if test.valid && (err != nil || email != user) {
+				t.Errorf("expected %v, got %v, %v", user, email, err)
+			}
+			if !test.valid && !errors.Is(err, domain.ErrUnauthenticated) {
+				t.Errorf("expected ErrUnauthenticated, got %v, %v", email, err)
+			}
+		})
+	}
+}
+
+func TestTheRefreshTokenRenewsExpiredAccessTokens(t *testing.T) {
+	s, now := newTestSessions(t)
+	cookies := login(t, s)
+	*now = now.Add(s.cfg.TokenLifetime + time.Second)
+
+	if _, _, err := authenticate(s, cookies[AccessTokenCookie]); !errors.Is(err, domain.ErrUnauthenticated) {
+		t.Fatalf("expected the access token to be expired, got %v", err)
+	}
+	email, recorder, err := authenticate(s, cookies[AccessTokenCookie], cookies[RefreshTokenCookie])
+	if err != nil || email != user {
+		t.Fatalf("expected %v, got %v, %v", user, email, err)
+	}
+	renewed := cookiesOf(recorder)
+	if renewed[RefreshTokenCookie] == nil || renewed[RefreshTokenCookie].Value == cookies[RefreshTokenCookie].Value {
+		t.Errorf("expected a new refresh token, got %v", renewed[RefreshTokenCookie])
+	}
+	if email, _, err = authenticate(s, renewed[AccessTokenCookie]); err != nil || email != user {
+		t.Errorf("expected the new access token to be valid, got %v, %v", email, err)
+	}
+
+	// a request that was sent together with the first one
+	email, recorder, err = authenticate(s, cookies[RefreshTokenCookie])
+	if err != nil || email != user {
+		t.Errorf("expected the replaced refresh token to be accepted in the grace period, got %v, %v", email, err)
+	}
+	if _, ok := cookiesOf(recorder)[RefreshTokenCookie]; ok {
+		t.Error("expected no new refresh token in the grace period")
+	}
+}
+
+func TestAReusedRefreshTokenRevokesTheSession(t *testing.T) {
+	s, now := newTestSessions(t)
+	cookies := login(t, s)
+
+	_, recorder, err := authenticate(s, cookies[RefreshTokenCookie])
+	if err != nil {
+		t.Fatal(err)
+	}
+	renewed := cookiesOf(recorder)
+	*now = now.Add(refreshGracePeriod)
+
+	_, recorder, err = authenticate(s, cookies[RefreshTokenCookie])
+	if !errors.Is(err, domain.ErrUnauthenticated) {
+		t.Fatalf("expected the reused refresh token to be rejected, got %v", err)
+	}
+	if cleared := cookiesOf(recorder)[RefreshTokenCookie]; cleared == nil || cleared.MaxAge >= 0 {
+		t.Errorf("expected the refresh token cookie to be removed, got %v", cleared)
+	}
+	for _, cookie := range []*http.Cookie{renewed[AccessTokenCookie], renewed[RefreshTokenCookie]} {
+		if _, _, err = authenticate(s, cookie); !errors.Is(err, domain.ErrUnauthenticated) {
+			t.Errorf("expected %v of the revoked session to be rejected, got %v", cookie.Name, err)
+		}
+	}
+}
+
+func TestLogoutRevokesTheSession(t *testing.T) {
+	s, _ := newTestSessions(t)
+	cookies := login(t, s)
+	other := login(t, s)
+
+	request := httptest.NewRequest(http.MethodPost, "/logout", nil)
+	request.AddCookie(cookies[AccessTokenCookie])
+	request.AddCookie(cookies[RefreshTokenCookie])
+	recorder := httptest.NewRecorder()
+	if err := s.Logout(recorder, request); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, cookie := range cookiesOf(recorder) {
+		if cookie.MaxAge >= 0 {
+			t.Errorf("expected the cookie %v to be removed, got %v", name, cookie)
+		}
+	}
+	for _, cookie := range cookies {
+		if _, _, err := authenticate(s, cookie); !errors.Is(err, domain.ErrUnauthenticated) {
+			t.Errorf("expected %v to be revoked, got %v", cookie.Name, err)
+		}
+	}
+	if email, _, err := authenticate(s, other[AccessTokenCookie]); err != nil || email != user {
+		t.Errorf("expected the other session to stay active, got %v, %v", email, err)
+	}
+}
+
+func TestRevalidateFollowsTheSessionWithoutRenewingIt(t *testing.T) {
+	s, now := newTestSessions(t)
+	cookies := login(t, s)
+	stream := httptest.NewRequest(http.MethodGet, "/models/1/events", nil)
+	stream.AddCookie(cookies[AccessTokenCookie])
+	stream.AddCookie(cookies[RefreshTokenCookie])
+
+	// the stream outlives its access token
+	*now = now.Add(s.cfg.TokenLifetime + time.Second)
+	if email, err := s.Revalidate(stream); err != nil || email != user {
+		t.Fatalf("expected the session to be active, got %v, %v", email, err)
+	}
+	if email, _, err := authenticate(s, cookies[RefreshTokenCookie]); err != nil || email != user {
+		t.Fatalf("expected the refresh token not to be replaced by Revalidate, got %v, %v", email, err)
+	}
+
+	logout := httptest.NewRequest(http.MethodPost, "/logout", nil)
+	logout.AddCookie(cookies[RefreshTokenCookie])
+	if err := s.Logout(httptest.NewRecorder(), logout); err != nil {
+		t.Fatal(err)
+	}
+	if email, err := s.Revalidate(stream); !errors.Is(err, domain.ErrUnauthenticated) {
+		t.Errorf("expected the logged out session to be rejected, got %v, %v", email, err)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gossie/modelling-service/config"
+)
+
+const (
+	initialPingDelay = 250 * time.Millisecond
+	maxPingDelay     = 5 * time.Second
+)
+
+func connectToDB(cfg config.Database) *sql.DB {
+	dsn, err := cfg.PostgresDSN()
+	if err != nil {
+		exitWithError(err.Error())
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		exitWithError(fmt.Sprintf("could not open the database: %v", err.Error()))
+	}
+	configurePool(db, cfg.Pool)
+
+	err = waitForDatabase(context.Background(), db, cfg.ConnectTimeout)
+	if err != nil {
+		db.Close()
+		exitWithError(fmt.Sprintf("could not connect to the database within %v: %v", cfg.ConnectTimeout, err.Error()))
+	}
+	return db
+}
+
+func configurePool(db *sql.DB, cfg config.Pool) {
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+}
+
+// waitForDatabase pings the database until it answers, the delay between the attempts doubles up to maxPingDelay.
+func waitForDatabase(ctx context.Context, db *sql.DB, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	delay := initialPingDelay
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+
+		slog.Warn(fmt.Sprintf("database is not available yet, attempt %v, trying again in %v: %v", attempt, delay, err.Error()))
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, maxPingDelay)
+	}
+}
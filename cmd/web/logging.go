@@ -5,7 +5,9 @@ import (
 	"log/slog"
 	"os"
 
+	"github.com/gossie/modelling-service/config"
 	"github.com/gossie/modelling-service/middleware"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type LogHandler struct {
@@ -21,10 +23,23 @@ func (lh *LogHandler) Handle(ctx context.Context, r slog.Record) error {
 		r.AddAttrs(slog.String("userIdentifier", userIdentifier))
 	}
 
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		r.AddAttrs(slog.String("traceId", spanContext.TraceID().String()), slog.String("spanId", spanContext.SpanID().String()))
+	}
+
 	return lh.Handler.Handle(ctx, r)
 }
-func customizeLogging() {
-	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo})
+
+func customizeLogging(cfg config.Logging) {
+	var level slog.Level
+	_ = level.UnmarshalText([]byte(cfg.Level))
+
+	var handler slog.Handler
+	if cfg.Format == "text" {
+		handler = slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: level})
+	} else {
+		handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level})
+	}
 	wrapper := LogHandler{handler}
 	logger := slog.New(&wrapper)
 	slog.SetDefault(logger)
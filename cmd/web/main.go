@@ -1,69 +1,222 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/gossie/modelling-service/config"
+	"github.com/gossie/modelling-service/domain"
+	"github.com/gossie/modelling-service/events"
+	"github.com/gossie/modelling-service/metrics"
+	"github.com/gossie/modelling-service/persistence"
 	"github.com/gossie/modelling-service/rest"
+	"github.com/gossie/modelling-service/tracing"
+	"github.com/gossie/modelling-service/views"
+	"github.com/gossie/modelling-service/webhooks"
 	_ "github.com/lib/pq"
 )
 
-const (
-	defaultDBHost = "localhost"
-	defaultDBPort = "5432"
-	defaultDBUser = "postgres"
-	defaultDBName = "modelling"
-)
-
-func connectToDB() *sql.DB {
-	dbHost := getOrDefault("DB_HOST", defaultDBHost)
-	dbPort := getOrDefault("DB_PORT", defaultDBPort)
-	dbUser := getOrDefault("DB_USER", defaultDBUser)
-	dbPassword := os.Getenv("DB_PASSWORD")
-	if dbPassword == "" {
-		panic("no DB_PASSWORD was passed")
-	}
-	dbName := getOrDefault("DB_NAME", defaultDBName)
-	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", dbHost, dbPort, dbUser, dbPassword, dbName)
+// backend is the persistence configured by database.persistence.
+type backend struct {
+	repositories domain.Repositories
+	// migrator is nil if the backend has no schema
+	migrator *persistence.Migrator
+	// db is nil if the backend has no database
+	db *sql.DB
+	// workersLock is held by the instance that runs the workers of the outbox and the webhooks
+	workersLock domain.Lock
+	close       func()
+}
 
-	db, err := sql.Open("postgres", psqlInfo)
-	if err != nil {
-		panic(err)
+func openBackend(cfg config.Database) backend {
+	switch cfg.Persistence {
+	case "postgres":
+		db := connectToDB(cfg)
+		migrator, err := persistence.NewPsqlMigrator(db)
+		if err != nil {
+			panic(err)
+		}
+		return backend{persistence.NewPsqlRepositories(db), migrator, db, persistence.NewPsqlLock(db, workersLockKey), func() { db.Close() }}
+	case "sqlite":
+		db, err := persistence.OpenSqlite(context.Background(), cfg.SqlitePath)
+		if err != nil {
+			panic(err)
+		}
+		configurePool(db, cfg.Pool)
+		migrator, err := persistence.NewSqliteMigrator(db)
+		if err != nil {
+			panic(err)
+		}
+		return backend{persistence.NewSqliteRepositories(db), migrator, db, persistence.NewLocalLock(), func() { db.Close() }}
+	default:
+		return backend{persistence.NewMemoryRepositories(cfg.MemoryUsers...), nil, nil, persistence.NewLocalLock(), func() {}}
 	}
-	return db
 }
 
-func getOrDefault(env, defaultValue string) string {
-	value := os.Getenv(env)
-	if value == "" {
-		slog.Info("no " + env + " was set, using default: " + defaultValue)
-		value = defaultValue
+// newHttpServer applies the timeouts and limits of the configuration.
+func newHttpServer(cfg config.Server, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
 	}
-	return value
 }
 
+// tracingFlushTimeout bounds exporting the remaining spans on shutdown.
+const tracingFlushTimeout = 5 * time.Second
+
+const usage = "usage: web [flags] [migrate [up | down [steps] | version] | config]\nrun web -h to list the flags"
+
 func main() {
-	customizeLogging()
+	// the flags come before the subcommand: web -persistence sqlite migrate up
+	cfg, args, err := config.LoadFromEnvironment(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if len(args) > 0 && args[0] == "config" {
+		// the effective configuration is printed even if it is invalid, it helps to find the problem
+		_ = cfg.Print(os.Stdout)
+		if err != nil {
+			exitWithError(fmt.Sprintf("invalid configuration:\n%v", err.Error()))
+		}
+		return
+	}
+	if err != nil {
+		exitWithError(fmt.Sprintf("invalid configuration:\n%v", err.Error()))
+	}
+
+	customizeLogging(cfg.Logging)
+	slog.Info("effective configuration", "config", cfg)
+
+	if len(args) > 0 {
+		if args[0] != "migrate" {
+			exitWithError(usage)
+		}
+		migrate(cfg.Database, args[1:])
+		return
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		exitWithError(err.Error())
+	}
+
+	store := openBackend(cfg.Database)
+	defer store.close()
+
+	if store.migrator != nil && cfg.Database.MigrateOnStartup {
+		err := store.migrator.Up(context.Background())
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	shutdownTimeout := cfg.Server.ShutdownTimeout
+
+	// the workers keep running while the server drains, the requests it finishes may still produce events
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	m := metrics.New()
+	views.ObserveRendering(m.ObserveRendering)
+	repositories := metrics.Timed(store.repositories, m)
+
+	broker := events.NewBroker()
+	targets := webhooks.NewTargets(cfg.Webhooks.AllowedHosts)
+	dispatcher := webhooks.NewDispatcher(repositories.Webhooks, targets.Client(10*time.Second))
+	// the relay publishes every event once, the follower hands them to the viewers of every instance
+	relay := events.NewRelay(repositories.Outbox, dispatcher.Enqueue)
+	follower := events.NewFollower(repositories.Outbox, broker.Handle)
+	m.RegisterRelay(relay)
+	m.RegisterModels(repositories.Models)
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	var workers sync.WaitGroup
+	workers.Add(2)
+	go func() {
+		defer workers.Done()
+		runWhileLocked(workersCtx, store.workersLock, func(ctx context.Context) {
+			var locked sync.WaitGroup
+			locked.Add(2)
+			go func() {
+				defer locked.Done()
+				relay.Run(ctx)
+			}()
+			go func() {
+				defer locked.Done()
+				dispatcher.Run(ctx)
+			}()
+			locked.Wait()
+		})
+	}()
+	go func() {
+		defer workers.Done()
+		follower.Run(workersCtx)
+	}()
+
+	notify := func() {
+		relay.Notify()
+		follower.Notify()
+	}
+	svr := rest.NewServer(events.Recording(repositories, notify), broker, cfg, m)
+	if store.db != nil {
+		svr.AddHealthCheck("database", store.db.PingContext)
+		svr.SetDatabaseStats(store.db.Stats)
+		m.RegisterDatabase(store.db, cfg.Database.Persistence)
 	}
+	httpServer := newHttpServer(cfg.Server, svr)
+
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	slog.Info("starting server on port " + cfg.Server.Port)
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- httpServer.ListenAndServe()
+	}()
 
-	jwtSecrect := os.Getenv("JWT_SECRET")
-	if jwtSecrect == "" {
-		panic("no JWT_SECRET was passed")
+	select {
+	case err := <-serverErr:
+		stopWorkers()
+		workers.Wait()
+		store.close()
+		log.Fatal(err)
+	case <-signals.Done():
+		slog.Info(fmt.Sprintf("shutting down, waiting up to %v for requests to finish", shutdownTimeout))
 	}
 
-	db := connectToDB()
-	defer db.Close()
+	svr.Drain()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err = httpServer.Shutdown(shutdownCtx)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("requests did not finish in time: %v", err.Error()))
+	}
 
-	svr := rest.NewServer(db, jwtSecrect)
+	// the workers finish the event or delivery they are working on, the deferred close of the store runs afterwards
+	stopWorkers()
+	workers.Wait()
 
-	slog.Info("starting server on port " + port)
-	log.Fatal(http.ListenAndServe(":"+port, svr))
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), tracingFlushTimeout)
+	defer cancelFlush()
+	err = shutdownTracing(flushCtx)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("could not export the remaining spans: %v", err.Error()))
+	}
+	slog.Info("server stopped")
 }
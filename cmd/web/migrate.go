@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/gossie/modelling-service/config"
+)
+
+const migrateUsage = "usage: web migrate [up | down [steps] | version]"
+
+// migrate runs the migrate subcommand, without arguments it applies all pending migrations.
+func migrate(cfg config.Database, args []string) {
+	store := openBackend(cfg)
+	defer store.close()
+
+	if store.migrator == nil {
+		fmt.Println("the configured persistence has no schema to migrate")
+		return
+	}
+
+	ctx := context.Background()
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	var err error
+	switch command {
+	case "up":
+		err = store.migrator.Up(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				exitWithError(migrateUsage)
+			}
+		}
+		err = store.migrator.Down(ctx, steps)
+	case "version":
+		var version int
+		version, err = store.migrator.Version(ctx)
+		if err == nil {
+			fmt.Printf("schema version %v\n", version)
+			for _, migration := range store.migrator.Migrations() {
+				applied := "pending"
+				if migration.Version <= version {
+					applied = "applied"
+				}
+				fmt.Printf("%04d_%v\t%v\n", migration.Version, migration.Name, applied)
+			}
+		}
+	default:
+		exitWithError(migrateUsage)
+	}
+
+	if err != nil {
+		exitWithError(err.Error())
+	}
+}
+
+func exitWithError(message string) {
+	fmt.Fprintln(os.Stderr, message)
+	os.Exit(1)
+}
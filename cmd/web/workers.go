@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gossie/modelling-service/domain"
+)
+
+// workersLockKey identifies the lock of the workers that may only run on one instance per database.
+const workersLockKey int64 = 7_305_092_416
+
+// lockInterval is how often an instance tries to get the lock, and how often the holder checks that it still has it.
+const lockInterval = 10 * time.Second
+
+// runWhileLocked runs work while the instance holds the lock and tries to get the lock again if it is lost.
+func runWhileLocked(ctx context.Context, lock domain.Lock, work func(context.Context)) {
+	ticker := time.NewTicker(lockInterval)
+	defer ticker.Stop()
+
+	for {
+		held, err := lock.TryLock(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.WarnContext(ctx, fmt.Sprintf("could not get the lock of the workers: %v", err.Error()))
+		}
+		if held {
+			slog.InfoContext(ctx, "this instance runs the workers of the outbox and the webhooks")
+			workCtx, stopWork := context.WithCancel(ctx)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				work(workCtx)
+			}()
+
+			for held && ctx.Err() == nil {
+				select {
+				case <-ctx.Done():
+				case <-ticker.C:
+					held, err = lock.TryLock(ctx)
+					if !held && ctx.Err() == nil {
+						slog.WarnContext(ctx, fmt.Sprintf("lost the lock of the workers, stopping them: %v", err))
+					}
+				}
+			}
+			stopWork()
+			<-done
+
+			// ctx may be done already
+			unlockCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+			err = lock.Unlock(unlockCtx)
+			cancel()
+			if err != nil {
+				slog.WarnContext(ctx, fmt.Sprintf("could not release the lock of the workers: %v", err.Error()))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
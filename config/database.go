@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// PostgresDSN returns the connection string for lib/pq. The URL is used if it is set, the TLS settings only fill in
+// the parameters it does not have.
+func (d Database) PostgresDSN() (string, error) {
+	tls := map[string]string{
+		"sslmode":     d.Tls.Mode,
+		"sslrootcert": d.Tls.RootCert,
+		"sslcert":     d.Tls.Cert,
+		"sslkey":      d.Tls.Key,
+	}
+
+	if d.Url != "" {
+		u, err := url.Parse(d.Url)
+		if err != nil {
+			return "", fmt.Errorf("invalid database URL: %w", err)
+		}
+		query := u.Query()
+		for _, key := range sortedKeys(tls) {
+			if tls[key] != "" && !query.Has(key) {
+				query.Set(key, tls[key])
+			}
+		}
+		u.RawQuery = query.Encode()
+		return u.String(), nil
+	}
+
+	parameters := map[string]string{
+		"host":     d.Host,
+		"port":     d.Port,
+		"user":     d.User,
+		"password": d.Password,
+		"dbname":   d.Name,
+	}
+	for key, value := range tls {
+		parameters[key] = value
+	}
+
+	dsn := make([]string, 0, len(parameters))
+	for _, key := range sortedKeys(parameters) {
+		if parameters[key] != "" {
+			dsn = append(dsn, fmt.Sprintf("%v=%v", key, quote(parameters[key])))
+		}
+	}
+	return strings.Join(dsn, " "), nil
+}
+
+// quote escapes a value of a key=value connection string.
+func quote(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
@@ -0,0 +1,246 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileEnv names the environment variable with the path of the config file, the flag -config takes precedence.
+const ConfigFileEnv = "CONFIG_FILE"
+
+const redacted = "REDACTED"
+
+// Load builds the configuration from the defaults, the config file, the environment and the command-line arguments.
+// args must not contain the program name. The arguments that are left after the flags are returned.
+func Load(args []string, lookupEnv func(string) (string, bool)) (Config, []string, error) {
+	c := Default()
+
+	flags := flag.NewFlagSet("web", flag.ContinueOnError)
+	configFile := flags.String("config", "", "YAML file with the configuration, overrides "+ConfigFileEnv)
+	flagValues := make(map[string]string)
+	for _, s := range settings(&c) {
+		name := s.flag
+		store := func(value string) error {
+			flagValues[name] = value
+			return nil
+		}
+		if s.value.Kind() == reflect.Bool {
+			flags.BoolFunc(name, s.usage, store)
+		} else {
+			flags.Func(name, s.usage, store)
+		}
+	}
+	err := flags.Parse(args)
+	if err != nil {
+		return c, nil, err
+	}
+
+	file := *configFile
+	if file == "" {
+		file, _ = lookupEnv(ConfigFileEnv)
+	}
+	if file != "" {
+		err = readFile(file, &c)
+		if err != nil {
+			return c, nil, err
+		}
+	}
+
+	var problems []error
+	for _, s := range settings(&c) {
+		if value, ok := lookupEnv(s.env); ok && value != "" {
+			if err := s.set(value); err != nil {
+				problems = append(problems, fmt.Errorf("environment variable %v: %w", s.env, err))
+			}
+		}
+	}
+	for _, s := range settings(&c) {
+		if value, ok := flagValues[s.flag]; ok {
+			if err := s.set(value); err != nil {
+				problems = append(problems, fmt.Errorf("flag -%v: %w", s.flag, err))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return c, nil, errors.Join(problems...)
+	}
+
+	return c, flags.Args(), c.Validate()
+}
+
+// LoadFromEnvironment loads the configuration with the environment of the process.
+func LoadFromEnvironment(args []string) (Config, []string, error) {
+	return Load(args, os.LookupEnv)
+}
+
+func readFile(path string, c *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("could not read config file: %w", err)
+	}
+	defer f.Close()
+
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	err = decoder.Decode(c)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %v: %w", path, err)
+	}
+	return nil
+}
+
+// Redacted returns a copy of the configuration whose secrets are replaced.
+func (c Config) Redacted() Config {
+	for _, s := range settings(&c) {
+		if s.secret == "keys" {
+			// the key IDs stay visible, so that it can be seen which keys are configured
+			keys := make([]string, 0, s.value.Len())
+			for _, key := range s.value.Interface().([]string) {
+				keyId, _, _ := strings.Cut(key, "=")
+				keys = append(keys, keyId+"="+redacted)
+			}
+			s.value.Set(reflect.ValueOf(keys))
+			continue
+		}
+		if s.secret == "" || s.value.String() == "" {
+			continue
+		}
+		if s.secret != "url" {
+			s.value.SetString(redacted)
+			continue
+		}
+		u, err := url.Parse(s.value.String())
+		if err != nil {
+			s.value.SetString(redacted)
+			continue
+		}
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+		}
+		s.value.SetString(u.String())
+	}
+	return c
+}
+
+// Print writes the configuration with redacted secrets in the format of the config file.
+func (c Config) Print(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	err := encoder.Encode(c.Redacted())
+	if err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// LogValue logs every setting with redacted secrets.
+func (c Config) LogValue() slog.Value {
+	redactedConfig := c.Redacted()
+	attrs := make([]slog.Attr, 0)
+	for _, s := range settings(&redactedConfig) {
+		attrs = append(attrs, slog.String(s.key, s.String()))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// setting is a single value of the configuration together with its tags.
+type setting struct {
+	key    string
+	env    string
+	flag   string
+	usage  string
+	secret string
+	value  reflect.Value
+}
+
+// settings returns the settings of c in the order they are declared.
+func settings(c *Config) []setting {
+	return collectSettings(reflect.ValueOf(c).Elem(), "")
+}
+
+func collectSettings(v reflect.Value, prefix string) []setting {
+	result := make([]setting, 0)
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		key := prefix + field.Tag.Get("yaml")
+		if field.Type.Kind() == reflect.Struct {
+			result = append(result, collectSettings(v.Field(i), key+".")...)
+			continue
+		}
+		result = append(result, setting{
+			key:    key,
+			env:    field.Tag.Get("env"),
+			flag:   field.Tag.Get("flag"),
+			usage:  field.Tag.Get("usage"),
+			secret: field.Tag.Get("secret"),
+			value:  v.Field(i),
+		})
+	}
+	return result
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func (s setting) set(raw string) error {
+	switch {
+	case s.value.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration like 30s or 5m", raw)
+		}
+		s.value.SetInt(int64(d))
+	case s.value.Kind() == reflect.String:
+		s.value.SetString(raw)
+	case s.value.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		s.value.SetInt(int64(n))
+	case s.value.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		s.value.SetFloat(f)
+	case s.value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", raw)
+		}
+		s.value.SetBool(b)
+	case s.value.Kind() == reflect.Slice:
+		values := make([]string, 0)
+		for _, value := range strings.Split(raw, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+		s.value.Set(reflect.ValueOf(values))
+	default:
+		return fmt.Errorf("unsupported type %v", s.value.Type())
+	}
+	return nil
+}
+
+func (s setting) String() string {
+	switch {
+	case s.value.Type() == durationType:
+		return time.Duration(s.value.Int()).String()
+	case s.value.Kind() == reflect.Slice:
+		return strings.Join(s.value.Interface().([]string), ",")
+	default:
+		return fmt.Sprint(s.value.Interface())
+	}
+}
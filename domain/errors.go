@@ -0,0 +1,18 @@
+package domain
+
+import "errors"
+
+var ErrNotFound = errors.New("not found")
+
+var ErrValueInUse = errors.New("value is referenced by at least one constraint")
+
+var ErrInvalidCursor = errors.New("cursor is invalid")
+
+// ErrConflict means that the entity was changed since the client read the version it based its change on.
+var ErrConflict = errors.New("entity was modified concurrently")
+
+// ErrUnauthenticated means that a request carries no valid credentials.
+var ErrUnauthenticated = errors.New("not authenticated")
+
+// ErrForbidden means that the credentials of a request do not allow it, like an API token without the needed scope.
+var ErrForbidden = errors.New("not allowed")
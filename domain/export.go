@@ -0,0 +1,35 @@
+package domain
+
+import (
+	configurationmodel "github.com/gossie/configuration-model"
+)
+
+type ConfigurationModelExport struct {
+	Id          int                 `json:"id"`
+	Name        string              `json:"name"`
+	Parameters  []ExportedParameter `json:"parameters"`
+	Constraints []Constraint        `json:"constraints"`
+	Metadata    ExportMetadata      `json:"metadata"`
+}
+
+type ExportedParameter struct {
+	Id           int                          `json:"id"`
+	Name         string                       `json:"name"`
+	ValueType    configurationmodel.ValueType `json:"valueType"`
+	Values       []string                     `json:"values"`
+	Description  string                       `json:"description,omitempty"`
+	Unit         string                       `json:"unit,omitempty"`
+	Required     bool                         `json:"required"`
+	DefaultValue string                       `json:"defaultValue,omitempty"`
+}
+
+type ExportMetadata struct {
+	Groups []ExportedParameterGroup `json:"groups"`
+}
+
+type ExportedParameterGroup struct {
+	Id           int    `json:"id"`
+	Name         string `json:"name"`
+	Translation  string `json:"translation"`
+	ParameterIds []int  `json:"parameterIds"`
+}
@@ -1,9 +1,16 @@
 package domain
 
 import (
+	"time"
+
 	configurationmodel "github.com/gossie/configuration-model"
 )
 
+type User struct {
+	Id    int    `json:"id"`
+	Email string `json:"email"`
+}
+
 type ModelCreationRequest struct {
 	Name string `json:"name"`
 }
@@ -13,6 +20,17 @@ type Model struct {
 	Name        string       `json:"name"`
 	Translation string       `json:"translation"`
 	Constraints []Constraint `json:"constraints"`
+	CreatedAt   time.Time    `json:"createdAt"`
+	UpdatedAt   time.Time    `json:"updatedAt"`
+	// Version is increased whenever parameters are added to or removed from the model
+	Version int `json:"version"`
+}
+
+// ModelStatistics describes the size of a model for monitoring.
+type ModelStatistics struct {
+	ModelId     int
+	Parameters  int
+	Constraints int
 }
 
 type ConstraintCreationRequest struct {
@@ -34,6 +52,7 @@ type Constraint struct {
 	FromValueId   int                               `json:"fromValueId"`
 	TargetId      int                               `json:"targetId"`
 	TargetValueId int                               `json:"targetValueId"`
+	Version       int                               `json:"version"`
 }
 
 type ParameterCreationRequest struct {
@@ -42,11 +61,30 @@ type ParameterCreationRequest struct {
 }
 
 type Parameter struct {
-	Id          int                          `json:"id"`
-	Name        string                       `json:"name"`
-	Translation string                       `json:"translation"`
-	ValueType   configurationmodel.ValueType `json:"valueType"`
-	Value       ParameterValue               `json:"value"`
+	Id           int                          `json:"id"`
+	Name         string                       `json:"name"`
+	Translation  string                       `json:"translation"`
+	ValueType    configurationmodel.ValueType `json:"valueType"`
+	Value        ParameterValue               `json:"value"`
+	GroupId      int                          `json:"groupId"`
+	Position     int                          `json:"position"`
+	Description  string                       `json:"description"`
+	Unit         string                       `json:"unit"`
+	Required     bool                         `json:"required"`
+	DefaultValue string                       `json:"defaultValue"`
+	CreatedAt    time.Time                    `json:"createdAt"`
+	UpdatedAt    time.Time                    `json:"updatedAt"`
+	// Version is increased with every change of the parameter, its translations and its values
+	Version int `json:"version"`
+}
+
+type ParameterModificationRequest struct {
+	Description  string `json:"description"`
+	Unit         string `json:"unit"`
+	Required     bool   `json:"required"`
+	DefaultValue string `json:"defaultValue"`
+	// Version is the version of the parameter the modification is based on, 0 skips the check
+	Version int `json:"version"`
 }
 
 type ParameterValue struct {
@@ -57,16 +95,28 @@ type Value struct {
 	Id          int    `json:"id"`
 	Value       string `json:"value"`
 	Translation string `json:"translation"`
+	Position    int    `json:"position"`
+	// Version is increased with every change of the value, an update with version 0 skips the check
+	Version int `json:"version"`
 }
 
 type ValueModificationRequest struct {
 	NewValues     []string `json:"newValues"`
 	UpdatedValues []Value  `json:"updatedValues"`
+	DeletedValues []int    `json:"deletedValues"`
+	// Cascade deletes constraints that reference a deleted value instead of rejecting the request
+	Cascade bool `json:"cascade"`
+	// ValueOrder contains the IDs of the parameter's values in the order they should be presented
+	ValueOrder []int `json:"valueOrder"`
+	// Version is the version of the parameter the modification is based on, 0 skips the check
+	Version int `json:"version"`
 }
 
 type TranslationModificationRequest struct {
 	NewTranslations     []Translation `json:"newTranslations"`
 	UpdatedTranslations []Translation `json:"updatedTranslations"`
+	// Version is the version of the translated parameter the modification is based on, 0 skips the check
+	Version int `json:"version"`
 }
 
 type Translation struct {
@@ -75,3 +125,119 @@ type Translation struct {
 	Language string `json:"language"`
 	Value    string `json:"value"`
 }
+
+type ParameterGroupCreationRequest struct {
+	Name string `json:"name"`
+}
+
+type ParameterGroup struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name"`
+	Translation string `json:"translation"`
+	Position    int    `json:"position"`
+}
+
+// ParameterLayout describes which parameters belong to a group and in which order they appear.
+// A GroupId of 0 stands for the parameters that are not assigned to any group.
+type ParameterLayout struct {
+	GroupId      int   `json:"groupId"`
+	ParameterIds []int `json:"parameterIds"`
+}
+
+type WebhookCreationRequest struct {
+	Url    string `json:"url"`
+	Secret string `json:"secret"`
+}
+
+// Webhook subscribes a receiver to the events of a model. The payloads are signed with the secret.
+type Webhook struct {
+	Id        int       `json:"id"`
+	ModelId   int       `json:"modelId"`
+	Url       string    `json:"url"`
+	Secret    string    `json:"-"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   = DeliveryStatus("pending")
+	DeliveryDelivered = DeliveryStatus("delivered")
+	// DeliveryDead marks deliveries that failed too often until they are retried manually
+	DeliveryDead = DeliveryStatus("dead")
+)
+
+// WebhookDelivery is one attempt to tell a webhook about an event, including all its retries.
+type WebhookDelivery struct {
+	Id             int            `json:"id"`
+	WebhookId      int            `json:"webhookId"`
+	Url            string         `json:"url"`
+	Event          string         `json:"event"`
+	IdempotencyKey string         `json:"idempotencyKey"`
+	Payload        string         `json:"payload"`
+	Status         DeliveryStatus `json:"status"`
+	Attempts       int            `json:"attempts"`
+	LastError      string         `json:"lastError"`
+	ResponseStatus int            `json:"responseStatus"`
+	NextAttemptAt  time.Time      `json:"nextAttemptAt"`
+	CreatedAt      time.Time      `json:"createdAt"`
+	// DeliveredAt is zero until the receiver accepted the delivery
+	DeliveredAt time.Time `json:"deliveredAt"`
+}
+
+// DueDelivery is a pending delivery together with the webhook it goes to.
+type DueDelivery struct {
+	Delivery WebhookDelivery
+	Webhook  Webhook
+}
+
+// OutboxEvent is a change of a model that was stored in the same transaction as the change itself.
+type OutboxEvent struct {
+	Id      int
+	ModelId int
+	Kind    string
+	// IdempotencyKey stays the same when the event is published more than once, consumers use it to ignore duplicates
+	IdempotencyKey string
+	CreatedAt      time.Time
+	// PublishedAt is zero while the event is pending
+	PublishedAt time.Time
+}
+
+// Session is a login of a user. Only the hash of its refresh token is stored, the token is replaced whenever it is used.
+type Session struct {
+	Id               int
+	UserEmail        string
+	RefreshTokenHash string
+	// PreviousRefreshTokenHash is the hash of the refresh token replaced at RotatedAt, accepted for a moment
+	PreviousRefreshTokenHash string
+	CreatedAt                time.Time
+	// RotatedAt is zero until the refresh token was replaced the first time
+	RotatedAt time.Time
+	// ExpiresAt is moved on with every new refresh token
+	ExpiresAt time.Time
+	// RevokedAt is zero while the session is active
+	RevokedAt time.Time
+}
+
+// The scopes of API tokens. Tokens with ScopeRead may send GET requests, ScopeWrite allows all other requests.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+// ApiToken lets scripts call the API on behalf of a user. Only the hash of the token is stored, the token itself is
+// shown once when it is created.
+type ApiToken struct {
+	Id        int
+	UserEmail string
+	Name      string
+	Scopes    []string
+	TokenHash string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	// LastUsedAt is zero until the token was used, LastUsedFrom is the address of that request
+	LastUsedAt   time.Time
+	LastUsedFrom string
+	// RevokedAt is zero while the token is active
+	RevokedAt time.Time
+}
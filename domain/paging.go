@@ -0,0 +1,48 @@
+package domain
+
+import (
+	configurationmodel "github.com/gossie/configuration-model"
+)
+
+type SortField string
+
+const (
+	// SortByPosition sorts by the order the modeller defined. Models do not have a position and are sorted by creation instead.
+	SortByPosition SortField = "position"
+	SortByName     SortField = "name"
+	SortByCreated  SortField = "created"
+	SortByUpdated  SortField = "updated"
+)
+
+const (
+	DefaultPageSize = 50
+	// MaxPageSize bounds the limit that clients ask for, larger pages are cut down to it
+	MaxPageSize = 200
+)
+
+type PageRequest struct {
+	// Cursor is the opaque value of Page.NextCursor of the previous page. It is empty for the first page.
+	Cursor     string
+	Limit      int
+	SortBy     SortField
+	Descending bool
+}
+
+type Page[T any] struct {
+	Items []T
+	// NextCursor is empty if there are no more items.
+	NextCursor string
+}
+
+type ModelFilter struct {
+	Untranslated   bool
+	HasConstraints bool
+}
+
+type ParameterFilter struct {
+	SearchValue    string
+	ValueType      *configurationmodel.ValueType
+	HasValues      *bool
+	HasConstraints *bool
+	Untranslated   bool
+}
@@ -2,24 +2,156 @@ package domain
 
 import (
 	"context"
+	"time"
 )
 
+// Repositories bundles the repositories of one persistence backend.
+type Repositories struct {
+	Users           UserRepository
+	Models          ModelRepository
+	Parameters      ParameterRepository
+	ParameterGroups ParameterGroupRepository
+	Constraints     ConstraintRepository
+	Webhooks        WebhookRepository
+	Outbox          OutboxRepository
+	Sessions        SessionRepository
+	ApiTokens       ApiTokenRepository
+	UnitOfWork      UnitOfWork
+}
+
+// UnitOfWork makes several repository calls atomic.
+type UnitOfWork interface {
+	// Do passes repositories to work that share one transaction. The transaction is committed if work returns nil and rolled back otherwise.
+	// Units of work started on the passed repositories join the surrounding one.
+	Do(ctx context.Context, work func(Repositories) error) error
+}
+
+type UserRepository interface {
+	FindByEmail(context.Context, string) (User, error)
+	// SaveUser creates the user unless there is a user with the email already, it returns the user either way.
+	SaveUser(ctx context.Context, email string) (User, error)
+}
+
 type ModelRepository interface {
 	FindById(context.Context, int) (Model, error)
+	HasAccess(context.Context, int, string) (bool, error)
 	FindAllByUser(context.Context, string) ([]Model, error)
+	FindPageByUser(context.Context, string, ModelFilter, PageRequest) (Page[Model], error)
 	SaveModel(context.Context, string, ModelCreationRequest) (int, error)
+	// SyncGroupGrants gives the user access to the models of the user's groups and takes back what groups granted to
+	// other models. Access that no group granted, like that of the creator, is kept and unknown models are skipped.
+	// It returns the IDs of the models whose users changed, or ErrNotFound if there is no such user.
+	SyncGroupGrants(ctx context.Context, userEmail string, modelIds []int) ([]int, error)
+	// Statistics counts the parameters and constraints of every model, ordered by the model ID.
+	Statistics(context.Context) ([]ModelStatistics, error)
 }
 
 type ParameterRepository interface {
 	FindAllByModelId(context.Context, int, string) ([]Parameter, error)
+	FindPageByModelId(context.Context, int, ParameterFilter, PageRequest) (Page[Parameter], error)
+	FindById(context.Context, int, int) (Parameter, error)
 	SaveParameter(context.Context, int, ParameterCreationRequest) (int, error)
-	DeleteParameter(context.Context, int, int) error
-	FindAllTranslations(context.Context, string) ([]Translation, error)
-	SaveTranslations(context.Context, string, TranslationModificationRequest) error
-	SaveValues(context.Context, string, ValueModificationRequest) error
+	DeleteParameter(context.Context, int, int, int) error
+	UpdateParameter(context.Context, int, int, ParameterModificationRequest) error
+	// FindAllTranslations, SaveTranslations and SaveValues return ErrNotFound if the parameter does not belong to the
+	// model. Translations and values of other parameters are not changed.
+	FindAllTranslations(ctx context.Context, modelId, parameterId int) ([]Translation, error)
+	SaveTranslations(ctx context.Context, modelId, parameterId int, tmr TranslationModificationRequest) error
+	SaveValues(ctx context.Context, modelId, parameterId int, vmr ValueModificationRequest) error
+}
+
+type ParameterGroupRepository interface {
+	FindAllByModelId(context.Context, int) ([]ParameterGroup, error)
+	SaveGroup(context.Context, int, ParameterGroupCreationRequest) (int, error)
+	DeleteGroup(context.Context, int, int) error
+	// FindAllTranslations and SaveTranslations return ErrNotFound if the group does not belong to the model.
+	FindAllTranslations(ctx context.Context, modelId, groupId int) ([]Translation, error)
+	SaveTranslations(ctx context.Context, modelId, groupId int, tmr TranslationModificationRequest) error
+	// SaveLayout numbers the groups and the parameters of every group in the order of the layout, so the layout should
+	// contain all groups and parameters of the model. It returns ErrNotFound if a group of the layout does not belong
+	// to the model, parameters of other models are skipped.
+	SaveLayout(context.Context, int, []ParameterLayout) error
 }
 
 type ConstraintRepository interface {
+	// SaveConstraint returns ErrNotFound if a parameter of the constraint does not belong to the model or a value does
+	// not belong to its parameter.
 	SaveConstraint(context.Context, string, ConstraintCreationRequest) (int, error)
-	DeleteConstraint(context.Context, string, string) error
+	DeleteConstraint(context.Context, string, string, int) error
+}
+
+type WebhookRepository interface {
+	FindAllByModelId(context.Context, int) ([]Webhook, error)
+	SaveWebhook(context.Context, int, WebhookCreationRequest) (int, error)
+	DeleteWebhook(context.Context, int, int) error
+	// EnqueueDeliveries creates a pending delivery of the event for every webhook of the model. Webhooks that already
+	// have a delivery with the idempotency key are skipped, so enqueueing the same event again has no effect.
+	EnqueueDeliveries(ctx context.Context, modelId int, idempotencyKey string, event string, payload string) error
+	// FindDueDeliveries returns at most limit pending deliveries whose next attempt is due, the oldest first.
+	FindDueDeliveries(ctx context.Context, now time.Time, limit int) ([]DueDelivery, error)
+	// UpdateDelivery stores the outcome of an attempt.
+	UpdateDelivery(context.Context, WebhookDelivery) error
+	// FindDeliveriesByModelId returns the latest deliveries to the webhooks of the model, the newest first.
+	FindDeliveriesByModelId(ctx context.Context, modelId int, limit int) ([]WebhookDelivery, error)
+	// RetryDelivery makes a delivery of the model pending again, starting with a fresh number of attempts.
+	RetryDelivery(ctx context.Context, modelId int, deliveryId int) error
+}
+
+// OutboxRepository stores the events of changes. Used with the repositories of a unit of work, an event is only stored
+// if the change is committed, and a relay publishes it later.
+type OutboxRepository interface {
+	Append(ctx context.Context, modelId int, kind string, idempotencyKey string) error
+	// FindPending returns at most limit events that were not published yet, in the order they were appended. Events of
+	// the excluded models are skipped.
+	FindPending(ctx context.Context, limit int, excludedModelIds []int) ([]OutboxEvent, error)
+	MarkPublished(ctx context.Context, eventId int, publishedAt time.Time) error
+	// FindAfter returns at most limit events that were appended after the event with the ID, published or not, in the
+	// order they were appended.
+	FindAfter(ctx context.Context, eventId int, limit int) ([]OutboxEvent, error)
+	// LastId returns the ID of the event that was appended last, 0 if there are none.
+	LastId(context.Context) (int, error)
+	// PendingStats returns the number of events that were not published yet and when the oldest of them was appended.
+	// The time is zero if there are no pending events.
+	PendingStats(context.Context) (int, time.Time, error)
+	// DeletePublished removes the events that were published before the given time and returns how many there were.
+	DeletePublished(ctx context.Context, before time.Time) (int, error)
+}
+
+// Lock is held by at most one instance of the service at a time. Workers that must not run concurrently, like the
+// relay of the outbox, only run on the instance that holds it.
+type Lock interface {
+	// TryLock acquires the lock if no instance holds it and tells whether this instance holds it now. Called again by
+	// the holder, it checks that the lock was not lost in the meantime.
+	TryLock(context.Context) (bool, error)
+	// Unlock releases the lock if this instance holds it.
+	Unlock(context.Context) error
+}
+
+type SessionRepository interface {
+	// SaveSession starts a session of the user and returns its ID. It returns ErrNotFound if there is no such user.
+	SaveSession(ctx context.Context, userEmail string, refreshTokenHash string, expiresAt time.Time) (int, error)
+	// FindSession returns ErrNotFound if there is no session with the ID.
+	FindSession(ctx context.Context, sessionId int) (Session, error)
+	// RotateRefreshToken replaces the refresh token of an active session and keeps the hash of the old one. It returns
+	// ErrConflict if the session has another refresh token by now or was revoked, and ErrNotFound if there is none.
+	RotateRefreshToken(ctx context.Context, sessionId int, expectedHash string, newHash string, rotatedAt time.Time, expiresAt time.Time) error
+	// RevokeSession ends the session, its tokens are not accepted anymore. Revoking a revoked session has no effect.
+	RevokeSession(ctx context.Context, sessionId int, revokedAt time.Time) error
+	// DeleteExpiredSessions removes the sessions that expired or were revoked before the given time and returns how
+	// many there were.
+	DeleteExpiredSessions(ctx context.Context, before time.Time) (int, error)
+}
+
+type ApiTokenRepository interface {
+	// SaveApiToken creates a token of the user and returns its ID. It returns ErrNotFound if there is no such user.
+	SaveApiToken(ctx context.Context, userEmail string, name string, scopes []string, tokenHash string, expiresAt time.Time) (int, error)
+	// FindApiToken returns ErrNotFound if there is no token with the ID.
+	FindApiToken(ctx context.Context, tokenId int) (ApiToken, error)
+	// FindApiTokensByUser returns the tokens of the user, the newest first. Revoked and expired tokens are included.
+	FindApiTokensByUser(ctx context.Context, userEmail string) ([]ApiToken, error)
+	// RecordApiTokenUse remembers when and from where the token was used last.
+	RecordApiTokenUse(ctx context.Context, tokenId int, usedAt time.Time, usedFrom string) error
+	// RevokeApiToken revokes a token of the user. It returns ErrNotFound if the user has no such token, revoking a
+	// revoked token has no effect.
+	RevokeApiToken(ctx context.Context, userEmail string, tokenId int, revokedAt time.Time) error
 }
@@ -0,0 +1,124 @@
+package events
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Kind string
+
+const (
+	ParametersChanged  = Kind("parameters")
+	ConstraintsChanged = Kind("constraints")
+	PresenceChanged    = Kind("presence")
+	ModelPublished     = Kind("published")
+)
+
+// Event tells the viewers of a model that a part of it changed. It carries no data, viewers load the current state themselves.
+type Event struct {
+	ModelId int
+	Kind    Kind
+	// Key identifies events of the outbox, it is the same when an event is published again
+	Key        string
+	OccurredAt time.Time
+}
+
+// subscriptionBuffer is the number of events a subscriber can fall behind before further events are dropped.
+const subscriptionBuffer = 16
+
+type Subscription struct {
+	ModelId int
+	Viewer  string
+	events  chan Event
+}
+
+// Events is closed when the subscription ends.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Broker distributes events to the subscribers of a model. It only works within one process.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[int]map[*Subscription]bool
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: make(map[int]map[*Subscription]bool)}
+}
+
+// Subscribe registers the viewer for the events of the model and tells all viewers about the new one.
+func (b *Broker) Subscribe(modelId int, viewer string) *Subscription {
+	subscription := &Subscription{ModelId: modelId, Viewer: viewer, events: make(chan Event, subscriptionBuffer)}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subscribers[modelId] == nil {
+		b.subscribers[modelId] = make(map[*Subscription]bool)
+	}
+	b.subscribers[modelId][subscription] = true
+	b.publish(Event{ModelId: modelId, Kind: PresenceChanged})
+	return subscription
+}
+
+// Unsubscribe ends the subscription and tells the remaining viewers that the viewer left.
+func (b *Broker) Unsubscribe(subscription *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	subscribers := b.subscribers[subscription.ModelId]
+	if !subscribers[subscription] {
+		return
+	}
+
+	delete(subscribers, subscription)
+	close(subscription.events)
+	if len(subscribers) == 0 {
+		delete(b.subscribers, subscription.ModelId)
+	}
+	b.publish(Event{ModelId: subscription.ModelId, Kind: PresenceChanged})
+}
+
+func (b *Broker) Publish(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.publish(event)
+}
+
+// Handle publishes an event of the outbox, it is meant to be a Handler of a Relay.
+func (b *Broker) Handle(ctx context.Context, event Event) error {
+	b.Publish(event)
+	return nil
+}
+
+// publish drops the events of subscribers that do not keep up. The caller has to hold the lock.
+func (b *Broker) publish(event Event) {
+	for subscription := range b.subscribers[event.ModelId] {
+		select {
+		case subscription.events <- event:
+		default:
+		}
+	}
+}
+
+// Viewers returns the distinct viewers of the model in alphabetical order.
+func (b *Broker) Viewers(modelId int) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	unique := make(map[string]bool)
+	for subscription := range b.subscribers[modelId] {
+		unique[subscription.Viewer] = true
+	}
+
+	viewers := make([]string, 0, len(unique))
+	for viewer := range unique {
+		viewers = append(viewers, viewer)
+	}
+	sort.Strings(viewers)
+	return viewers
+}
@@ -0,0 +1,156 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/gossie/modelling-service/domain"
+)
+
+// Follower hands every event of the outbox to its handlers on every instance, while the relay publishes it once on
+// the instance that holds the lock. It starts with the events appended after it started and does not repeat failed
+// handlers, so it suits handlers like Broker.Handle whose events only ask viewers to reload. IDs are assigned when an
+// event is appended, but transactions commit in any order, so a skipped ID is remembered as a gap and followed
+// later if its event commits within GapTimeout.
+type Follower struct {
+	outbox   domain.OutboxRepository
+	handlers []Handler
+	wake     chan struct{}
+	// lastId is the ID of the last followed event, it is -1 until the follower started
+	lastId int
+	gaps   []gap
+	now    func() time.Time
+
+	PollInterval time.Duration
+	BatchSize    int
+	GapTimeout   time.Duration
+}
+
+// gap is a range of skipped IDs whose events may still be committed.
+type gap struct {
+	from, to int
+	since    time.Time
+}
+
+func NewFollower(outbox domain.OutboxRepository, handlers ...Handler) *Follower {
+	return &Follower{
+		outbox:       outbox,
+		handlers:     handlers,
+		wake:         make(chan struct{}, 1),
+		lastId:       -1,
+		now:          time.Now,
+		PollInterval: time.Second,
+		BatchSize:    100,
+		GapTimeout:   time.Minute,
+	}
+}
+
+// Notify tells the follower that there are new events. It never blocks.
+func (f *Follower) Notify() {
+	select {
+	case f.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run follows the outbox until ctx is done.
+func (f *Follower) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		err := f.FollowNew(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.WarnContext(ctx, fmt.Sprintf("could not follow the events of the outbox: %v", err.Error()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-f.wake:
+		}
+	}
+}
+
+// FollowNew hands the events that were appended since the last call and the events that filled a gap to the
+// handlers. The first call only remembers the last event.
+func (f *Follower) FollowNew(ctx context.Context) error {
+	if f.lastId < 0 {
+		lastId, err := f.outbox.LastId(ctx)
+		if err != nil {
+			return err
+		}
+		f.lastId = lastId
+		return nil
+	}
+
+	f.closeGaps()
+	cursor := f.lastId
+	if len(f.gaps) > 0 {
+		cursor = f.gaps[0].from - 1
+	}
+
+	for {
+		appended, err := f.outbox.FindAfter(ctx, cursor, f.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, outboxEvent := range appended {
+			cursor = outboxEvent.Id
+			if outboxEvent.Id <= f.lastId && !f.fillGap(outboxEvent.Id) {
+				continue
+			}
+			if outboxEvent.Id > f.lastId+1 {
+				f.gaps = append(f.gaps, gap{from: f.lastId + 1, to: outboxEvent.Id - 1, since: f.now()})
+			}
+			f.lastId = max(f.lastId, outboxEvent.Id)
+			f.hand(ctx, outboxEvent)
+		}
+
+		if len(appended) < f.BatchSize {
+			return nil
+		}
+	}
+}
+
+func (f *Follower) hand(ctx context.Context, outboxEvent domain.OutboxEvent) {
+	event := Event{ModelId: outboxEvent.ModelId, Kind: Kind(outboxEvent.Kind), Key: outboxEvent.IdempotencyKey, OccurredAt: outboxEvent.CreatedAt}
+	for _, handler := range f.handlers {
+		err := handler(ctx, event)
+		if err != nil {
+			slog.WarnContext(ctx, fmt.Sprintf("could not hand over event %v of model with ID %v: %v", event.Key, event.ModelId, err.Error()))
+		}
+	}
+}
+
+// fillGap removes the ID from the gaps and reports whether it was in one.
+func (f *Follower) fillGap(id int) bool {
+	for i, g := range f.gaps {
+		if id < g.from || id > g.to {
+			continue
+		}
+		rest := make([]gap, 0, 2)
+		if id > g.from {
+			rest = append(rest, gap{from: g.from, to: id - 1, since: g.since})
+		}
+		if id < g.to {
+			rest = append(rest, gap{from: id + 1, to: g.to, since: g.since})
+		}
+		f.gaps = slices.Replace(f.gaps, i, i+1, rest...)
+		return true
+	}
+	return false
+}
+
+// closeGaps gives up the gaps that are older than GapTimeout, their transactions are assumed to be rolled back.
+func (f *Follower) closeGaps() {
+	now := f.now()
+	f.gaps = slices.DeleteFunc(f.gaps, func(g gap) bool {
+		return now.Sub(g.since) > f.GapTimeout
+	})
+}
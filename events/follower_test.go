@@ -0,0 +1,81 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gossie/modelling-service/domain"
+)
+
+// committedOutbox holds the committed events, the tests commit them in any order.
+type committedOutbox struct {
+	domain.OutboxRepository
+	events []domain.OutboxEvent
+}
+
+func (o *committedOutbox) commit(ids ...int) {
+	for _, id := range ids {
+		o.events = append(o.events, domain.OutboxEvent{Id: id, ModelId: 1, Kind: string(ParametersChanged), IdempotencyKey: fmt.Sprint(id)})
+	}
+	slices.SortFunc(o.events, func(a, b domain.OutboxEvent) int { return a.Id - b.Id })
+}
+
+func (o *committedOutbox) FindAfter(_ context.Context, eventId int, limit int) ([]domain.OutboxEvent, error) {
+	found := make([]domain.OutboxEvent, 0, limit)
+	for _, event := range o.events {
+		if event.Id > eventId && len(found) < limit {
+			found = append(found, event)
+		}
+	}
+	return found, nil
+}
+
+func (o *committedOutbox) LastId(context.Context) (int, error) {
+	if len(o.events) == 0 {
+		return 0, nil
+	}
+	return o.events[len(o.events)-1].Id, nil
+}
+
+func TestFollowerFollowsEventsThatCommitLate(t *testing.T) {
+	outbox := &committedOutbox{}
+	outbox.commit(1)
+	var followed []string
+	follower := NewFollower(outbox, func(_ context.Context, event Event) error {
+		followed = append(followed, event.Key)
+		return nil
+	})
+	follower.BatchSize = 2
+	now := time.Now()
+	follower.now = func() time.Time { return now }
+
+	followNew := func() {
+		t.Helper()
+		if err := follower.FollowNew(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	followNew()
+
+	outbox.commit(3, 6)
+	followNew()
+	outbox.commit(2, 7)
+	followNew()
+	outbox.commit(5)
+	followNew()
+	followNew()
+	if fmt.Sprint(followed) != "[3 6 2 7 5]" {
+		t.Errorf("expected every event to be followed once, got %v", followed)
+	}
+
+	now = now.Add(follower.GapTimeout + time.Second)
+	followNew()
+	outbox.commit(4)
+	followNew()
+	if fmt.Sprint(followed) != "[3 6 2 7 5]" {
+		t.Errorf("expected an event that commits after the gap timeout to be missed, got %v", followed)
+	}
+}
@@ -0,0 +1,211 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gossie/modelling-service/domain"
+)
+
+// Handler processes an event of the outbox. Events can reach a handler more than once, handlers use the key of
+// the event to recognize repetitions.
+type Handler func(context.Context, Event) error
+
+// RelayMetrics tells how far the relay lags behind the changes.
+type RelayMetrics struct {
+	// Pending is the number of events that were not published yet
+	Pending int
+	// Lag is the age of the oldest pending event, it is 0 if there are none
+	Lag time.Duration
+	// LastLag is the time the last published event waited in the outbox
+	LastLag   time.Duration
+	Published int64
+	Failures  int64
+}
+
+// Relay publishes the events of the outbox to its handlers. An event is marked as published after all handlers
+// succeeded, so a crash in between publishes it again (at-least-once). If a handler fails, the later events of the
+// same model wait for the next attempt, which keeps the order of the events per model. Only one relay may run per
+// database, it runs on the instance that holds the domain.Lock of the workers. Handlers that have to see the events on
+// every instance belong to a Follower.
+type Relay struct {
+	outbox   domain.OutboxRepository
+	handlers []Handler
+	wake     chan struct{}
+	now      func() time.Time
+
+	mu          sync.Mutex
+	metrics     RelayMetrics
+	lastCleanup time.Time
+
+	PollInterval time.Duration
+	BatchSize    int
+	// Retention is how long published events are kept
+	Retention time.Duration
+	// LagWarning is the lag from which on the relay logs warnings
+	LagWarning time.Duration
+}
+
+func NewRelay(outbox domain.OutboxRepository, handlers ...Handler) *Relay {
+	return &Relay{
+		outbox:       outbox,
+		handlers:     handlers,
+		wake:         make(chan struct{}, 1),
+		now:          time.Now,
+		PollInterval: time.Second,
+		BatchSize:    100,
+		Retention:    7 * 24 * time.Hour,
+		LagWarning:   time.Minute,
+	}
+}
+
+// Notify tells the relay that there are new events. It never blocks.
+func (r *Relay) Notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run publishes pending events until ctx is done. When ctx is done, the event that is being published is finished
+// and marked before Run returns.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		err := r.PublishPending(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.WarnContext(ctx, fmt.Sprintf("could not publish events of the outbox: %v", err.Error()))
+		}
+		r.updateMetrics(ctx)
+		r.cleanup(ctx)
+
+		select {
+		case <-ctx.Done():
+			slog.InfoContext(ctx, "stopped publishing events of the outbox")
+			return
+		case <-ticker.C:
+		case <-r.wake:
+		}
+	}
+}
+
+// PublishPending publishes the pending events in the order they were appended. It stops between two events when
+// ctx is done, an event whose publication started is still finished.
+func (r *Relay) PublishPending(ctx context.Context) error {
+	work := context.WithoutCancel(ctx)
+	// the events of blocked models wait for the next attempt, the query skips them so they do not hold up other models
+	blocked := make(map[int]bool)
+	for {
+		pending, err := r.outbox.FindPending(work, r.BatchSize, modelIds(blocked))
+		if err != nil {
+			return err
+		}
+
+		for _, outboxEvent := range pending {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if blocked[outboxEvent.ModelId] {
+				continue
+			}
+
+			event := Event{ModelId: outboxEvent.ModelId, Kind: Kind(outboxEvent.Kind), Key: outboxEvent.IdempotencyKey, OccurredAt: outboxEvent.CreatedAt}
+			err = r.handle(work, event)
+			if err != nil {
+				slog.WarnContext(ctx, fmt.Sprintf("could not publish event %v of model with ID %v, retrying later: %v", outboxEvent.IdempotencyKey, outboxEvent.ModelId, err.Error()))
+				blocked[outboxEvent.ModelId] = true
+				r.record(func(m *RelayMetrics) { m.Failures++ })
+				continue
+			}
+
+			publishedAt := r.now()
+			err = r.outbox.MarkPublished(work, outboxEvent.Id, publishedAt)
+			if err != nil {
+				return err
+			}
+			r.record(func(m *RelayMetrics) {
+				m.Published++
+				m.LastLag = publishedAt.Sub(outboxEvent.CreatedAt)
+			})
+		}
+
+		if len(pending) < r.BatchSize {
+			return nil
+		}
+	}
+}
+
+func modelIds(models map[int]bool) []int {
+	ids := make([]int, 0, len(models))
+	for modelId := range models {
+		ids = append(ids, modelId)
+	}
+	return ids
+}
+
+func (r *Relay) handle(ctx context.Context, event Event) error {
+	for _, handler := range r.handlers {
+		err := handler(ctx, event)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Metrics returns the metrics as of the last poll.
+func (r *Relay) Metrics() RelayMetrics {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.metrics
+}
+
+func (r *Relay) record(update func(*RelayMetrics)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	update(&r.metrics)
+}
+
+func (r *Relay) updateMetrics(ctx context.Context) {
+	pending, oldest, err := r.outbox.PendingStats(context.WithoutCancel(ctx))
+	if err != nil {
+		slog.WarnContext(ctx, fmt.Sprintf("could not determine the lag of the outbox: %v", err.Error()))
+		return
+	}
+
+	var lag time.Duration
+	if !oldest.IsZero() {
+		lag = r.now().Sub(oldest)
+	}
+	r.record(func(m *RelayMetrics) {
+		m.Pending = pending
+		m.Lag = lag
+	})
+
+	if lag > r.LagWarning {
+		slog.WarnContext(ctx, fmt.Sprintf("the outbox lags %v behind with %v pending events", lag.Round(time.Second), pending))
+	}
+}
+
+// cleanup deletes old published events about once an hour.
+func (r *Relay) cleanup(ctx context.Context) {
+	now := r.now()
+	if now.Sub(r.lastCleanup) < time.Hour {
+		return
+	}
+	r.lastCleanup = now
+
+	deleted, err := r.outbox.DeletePublished(context.WithoutCancel(ctx), now.Add(-r.Retention))
+	if err != nil {
+		slog.WarnContext(ctx, fmt.Sprintf("could not delete published events of the outbox: %v", err.Error()))
+		return
+	}
+	if deleted > 0 {
+		slog.InfoContext(ctx, fmt.Sprintf("deleted %v published events of the outbox", deleted))
+	}
+}
@@ -0,0 +1,279 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	configurationmodel "github.com/gossie/configuration-model"
+	"github.com/gossie/modelling-service/domain"
+	"github.com/gossie/modelling-service/middleware"
+	"github.com/gossie/modelling-service/persistence"
+)
+
+const owner = "owner@example.com"
+
+func inLanguage(language string) context.Context {
+	return context.WithValue(context.Background(), middleware.LanguageKey, language)
+}
+
+func saveModel(t *testing.T, repositories domain.Repositories, name string) int {
+	t.Helper()
+	modelId, err := repositories.Models.SaveModel(inLanguage("de"), owner, domain.ModelCreationRequest{Name: name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return modelId
+}
+
+func saveParameter(t *testing.T, repositories domain.Repositories, modelId int, name string) int {
+	t.Helper()
+	parameterId, err := repositories.Parameters.SaveParameter(inLanguage("de"), modelId, domain.ParameterCreationRequest{Name: name, ValueType: configurationmodel.StringSetType})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parameterId
+}
+
+func pending(t *testing.T, outbox domain.OutboxRepository) []string {
+	t.Helper()
+	events, err := outbox.FindPending(context.Background(), 100, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := make([]string, len(events))
+	for i, event := range events {
+		result[i] = fmt.Sprintf("%v:%v", event.ModelId, event.Kind)
+	}
+	return result
+}
+
+func TestChangesAreRecordedWithTheirEvents(t *testing.T) {
+	store := persistence.NewMemoryRepositories(owner)
+	notified := 0
+	repositories := Recording(store, func() { notified++ })
+	ctx := inLanguage("de")
+
+	modelId := saveModel(t, repositories, "car")
+	parameterId := saveParameter(t, repositories, modelId, "color")
+	if fmt.Sprint(pending(t, store.Outbox)) != fmt.Sprintf("[%v:parameters]", modelId) || notified != 1 {
+		t.Fatalf("expected an event of the new parameter and a notification, got %v and %v notifications", pending(t, store.Outbox), notified)
+	}
+
+	err := repositories.Parameters.DeleteParameter(ctx, modelId, parameterId, 42)
+	if !errors.Is(err, domain.ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+	if len(pending(t, store.Outbox)) != 1 || notified != 1 {
+		t.Errorf("expected a failed change not to record events, got %v", pending(t, store.Outbox))
+	}
+
+	err = repositories.UnitOfWork.Do(ctx, func(tx domain.Repositories) error {
+		_, err := tx.ParameterGroups.SaveGroup(ctx, modelId, domain.ParameterGroupCreationRequest{Name: "exterior"})
+		if err != nil {
+			return err
+		}
+		return errors.New("rolled back")
+	})
+	if err == nil {
+		t.Fatal("expected the unit of work to fail")
+	}
+	if len(pending(t, store.Outbox)) != 1 || notified != 1 {
+		t.Errorf("expected a rolled back unit of work not to record events, got %v", pending(t, store.Outbox))
+	}
+
+	err = repositories.UnitOfWork.Do(ctx, func(tx domain.Repositories) error {
+		_, err := tx.ParameterGroups.SaveGroup(ctx, modelId, domain.ParameterGroupCreationRequest{Name: "exterior"})
+		if err != nil {
+			return err
+		}
+		return tx.Parameters.DeleteParameter(ctx, modelId, parameterId, 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := fmt.Sprintf("[%[1]v:parameters %[1]v:parameters %[1]v:parameters %[1]v:constraints]", modelId)
+	if fmt.Sprint(pending(t, store.Outbox)) != expected || notified != 2 {
+		t.Errorf("expected the events of the unit of work and one notification, got %v and %v notifications", pending(t, store.Outbox), notified)
+	}
+}
+
+func TestRelayKeepsTheOrderPerModel(t *testing.T) {
+	store := persistence.NewMemoryRepositories(owner)
+	repositories := Recording(store, func() {})
+	car := saveModel(t, repositories, "car")
+	bike := saveModel(t, repositories, "bike")
+	ctx := context.Background()
+
+	for _, modelId := range []int{car, bike, car, bike} {
+		err := Append(ctx, repositories.Outbox, modelId, ParametersChanged)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	failCar := true
+	received := make([]string, 0)
+	relay := NewRelay(store.Outbox, func(ctx context.Context, event Event) error {
+		if event.ModelId == car && failCar {
+			return errors.New("receiver unavailable")
+		}
+		received = append(received, fmt.Sprintf("%v:%v", event.ModelId, event.Key != ""))
+		return nil
+	})
+
+	err := relay.PublishPending(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(received) != fmt.Sprintf("[%[1]v:true %[1]v:true]", bike) {
+		t.Fatalf("expected only the events of the bike, got %v", received)
+	}
+	if fmt.Sprint(pending(t, store.Outbox)) != fmt.Sprintf("[%[1]v:parameters %[1]v:parameters]", car) {
+		t.Errorf("expected the events of the car to stay pending, got %v", pending(t, store.Outbox))
+	}
+	if metrics := relay.Metrics(); metrics.Published != 2 || metrics.Failures != 1 {
+		t.Errorf("expected 2 published events and 1 failure, got %+v", metrics)
+	}
+
+	failCar = false
+	err = relay.PublishPending(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(received[2:]) != fmt.Sprintf("[%[1]v:true %[1]v:true]", car) || len(pending(t, store.Outbox)) != 0 {
+		t.Errorf("expected the events of the car to be published later, got %v", received)
+	}
+}
+
+func TestRelayRepeatsEventsThatWereNotMarked(t *testing.T) {
+	store := persistence.NewMemoryRepositories(owner)
+	modelId := saveModel(t, store, "car")
+	ctx := context.Background()
+
+	err := Append(ctx, store.Outbox, modelId, ConstraintsChanged)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := make([]string, 0)
+	handler := func(ctx context.Context, event Event) error {
+		keys = append(keys, event.Key)
+		return nil
+	}
+	failing := func(ctx context.Context, event Event) error {
+		return errors.New("crashed before marking the event")
+	}
+
+	_ = NewRelay(store.Outbox, handler, failing).PublishPending(ctx)
+	_ = NewRelay(store.Outbox, handler).PublishPending(ctx)
+	if len(keys) != 2 || keys[0] != keys[1] {
+		t.Errorf("expected the event to be published again with the same key, got %v", keys)
+	}
+}
+
+func TestRelayStopsWhenCanceled(t *testing.T) {
+	store := persistence.NewMemoryRepositories(owner)
+	modelId := saveModel(t, store, "car")
+	err := Append(context.Background(), store.Outbox, modelId, ParametersChanged)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	published := 0
+	relay := NewRelay(store.Outbox, func(ctx context.Context, event Event) error {
+		published++
+		return nil
+	})
+	relay.Run(ctx)
+
+	if published != 0 || len(pending(t, store.Outbox)) != 1 {
+		t.Errorf("expected a canceled relay not to publish, got %v published events", published)
+	}
+	if metrics := relay.Metrics(); metrics.Pending != 1 || metrics.Lag < 0 {
+		t.Errorf("expected the metrics to show the pending event, got %+v", metrics)
+	}
+}
+
+func TestFollowersSeeEveryEventOnEveryInstance(t *testing.T) {
+	store := persistence.NewMemoryRepositories(owner)
+	modelId := saveModel(t, store, "car")
+	ctx := context.Background()
+
+	err := Append(ctx, store.Outbox, modelId, ParametersChanged)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(map[string][]string)
+	follower := func(instance string) *Follower {
+		return NewFollower(store.Outbox, func(ctx context.Context, event Event) error {
+			received[instance] = append(received[instance], string(event.Kind))
+			return nil
+		})
+	}
+	first, second := follower("first"), follower("second")
+	for _, f := range []*Follower{first, second} {
+		if err = f.FollowNew(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, kind := range []Kind{ConstraintsChanged, ModelPublished} {
+		if err = Append(ctx, store.Outbox, modelId, kind); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the relay publishing the events does not hide them from the followers
+	if err = NewRelay(store.Outbox).PublishPending(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []*Follower{first, second, first} {
+		if err = f.FollowNew(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, instance := range []string{"first", "second"} {
+		if fmt.Sprint(received[instance]) != "[constraints published]" {
+			t.Errorf("expected %v to receive the events appended after it started once, got %v", instance, received[instance])
+		}
+	}
+}
+
+func TestABlockedModelDoesNotHoldUpOthers(t *testing.T) {
+	store := persistence.NewMemoryRepositories(owner)
+	car := saveModel(t, store, "car")
+	bike := saveModel(t, store, "bike")
+	ctx := context.Background()
+
+	// a whole batch of events of the car comes first
+	for _, modelId := range []int{car, car, car, bike} {
+		err := Append(ctx, store.Outbox, modelId, ParametersChanged)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	published := make([]int, 0)
+	relay := NewRelay(store.Outbox, func(ctx context.Context, event Event) error {
+		if event.ModelId == car {
+			return errors.New("receiver unavailable")
+		}
+		published = append(published, event.ModelId)
+		return nil
+	})
+	relay.BatchSize = 2
+
+	err := relay.PublishPending(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(published) != fmt.Sprint([]int{bike}) || len(pending(t, store.Outbox)) != 3 {
+		t.Errorf("expected the event of the bike to be published, got %v with %v pending", published, pending(t, store.Outbox))
+	}
+}
@@ -0,0 +1,217 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+
+	"github.com/gossie/modelling-service/domain"
+	"github.com/gossie/modelling-service/middleware"
+)
+
+// Recording decorates the repositories, so that every successful change appends events to the outbox in the same
+// transaction as the change itself. A change made outside a unit of work gets a unit of work of its own.
+// notify is called after events were committed, a Relay can use it to publish them right away.
+func Recording(repositories domain.Repositories, notify func()) domain.Repositories {
+	return decorate(repositories, &recorder{repositories: repositories, notify: notify})
+}
+
+// Append stores an event of the model in the outbox with a new idempotency key.
+func Append(ctx context.Context, outbox domain.OutboxRepository, modelId int, kind Kind) error {
+	key, err := newIdempotencyKey()
+	if err != nil {
+		return err
+	}
+	return outbox.Append(ctx, modelId, string(kind), key)
+}
+
+func newIdempotencyKey() (string, error) {
+	key := make([]byte, 16)
+	_, err := rand.Read(key)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// recorder runs changes together with appending their events.
+type recorder struct {
+	// repositories are not decorated, they are the ones of the unit of work if joined is set
+	repositories domain.Repositories
+	notify       func()
+	joined       bool
+}
+
+// record makes the change and appends events of the given kinds if it succeeds.
+func (r *recorder) record(ctx context.Context, modelId int, change func(domain.Repositories) error, kinds ...Kind) error {
+	if r.joined {
+		return r.changeAndAppend(ctx, r.repositories, modelId, change, kinds)
+	}
+
+	err := r.repositories.UnitOfWork.Do(ctx, func(repositories domain.Repositories) error {
+		return r.changeAndAppend(ctx, repositories, modelId, change, kinds)
+	})
+	if err == nil {
+		r.notify()
+	}
+	return err
+}
+
+func (r *recorder) changeAndAppend(ctx context.Context, repositories domain.Repositories, modelId int, change func(domain.Repositories) error, kinds []Kind) error {
+	err := change(repositories)
+	if err != nil {
+		return err
+	}
+
+	if modelId == 0 {
+		modelId, _ = ctx.Value(middleware.ModelIdKey).(int)
+	}
+	if modelId == 0 {
+		return nil
+	}
+
+	for _, kind := range kinds {
+		err = Append(ctx, repositories.Outbox, modelId, kind)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decorate(repositories domain.Repositories, r *recorder) domain.Repositories {
+	repositories.Parameters = &recordingParameterRepository{repositories.Parameters, r}
+	repositories.ParameterGroups = &recordingParameterGroupRepository{repositories.ParameterGroups, r}
+	repositories.Constraints = &recordingConstraintRepository{repositories.Constraints, r}
+	repositories.Outbox = &notifyingOutboxRepository{repositories.Outbox, r}
+	repositories.UnitOfWork = &recordingUnitOfWork{repositories.UnitOfWork, r}
+	return repositories
+}
+
+type recordingUnitOfWork struct {
+	domain.UnitOfWork
+	recorder *recorder
+}
+
+func (u *recordingUnitOfWork) Do(ctx context.Context, work func(domain.Repositories) error) error {
+	err := u.UnitOfWork.Do(ctx, func(repositories domain.Repositories) error {
+		// the surrounding unit of work notifies once it is committed
+		return work(decorate(repositories, &recorder{repositories: repositories, notify: func() {}, joined: true}))
+	})
+	if err == nil {
+		u.recorder.notify()
+	}
+	return err
+}
+
+// notifyingOutboxRepository notifies about events that are appended directly.
+type notifyingOutboxRepository struct {
+	domain.OutboxRepository
+	recorder *recorder
+}
+
+func (or *notifyingOutboxRepository) Append(ctx context.Context, modelId int, kind string, idempotencyKey string) error {
+	err := or.OutboxRepository.Append(ctx, modelId, kind, idempotencyKey)
+	if err == nil && !or.recorder.joined {
+		or.recorder.notify()
+	}
+	return err
+}
+
+type recordingParameterRepository struct {
+	domain.ParameterRepository
+	recorder *recorder
+}
+
+func (pr *recordingParameterRepository) SaveParameter(ctx context.Context, modelId int, pcr domain.ParameterCreationRequest) (int, error) {
+	var parameterId int
+	err := pr.recorder.record(ctx, modelId, func(repositories domain.Repositories) (err error) {
+		parameterId, err = repositories.Parameters.SaveParameter(ctx, modelId, pcr)
+		return err
+	}, ParametersChanged)
+	return parameterId, err
+}
+
+func (pr *recordingParameterRepository) DeleteParameter(ctx context.Context, modelId, parameterId, expectedVersion int) error {
+	// the constraints of the parameter are deleted along with it
+	return pr.recorder.record(ctx, modelId, func(repositories domain.Repositories) error {
+		return repositories.Parameters.DeleteParameter(ctx, modelId, parameterId, expectedVersion)
+	}, ParametersChanged, ConstraintsChanged)
+}
+
+func (pr *recordingParameterRepository) UpdateParameter(ctx context.Context, modelId, parameterId int, pmr domain.ParameterModificationRequest) error {
+	return pr.recorder.record(ctx, modelId, func(repositories domain.Repositories) error {
+		return repositories.Parameters.UpdateParameter(ctx, modelId, parameterId, pmr)
+	}, ParametersChanged)
+}
+
+func (pr *recordingParameterRepository) SaveTranslations(ctx context.Context, modelId, parameterId int, tmr domain.TranslationModificationRequest) error {
+	return pr.recorder.record(ctx, modelId, func(repositories domain.Repositories) error {
+		return repositories.Parameters.SaveTranslations(ctx, modelId, parameterId, tmr)
+	}, ParametersChanged)
+}
+
+func (pr *recordingParameterRepository) SaveValues(ctx context.Context, modelId, parameterId int, vmr domain.ValueModificationRequest) error {
+	kinds := []Kind{ParametersChanged}
+	if vmr.Cascade && len(vmr.DeletedValues) > 0 {
+		kinds = append(kinds, ConstraintsChanged)
+	}
+	return pr.recorder.record(ctx, modelId, func(repositories domain.Repositories) error {
+		return repositories.Parameters.SaveValues(ctx, modelId, parameterId, vmr)
+	}, kinds...)
+}
+
+type recordingParameterGroupRepository struct {
+	domain.ParameterGroupRepository
+	recorder *recorder
+}
+
+func (gr *recordingParameterGroupRepository) SaveGroup(ctx context.Context, modelId int, gcr domain.ParameterGroupCreationRequest) (int, error) {
+	var groupId int
+	err := gr.recorder.record(ctx, modelId, func(repositories domain.Repositories) (err error) {
+		groupId, err = repositories.ParameterGroups.SaveGroup(ctx, modelId, gcr)
+		return err
+	}, ParametersChanged)
+	return groupId, err
+}
+
+func (gr *recordingParameterGroupRepository) DeleteGroup(ctx context.Context, modelId, groupId int) error {
+	return gr.recorder.record(ctx, modelId, func(repositories domain.Repositories) error {
+		return repositories.ParameterGroups.DeleteGroup(ctx, modelId, groupId)
+	}, ParametersChanged)
+}
+
+func (gr *recordingParameterGroupRepository) SaveTranslations(ctx context.Context, modelId, groupId int, tmr domain.TranslationModificationRequest) error {
+	return gr.recorder.record(ctx, modelId, func(repositories domain.Repositories) error {
+		return repositories.ParameterGroups.SaveTranslations(ctx, modelId, groupId, tmr)
+	}, ParametersChanged)
+}
+
+func (gr *recordingParameterGroupRepository) SaveLayout(ctx context.Context, modelId int, layout []domain.ParameterLayout) error {
+	return gr.recorder.record(ctx, modelId, func(repositories domain.Repositories) error {
+		return repositories.ParameterGroups.SaveLayout(ctx, modelId, layout)
+	}, ParametersChanged)
+}
+
+type recordingConstraintRepository struct {
+	domain.ConstraintRepository
+	recorder *recorder
+}
+
+func (cr *recordingConstraintRepository) SaveConstraint(ctx context.Context, modelId string, ccr domain.ConstraintCreationRequest) (int, error) {
+	model, _ := strconv.Atoi(modelId)
+	var constraintId int
+	err := cr.recorder.record(ctx, model, func(repositories domain.Repositories) (err error) {
+		constraintId, err = repositories.Constraints.SaveConstraint(ctx, modelId, ccr)
+		return err
+	}, ConstraintsChanged)
+	return constraintId, err
+}
+
+func (cr *recordingConstraintRepository) DeleteConstraint(ctx context.Context, modelId, constraintId string, expectedVersion int) error {
+	model, _ := strconv.Atoi(modelId)
+	return cr.recorder.record(ctx, model, func(repositories domain.Repositories) error {
+		return repositories.Constraints.DeleteConstraint(ctx, modelId, constraintId, expectedVersion)
+	}, ConstraintsChanged)
+}
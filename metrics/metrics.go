@@ -0,0 +1,192 @@
+// Package metrics exposes the metrics of the service in the Prometheus exposition format.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gossie/modelling-service/domain"
+	"github.com/gossie/modelling-service/events"
+	"github.com/gossie/modelling-service/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "modelling"
+
+// statisticsTimeout bounds the queries of a scrape, a slow database should not block Prometheus.
+const statisticsTimeout = 5 * time.Second
+
+// Metrics keeps the collectors of one server. Every server has a registry of its own, so that several servers can
+// run in one process, like in the tests.
+type Metrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	responseSize    *prometheus.HistogramVec
+	queryDuration   *prometheus.HistogramVec
+	renderDuration  *prometheus.HistogramVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Number of handled requests by route, method and status code.",
+		}, []string{"route", "method", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time it took to handle a request by route and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		responseSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_response_size_bytes",
+			Help:      "Size of the response bodies by route and method.",
+			Buckets:   prometheus.ExponentialBuckets(100, 10, 6),
+		}, []string{"route", "method"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "repository_duration_seconds",
+			Help:      "Time a repository method took by repository, method and outcome.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"repository", "method", "outcome"}),
+		renderDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "template_render_duration_seconds",
+			Help:      "Time it took to render a view by template.",
+			Buckets:   []float64{.0001, .0005, .001, .0025, .005, .01, .025, .05, .1},
+		}, []string{"template"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.responseSize,
+		m.queryDuration,
+		m.renderDuration,
+	)
+	return m
+}
+
+// Handler serves the metrics to Prometheus.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// Instrument counts and times the requests of a route. The route is the pattern without the method, so that the
+// path values do not end up as labels.
+func (m *Metrics) Instrument(pattern string) middleware.Middleware {
+	route := pattern
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		route = path
+	}
+
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			recorder := middleware.RecordResponse(w)
+
+			next(recorder, r)
+
+			m.requests.WithLabelValues(route, r.Method, strconv.Itoa(recorder.Status())).Inc()
+			m.requestDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+			m.responseSize.WithLabelValues(route, r.Method).Observe(float64(recorder.Bytes()))
+		}
+	}
+}
+
+// ObserveRendering records the duration of rendering a view, see views.ObserveRendering.
+func (m *Metrics) ObserveRendering(layout string, d time.Duration) {
+	m.renderDuration.WithLabelValues(layout).Observe(d.Seconds())
+}
+
+func (m *Metrics) observeQuery(repository, method string, d time.Duration, err error) {
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	m.queryDuration.WithLabelValues(repository, method, outcome).Observe(d.Seconds())
+}
+
+// RegisterDatabase adds the statistics of the connection pool.
+func (m *Metrics) RegisterDatabase(db *sql.DB, name string) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterRelay adds the progress of the relay that publishes the events of the outbox.
+func (m *Metrics) RegisterRelay(relay *events.Relay) {
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "outbox_pending_events",
+			Help:      "Number of events in the outbox that were not published yet.",
+		}, func() float64 { return float64(relay.Metrics().Pending) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "outbox_lag_seconds",
+			Help:      "Age of the oldest event that was not published yet.",
+		}, func() float64 { return relay.Metrics().Lag.Seconds() }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "outbox_published_events_total",
+			Help:      "Number of events the relay published.",
+		}, func() float64 { return float64(relay.Metrics().Published) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "outbox_failures_total",
+			Help:      "Number of times a handler of the relay failed.",
+		}, func() float64 { return float64(relay.Metrics().Failures) }),
+	)
+}
+
+// RegisterModels adds the number of models and the size of every model. The numbers are queried on every scrape.
+func (m *Metrics) RegisterModels(models domain.ModelRepository) {
+	m.registry.MustRegister(&modelCollector{
+		models:      models,
+		count:       prometheus.NewDesc(namespace+"_models", "Number of models.", nil, nil),
+		parameters:  prometheus.NewDesc(namespace+"_model_parameters", "Number of parameters of a model.", []string{"model_id"}, nil),
+		constraints: prometheus.NewDesc(namespace+"_model_constraints", "Number of constraints of a model.", []string{"model_id"}, nil),
+	})
+}
+
+type modelCollector struct {
+	models      domain.ModelRepository
+	count       *prometheus.Desc
+	parameters  *prometheus.Desc
+	constraints *prometheus.Desc
+}
+
+func (mc *modelCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- mc.count
+	ch <- mc.parameters
+	ch <- mc.constraints
+}
+
+func (mc *modelCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), statisticsTimeout)
+	defer cancel()
+
+	statistics, err := mc.models.Statistics(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(mc.count, err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(mc.count, prometheus.GaugeValue, float64(len(statistics)))
+	for _, s := range statistics {
+		modelId := strconv.Itoa(s.ModelId)
+		ch <- prometheus.MustNewConstMetric(mc.parameters, prometheus.GaugeValue, float64(s.Parameters), modelId)
+		ch <- prometheus.MustNewConstMetric(mc.constraints, prometheus.GaugeValue, float64(s.Constraints), modelId)
+	}
+}
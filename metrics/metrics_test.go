@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gossie/modelling-service/domain"
+	"github.com/gossie/modelling-service/middleware"
+	"github.com/gossie/modelling-service/persistence"
+)
+
+const owner = "owner@example.com"
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(recorder.Body)
+	return string(body)
+}
+
+func expectLines(t *testing.T, exposition string, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if !strings.Contains(exposition, line+"\n") {
+			t.Errorf("expected the line %v in\n%v", line, exposition)
+		}
+	}
+}
+
+func TestRequestsAreCountedByRoute(t *testing.T) {
+	m := New()
+	handler := middleware.Chain(func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("modelId") == "2" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte("hello"))
+	}, m.Instrument("GET /models/{modelId}"))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /models/{modelId}", handler)
+	for _, path := range []string{"/models/1", "/models/1", "/models/2"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	m.ObserveRendering("model.html", time.Millisecond)
+
+	expectLines(t, scrape(t, m),
+		`modelling_http_requests_total{code="200",method="GET",route="/models/{modelId}"} 2`,
+		`modelling_http_requests_total{code="403",method="GET",route="/models/{modelId}"} 1`,
+		`modelling_http_request_duration_seconds_count{method="GET",route="/models/{modelId}"} 3`,
+		`modelling_http_response_size_bytes_sum{method="GET",route="/models/{modelId}"} 20`,
+		`modelling_template_render_duration_seconds_count{template="model.html"} 1`,
+	)
+}
+
+func TestRepositoriesAreTimedAndModelsCounted(t *testing.T) {
+	m := New()
+	repositories := Timed(persistence.NewMemoryRepositories(owner), m)
+	m.RegisterModels(repositories.Models)
+	ctx := context.WithValue(context.Background(), middleware.LanguageKey, "de")
+
+	var modelId int
+	err := repositories.UnitOfWork.Do(ctx, func(tx domain.Repositories) (err error) {
+		modelId, err = tx.Models.SaveModel(ctx, owner, domain.ModelCreationRequest{Name: "car"})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repositories.Parameters.SaveParameter(ctx, modelId, domain.ParameterCreationRequest{Name: "color"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = repositories.Models.FindById(ctx, modelId+1000)
+
+	expectLines(t, scrape(t, m),
+		`modelling_repository_duration_seconds_count{method="Do",outcome="ok",repository="unitOfWork"} 1`,
+		`modelling_repository_duration_seconds_count{method="SaveModel",outcome="ok",repository="models"} 1`,
+		`modelling_repository_duration_seconds_count{method="FindById",outcome="error",repository="models"} 1`,
+		`modelling_models 1`,
+		fmt.Sprintf(`modelling_model_parameters{model_id="%v"} 1`, modelId),
+		fmt.Sprintf(`modelling_model_constraints{model_id="%v"} 0`, modelId),
+	)
+}
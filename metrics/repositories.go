@@ -0,0 +1,404 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/gossie/modelling-service/domain"
+)
+
+// Timed decorates the repositories, so that the duration of every method call is recorded. The repositories of units
+// of work are timed as well.
+func Timed(repositories domain.Repositories, m *Metrics) domain.Repositories {
+	return domain.Repositories{
+		Users:           &timedUserRepository{repositories.Users, m},
+		Models:          &timedModelRepository{repositories.Models, m},
+		Parameters:      &timedParameterRepository{repositories.Parameters, m},
+		ParameterGroups: &timedParameterGroupRepository{repositories.ParameterGroups, m},
+		Constraints:     &timedConstraintRepository{repositories.Constraints, m},
+		Webhooks:        &timedWebhookRepository{repositories.Webhooks, m},
+		Outbox:          &timedOutboxRepository{repositories.Outbox, m},
+		Sessions:        &timedSessionRepository{repositories.Sessions, m},
+		ApiTokens:       &timedApiTokenRepository{repositories.ApiTokens, m},
+		UnitOfWork:      &timedUnitOfWork{repositories.UnitOfWork, m},
+	}
+}
+
+func timed[T any](m *Metrics, repository, method string, call func() (T, error)) (T, error) {
+	start := time.Now()
+	result, err := call()
+	m.observeQuery(repository, method, time.Since(start), err)
+	return result, err
+}
+
+func timedError(m *Metrics, repository, method string, call func() error) error {
+	start := time.Now()
+	err := call()
+	m.observeQuery(repository, method, time.Since(start), err)
+	return err
+}
+
+type timedUnitOfWork struct {
+	unitOfWork domain.UnitOfWork
+	metrics    *Metrics
+}
+
+func (u *timedUnitOfWork) Do(ctx context.Context, work func(domain.Repositories) error) error {
+	return timedError(u.metrics, "unitOfWork", "Do", func() error {
+		return u.unitOfWork.Do(ctx, func(repositories domain.Repositories) error {
+			return work(Timed(repositories, u.metrics))
+		})
+	})
+}
+
+type timedUserRepository struct {
+	repository domain.UserRepository
+	metrics    *Metrics
+}
+
+func (ur *timedUserRepository) FindByEmail(ctx context.Context, email string) (domain.User, error) {
+	return timed(ur.metrics, "users", "FindByEmail", func() (domain.User, error) {
+		return ur.repository.FindByEmail(ctx, email)
+	})
+}
+
+func (ur *timedUserRepository) SaveUser(ctx context.Context, email string) (domain.User, error) {
+	return timed(ur.metrics, "users", "SaveUser", func() (domain.User, error) {
+		return ur.repository.SaveUser(ctx, email)
+	})
+}
+
+type timedModelRepository struct {
+	repository domain.ModelRepository
+	metrics    *Metrics
+}
+
+func (mr *timedModelRepository) FindById(ctx context.Context, modelId int) (domain.Model, error) {
+	return timed(mr.metrics, "models", "FindById", func() (domain.Model, error) {
+		return mr.repository.FindById(ctx, modelId)
+	})
+}
+
+func (mr *timedModelRepository) HasAccess(ctx context.Context, modelId int, userEmail string) (bool, error) {
+	return timed(mr.metrics, "models", "HasAccess", func() (bool, error) {
+		return mr.repository.HasAccess(ctx, modelId, userEmail)
+	})
+}
+
+func (mr *timedModelRepository) FindAllByUser(ctx context.Context, userEmail string) ([]domain.Model, error) {
+	return timed(mr.metrics, "models", "FindAllByUser", func() ([]domain.Model, error) {
+		return mr.repository.FindAllByUser(ctx, userEmail)
+	})
+}
+
+func (mr *timedModelRepository) FindPageByUser(ctx context.Context, userEmail string, filter domain.ModelFilter, pr domain.PageRequest) (domain.Page[domain.Model], error) {
+	return timed(mr.metrics, "models", "FindPageByUser", func() (domain.Page[domain.Model], error) {
+		return mr.repository.FindPageByUser(ctx, userEmail, filter, pr)
+	})
+}
+
+func (mr *timedModelRepository) SaveModel(ctx context.Context, userEmail string, cmr domain.ModelCreationRequest) (int, error) {
+	return timed(mr.metrics, "models", "SaveModel", func() (int, error) {
+		return mr.repository.SaveModel(ctx, userEmail, cmr)
+	})
+}
+
+func (mr *timedModelRepository) SyncGroupGrants(ctx context.Context, userEmail string, modelIds []int) ([]int, error) {
+	return timed(mr.metrics, "models", "SyncGroupGrants", func() ([]int, error) {
+		return mr.repository.SyncGroupGrants(ctx, userEmail, modelIds)
+	})
+}
+
+func (mr *timedModelRepository) Statistics(ctx context.Context) ([]domain.ModelStatistics, error) {
+	return timed(mr.metrics, "models", "Statistics", func() ([]domain.ModelStatistics, error) {
+		return mr.repository.Statistics(ctx)
+	})
+}
+
+type timedParameterRepository struct {
+	repository domain.ParameterRepository
+	metrics    *Metrics
+}
+
+func (pr *timedParameterRepository) FindAllByModelId(ctx context.Context, modelId int, searchValue string) ([]domain.Parameter, error) {
+	return timed(pr.metrics, "parameters", "FindAllByModelId", func() ([]domain.Parameter, error) {
+		return pr.repository.FindAllByModelId(ctx, modelId, searchValue)
+	})
+}
+
+func (pr *timedParameterRepository) FindPageByModelId(ctx context.Context, modelId int, filter domain.ParameterFilter, page domain.PageRequest) (domain.Page[domain.Parameter], error) {
+	return timed(pr.metrics, "parameters", "FindPageByModelId", func() (domain.Page[domain.Parameter], error) {
+		return pr.repository.FindPageByModelId(ctx, modelId, filter, page)
+	})
+}
+
+func (pr *timedParameterRepository) FindById(ctx context.Context, modelId, parameterId int) (domain.Parameter, error) {
+	return timed(pr.metrics, "parameters", "FindById", func() (domain.Parameter, error) {
+		return pr.repository.FindById(ctx, modelId, parameterId)
+	})
+}
+
+func (pr *timedParameterRepository) SaveParameter(ctx context.Context, modelId int, pcr domain.ParameterCreationRequest) (int, error) {
+	return timed(pr.metrics, "parameters", "SaveParameter", func() (int, error) {
+		return pr.repository.SaveParameter(ctx, modelId, pcr)
+	})
+}
+
+func (pr *timedParameterRepository) DeleteParameter(ctx context.Context, modelId, parameterId, expectedVersion int) error {
+	return timedError(pr.metrics, "parameters", "DeleteParameter", func() error {
+		return pr.repository.DeleteParameter(ctx, modelId, parameterId, expectedVersion)
+	})
+}
+
+func (pr *timedParameterRepository) UpdateParameter(ctx context.Context, modelId, parameterId int, pmr domain.ParameterModificationRequest) error {
+	return timedError(pr.metrics, "parameters", "UpdateParameter", func() error {
+		return pr.repository.UpdateParameter(ctx, modelId, parameterId, pmr)
+	})
+}
+
+func (pr *timedParameterRepository) FindAllTranslations(ctx context.Context, modelId, parameterId int) ([]domain.Translation, error) {
+	return timed(pr.metrics, "parameters", "FindAllTranslations", func() ([]domain.Translation, error) {
+		return pr.repository.FindAllTranslations(ctx, modelId, parameterId)
+	})
+}
+
+func (pr *timedParameterRepository) SaveTranslations(ctx context.Context, modelId, parameterId int, tmr domain.TranslationModificationRequest) error {
+	return timedError(pr.metrics, "parameters", "SaveTranslations", func() error {
+		return pr.repository.SaveTranslations(ctx, modelId, parameterId, tmr)
+	})
+}
+
+func (pr *timedParameterRepository) SaveValues(ctx context.Context, modelId, parameterId int, vmr domain.ValueModificationRequest) error {
+	return timedError(pr.metrics, "parameters", "SaveValues", func() error {
+		return pr.repository.SaveValues(ctx, modelId, parameterId, vmr)
+	})
+}
+
+type timedParameterGroupRepository struct {
+	repository domain.ParameterGroupRepository
+	metrics    *Metrics
+}
+
+func (gr *timedParameterGroupRepository) FindAllByModelId(ctx context.Context, modelId int) ([]domain.ParameterGroup, error) {
+	return timed(gr.metrics, "parameterGroups", "FindAllByModelId", func() ([]domain.ParameterGroup, error) {
+		return gr.repository.FindAllByModelId(ctx, modelId)
+	})
+}
+
+func (gr *timedParameterGroupRepository) SaveGroup(ctx context.Context, modelId int, gcr domain.ParameterGroupCreationRequest) (int, error) {
+	return timed(gr.metrics, "parameterGroups", "SaveGroup", func() (int, error) {
+		return gr.repository.SaveGroup(ctx, modelId, gcr)
+	})
+}
+
+func (gr *timedParameterGroupRepository) DeleteGroup(ctx context.Context, modelId, groupId int) error {
+	return timedError(gr.metrics, "parameterGroups", "DeleteGroup", func() error {
+		return gr.repository.DeleteGroup(ctx, modelId, groupId)
+	})
+}
+
+func (gr *timedParameterGroupRepository) FindAllTranslations(ctx context.Context, modelId, groupId int) ([]domain.Translation, error) {
+	return timed(gr.metrics, "parameterGroups", "FindAllTranslations", func() ([]domain.Translation, error) {
+		return gr.repository.FindAllTranslations(ctx, modelId, groupId)
+	})
+}
+
+func (gr *timedParameterGroupRepository) SaveTranslations(ctx context.Context, modelId, groupId int, tmr domain.TranslationModificationRequest) error {
+	return timedError(gr.metrics, "parameterGroups", "SaveTranslations", func() error {
+		return gr.repository.SaveTranslations(ctx, modelId, groupId, tmr)
+	})
+}
+
+func (gr *timedParameterGroupRepository) SaveLayout(ctx context.Context, modelId int, layout []domain.ParameterLayout) error {
+	return timedError(gr.metrics, "parameterGroups", "SaveLayout", func() error {
+		return gr.repository.SaveLayout(ctx, modelId, layout)
+	})
+}
+
+type timedConstraintRepository struct {
+	repository domain.ConstraintRepository
+	metrics    *Metrics
+}
+
+func (cr *timedConstraintRepository) SaveConstraint(ctx context.Context, modelId string, ccr domain.ConstraintCreationRequest) (int, error) {
+	return timed(cr.metrics, "constraints", "SaveConstraint", func() (int, error) {
+		return cr.repository.SaveConstraint(ctx, modelId, ccr)
+	})
+}
+
+func (cr *timedConstraintRepository) DeleteConstraint(ctx context.Context, modelId, constraintId string, expectedVersion int) error {
+	return timedError(cr.metrics, "constraints", "DeleteConstraint", func() error {
+		return cr.repository.DeleteConstraint(ctx, modelId, constraintId, expectedVersion)
+	})
+}
+
+type timedWebhookRepository struct {
+	repository domain.WebhookRepository
+	metrics    *Metrics
+}
+
+func (wr *timedWebhookRepository) FindAllByModelId(ctx context.Context, modelId int) ([]domain.Webhook, error) {
+	return timed(wr.metrics, "webhooks", "FindAllByModelId", func() ([]domain.Webhook, error) {
+		return wr.repository.FindAllByModelId(ctx, modelId)
+	})
+}
+
+func (wr *timedWebhookRepository) SaveWebhook(ctx context.Context, modelId int, wcr domain.WebhookCreationRequest) (int, error) {
+	return timed(wr.metrics, "webhooks", "SaveWebhook", func() (int, error) {
+		return wr.repository.SaveWebhook(ctx, modelId, wcr)
+	})
+}
+
+func (wr *timedWebhookRepository) DeleteWebhook(ctx context.Context, modelId, webhookId int) error {
+	return timedError(wr.metrics, "webhooks", "DeleteWebhook", func() error {
+		return wr.repository.DeleteWebhook(ctx, modelId, webhookId)
+	})
+}
+
+func (wr *timedWebhookRepository) EnqueueDeliveries(ctx context.Context, modelId int, idempotencyKey string, event string, payload string) error {
+	return timedError(wr.metrics, "webhooks", "EnqueueDeliveries", func() error {
+		return wr.repository.EnqueueDeliveries(ctx, modelId, idempotencyKey, event, payload)
+	})
+}
+
+func (wr *timedWebhookRepository) FindDueDeliveries(ctx context.Context, now time.Time, limit int) ([]domain.DueDelivery, error) {
+	return timed(wr.metrics, "webhooks", "FindDueDeliveries", func() ([]domain.DueDelivery, error) {
+		return wr.repository.FindDueDeliveries(ctx, now, limit)
+	})
+}
+
+func (wr *timedWebhookRepository) UpdateDelivery(ctx context.Context, delivery domain.WebhookDelivery) error {
+	return timedError(wr.metrics, "webhooks", "UpdateDelivery", func() error {
+		return wr.repository.UpdateDelivery(ctx, delivery)
+	})
+}
+
+func (wr *timedWebhookRepository) FindDeliveriesByModelId(ctx context.Context, modelId int, limit int) ([]domain.WebhookDelivery, error) {
+	return timed(wr.metrics, "webhooks", "FindDeliveriesByModelId", func() ([]domain.WebhookDelivery, error) {
+		return wr.repository.FindDeliveriesByModelId(ctx, modelId, limit)
+	})
+}
+
+func (wr *timedWebhookRepository) RetryDelivery(ctx context.Context, modelId int, deliveryId int) error {
+	return timedError(wr.metrics, "webhooks", "RetryDelivery", func() error {
+		return wr.repository.RetryDelivery(ctx, modelId, deliveryId)
+	})
+}
+
+type timedOutboxRepository struct {
+	repository domain.OutboxRepository
+	metrics    *Metrics
+}
+
+func (or *timedOutboxRepository) Append(ctx context.Context, modelId int, kind string, idempotencyKey string) error {
+	return timedError(or.metrics, "outbox", "Append", func() error {
+		return or.repository.Append(ctx, modelId, kind, idempotencyKey)
+	})
+}
+
+func (or *timedOutboxRepository) FindPending(ctx context.Context, limit int, excludedModelIds []int) ([]domain.OutboxEvent, error) {
+	return timed(or.metrics, "outbox", "FindPending", func() ([]domain.OutboxEvent, error) {
+		return or.repository.FindPending(ctx, limit, excludedModelIds)
+	})
+}
+
+func (or *timedOutboxRepository) MarkPublished(ctx context.Context, eventId int, publishedAt time.Time) error {
+	return timedError(or.metrics, "outbox", "MarkPublished", func() error {
+		return or.repository.MarkPublished(ctx, eventId, publishedAt)
+	})
+}
+
+func (or *timedOutboxRepository) FindAfter(ctx context.Context, eventId int, limit int) ([]domain.OutboxEvent, error) {
+	return timed(or.metrics, "outbox", "FindAfter", func() ([]domain.OutboxEvent, error) {
+		return or.repository.FindAfter(ctx, eventId, limit)
+	})
+}
+
+func (or *timedOutboxRepository) LastId(ctx context.Context) (int, error) {
+	return timed(or.metrics, "outbox", "LastId", func() (int, error) {
+		return or.repository.LastId(ctx)
+	})
+}
+
+func (or *timedOutboxRepository) PendingStats(ctx context.Context) (int, time.Time, error) {
+	start := time.Now()
+	pending, oldest, err := or.repository.PendingStats(ctx)
+	or.metrics.observeQuery("outbox", "PendingStats", time.Since(start), err)
+	return pending, oldest, err
+}
+
+func (or *timedOutboxRepository) DeletePublished(ctx context.Context, before time.Time) (int, error) {
+	return timed(or.metrics, "outbox", "DeletePublished", func() (int, error) {
+		return or.repository.DeletePublished(ctx, before)
+	})
+}
+
+type timedSessionRepository struct {
+	repository domain.SessionRepository
+	metrics    *Metrics
+}
+
+func (sr *timedSessionRepository) SaveSession(ctx context.Context, userEmail string, refreshTokenHash string, expiresAt time.Time) (int, error) {
+	return timed(sr.metrics, "sessions", "SaveSession", func() (int, error) {
+		return sr.repository.SaveSession(ctx, userEmail, refreshTokenHash, expiresAt)
+	})
+}
+
+func (sr *timedSessionRepository) FindSession(ctx context.Context, sessionId int) (domain.Session, error) {
+	return timed(sr.metrics, "sessions", "FindSession", func() (domain.Session, error) {
+		return sr.repository.FindSession(ctx, sessionId)
+	})
+}
+
+func (sr *timedSessionRepository) RotateRefreshToken(ctx context.Context, sessionId int, expectedHash string, newHash string, rotatedAt time.Time, expiresAt time.Time) error {
+	return timedError(sr.metrics, "sessions", "RotateRefreshToken", func() error {
+		return sr.repository.RotateRefreshToken(ctx, sessionId, expectedHash, newHash, rotatedAt, expiresAt)
+	})
+}
+
+func (sr *timedSessionRepository) RevokeSession(ctx context.Context, sessionId int, revokedAt time.Time) error {
+	return timedError(sr.metrics, "sessions", "RevokeSession", func() error {
+		return sr.repository.RevokeSession(ctx, sessionId, revokedAt)
+	})
+}
+
+func (sr *timedSessionRepository) DeleteExpiredSessions(ctx context.Context, before time.Time) (int, error) {
+	return timed(sr.metrics, "sessions", "DeleteExpiredSessions", func() (int, error) {
+		return sr.repository.DeleteExpiredSessions(ctx, before)
+	})
+}
+
+type timedApiTokenRepository struct {
+	repository domain.ApiTokenRepository
+	metrics    *Metrics
+}
+
+func (tr *timedApiTokenRepository) SaveApiToken(ctx context.Context, userEmail string, name string, scopes []string, tokenHash string, expiresAt time.Time) (int, error) {
+	return timed(tr.metrics, "apiTokens", "SaveApiToken", func() (int, error) {
+		return tr.repository.SaveApiToken(ctx, userEmail, name, scopes, tokenHash, expiresAt)
+	})
+}
+
+func (tr *timedApiTokenRepository) FindApiToken(ctx context.Context, tokenId int) (domain.ApiToken, error) {
+	return timed(tr.metrics, "apiTokens", "FindApiToken", func() (domain.ApiToken, error) {
+		return tr.repository.FindApiToken(ctx, tokenId)
+	})
+}
+
+func (tr *timedApiTokenRepository) FindApiTokensByUser(ctx context.Context, userEmail string) ([]domain.ApiToken, error) {
+	return timed(tr.metrics, "apiTokens", "FindApiTokensByUser", func() ([]domain.ApiToken, error) {
+		return tr.repository.FindApiTokensByUser(ctx, userEmail)
+	})
+}
+
+func (tr *timedApiTokenRepository) RecordApiTokenUse(ctx context.Context, tokenId int, usedAt time.Time, usedFrom string) error {
+	return timedError(tr.metrics, "apiTokens", "RecordApiTokenUse", func() error {
+		return tr.repository.RecordApiTokenUse(ctx, tokenId, usedAt, usedFrom)
+	})
+}
+
+func (tr *timedApiTokenRepository) RevokeApiToken(ctx context.Context, userEmail string, tokenId int, revokedAt time.Time) error {
+	return timedError(tr.metrics, "apiTokens", "RevokeApiToken", func() error {
+		return tr.repository.RevokeApiToken(ctx, userEmail, tokenId, revokedAt)
+	})
+}
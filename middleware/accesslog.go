@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gossie/modelling-service/config"
+)
+
+type accessLogKey string
+
+const accessLogEntryKey = accessLogKey("accessLogEntry")
+
+// accessLogEntry collects what the middleware further down learns about the request, like the user.
+type accessLogEntry struct {
+	user string
+}
+
+// AccessLog logs one line per request after the response was written.
+func AccessLog(cfg config.AccessLog) Middleware {
+	var level slog.Level
+	_ = level.UnmarshalText([]byte(cfg.Level))
+
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		if !cfg.Enabled {
+			return next
+		}
+
+		return func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			recorder := RecordResponse(w)
+			entry := &accessLogEntry{}
+
+			next(recorder, r.WithContext(context.WithValue(r.Context(), accessLogEntryKey, entry)))
+
+			status := recorder.Status()
+			lineLevel := level
+			switch {
+			case status >= http.StatusInternalServerError:
+				lineLevel = slog.LevelError
+			case status >= http.StatusBadRequest:
+				lineLevel = slog.LevelWarn
+			case cfg.SampleRatio < 1 && rand.Float64() >= cfg.SampleRatio:
+				return
+			}
+
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", status),
+				slog.Int("bytes", recorder.Bytes()),
+				slog.Float64("durationMs", float64(time.Since(start).Microseconds())/1000),
+				slog.String("remoteAddr", r.RemoteAddr),
+				slog.String("userAgent", r.UserAgent()),
+			}
+			if entry.user != "" {
+				attrs = append(attrs, slog.String("user", entry.user))
+			}
+			if modelId := r.PathValue("modelId"); modelId != "" {
+				attrs = append(attrs, slog.String("modelId", modelId))
+			}
+			if headers := loggedHeaders(r.Header, cfg); len(headers) > 0 {
+				attrs = append(attrs, slog.Attr{Key: "headers", Value: slog.GroupValue(headers...)})
+			}
+			slog.LogAttrs(r.Context(), lineLevel, "request", attrs...)
+		}
+	}
+}
+
+// loggedHeaders returns the configured headers of the request, "*" stands for all of them.
+func loggedHeaders(header http.Header, cfg config.AccessLog) []slog.Attr {
+	names := cfg.Headers
+	if slices.Contains(names, "*") {
+		names = make([]string, 0, len(header))
+		for name := range header {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+	}
+
+	attrs := make([]slog.Attr, 0)
+	for _, name := range names {
+		value := header.Get(name)
+		if value == "" {
+			continue
+		}
+		if slices.ContainsFunc(cfg.RedactedHeaders, func(redacted string) bool { return strings.EqualFold(redacted, name) }) {
+			value = "REDACTED"
+		}
+		attrs = append(attrs, slog.String(name, value))
+	}
+	return attrs
+}
+
+// logUser adds the user to the access log line of the request.
+func logUser(ctx context.Context, user string) {
+	if entry, ok := ctx.Value(accessLogEntryKey).(*accessLogEntry); ok {
+		entry.user = user
+	}
+}
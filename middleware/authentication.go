@@ -2,44 +2,47 @@ package middleware
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/gossie/modelling-service/domain"
 )
 
 type userIdentifier string
 
 const UserIdentifierKey = userIdentifier("userIdentifier")
 
-func AuthenticatedRequest(secret string, next http.HandlerFunc) http.HandlerFunc {
+// Authenticator identifies the user of a request. It may renew the credentials of the user on the response. It returns
+// domain.ErrUnauthenticated if the request has no valid credentials and domain.ErrForbidden if the credentials do not
+// allow the request.
+type Authenticator interface {
+	Authenticate(w http.ResponseWriter, r *http.Request) (string, error)
+}
+
+// AuthenticatedRequest puts the user into the context of the request, requests without valid credentials get a 401 and
+// requests that the credentials do not allow a 403.
+func AuthenticatedRequest(authenticator Authenticator, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie("accessToken")
-		if err != nil {
-			slog.InfoContext(r.Context(), "no access token")
+		subject, err := authenticator.Authenticate(w, r)
+		switch {
+		case errors.Is(err, domain.ErrUnauthenticated):
+			slog.InfoContext(r.Context(), fmt.Sprintf("request is not authenticated: %v", err.Error()))
 			w.WriteHeader(http.StatusUnauthorized)
 			return
+		case errors.Is(err, domain.ErrForbidden):
+			slog.InfoContext(r.Context(), fmt.Sprintf("request is not allowed: %v", err.Error()))
+			w.WriteHeader(http.StatusForbidden)
+			return
 		}
-		token := verifyToken(cookie.Value, secret)
-		if token == nil || !token.Valid {
-			slog.InfoContext(r.Context(), "token is not valid")
-			w.WriteHeader(http.StatusUnauthorized)
+		if err != nil {
+			slog.ErrorContext(r.Context(), fmt.Sprintf("could not authenticate the request: %v", err.Error()))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		subject, _ := token.Claims.GetSubject() // TODO: handle err
+		logUser(r.Context(), subject)
 		next(w, r.WithContext(context.WithValue(r.Context(), UserIdentifierKey, subject)))
 	}
 }
-
-func verifyToken(tokenStr, secret string) *jwt.Token {
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secret), nil
-	})
-
-	if err != nil {
-		return nil
-	}
-
-	return token
-}
@@ -1,35 +1,42 @@
 package middleware
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
+
+	"github.com/gossie/modelling-service/domain"
 )
 
-func Authorized(db *sql.DB, next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		modelId := r.PathValue("modelId")
-		email := r.Context().Value(UserIdentifierKey)
+type modelIdentifier string
 
-		sqlStatement := `
-			SELECT COUNT(*)
-			FROM model_user_relations
-			WHERE modelId = $1 AND userId = (SELECT id FROM users WHERE email = $2)`
+// ModelIdKey holds the ID of the model that the request was authorized for.
+const ModelIdKey = modelIdentifier("modelId")
+
+func Authorized(modelRepository domain.ModelRepository, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		modelId, err := strconv.Atoi(r.PathValue("modelId"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		email, _ := r.Context().Value(UserIdentifierKey).(string)
 
-		var count int
-		err := db.QueryRowContext(r.Context(), sqlStatement, modelId, email).Scan(&count)
+		hasAccess, err := modelRepository.HasAccess(r.Context(), modelId, email)
 		if err != nil {
 			slog.WarnContext(r.Context(), fmt.Sprintf("error error checking if user is authorized for model ID %v: %v", modelId, err.Error()))
 			http.Error(w, err.Error(), 500)
 			return
 		}
 
-		if count == 0 {
+		if !hasAccess {
 			slog.InfoContext(r.Context(), fmt.Sprintf("user is not authorized for model ID %v", modelId))
 			w.WriteHeader(http.StatusForbidden)
+			return
 		}
 
-		next(w, r)
+		next(w, r.WithContext(context.WithValue(r.Context(), ModelIdKey, modelId)))
 	}
 }
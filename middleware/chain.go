@@ -0,0 +1,14 @@
+package middleware
+
+import "net/http"
+
+// Middleware wraps a handler with behaviour that runs before or after it.
+type Middleware func(next http.HandlerFunc) http.HandlerFunc
+
+// Chain wraps the handler with the middleware. The first middleware is the outermost, it sees the request first.
+func Chain(handler http.HandlerFunc, middleware ...Middleware) http.HandlerFunc {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
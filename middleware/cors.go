@@ -1,13 +1,44 @@
 package middleware
 
-import "net/http"
+import (
+	"net/http"
+	"slices"
+)
 
-func enableCors(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization")
+// Cors allows browsers on the allowed origins to call the server. "*" allows every origin, but without the cookie, only
+// listed origins may send it. Cors answers preflight requests itself, so it has to wrap the whole server and not single
+// routes. Without allowed origins it does nothing.
+func Cors(allowedOrigins []string) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		if len(allowedOrigins) == 0 {
+			return next
+		}
 
-		next(w, r)
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Origin")
+			origin := r.Header.Get("Origin")
+			switch {
+			case origin == "":
+				next(w, r)
+				return
+			case slices.Contains(allowedOrigins, origin):
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			case slices.Contains(allowedOrigins, "*"):
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			default:
+				next(w, r)
+				return
+			}
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, If-Match, HX-Request, HX-Target, HX-Current-URL, HX-Trigger")
+				w.Header().Set("Access-Control-Max-Age", "600")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			next(w, r)
+		}
 	}
 }
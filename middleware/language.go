@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"slices"
+)
+
+type language string
+
+const LanguageKey = language("language")
+
+// WithLanguage puts the language of the request into the context. Requests ask for a language with the query parameter
+// lang, requests that ask for none or for an unsupported one get the default language.
+func WithLanguage(defaultLanguage string, supported []string) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			lang := r.URL.Query().Get("lang")
+			if !slices.Contains(supported, lang) {
+				lang = defaultLanguage
+			}
+
+			next(w, r.WithContext(context.WithValue(r.Context(), LanguageKey, lang)))
+		}
+	}
+}
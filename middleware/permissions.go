@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gossie/modelling-service/domain"
+)
+
+// maxCachedPermissions bounds the memory of the cache, users that probe many model IDs should not fill it up.
+const maxCachedPermissions = 10_000
+
+type permissionKey struct {
+	modelId int
+	email   string
+}
+
+type permission struct {
+	hasAccess bool
+	expiresAt time.Time
+}
+
+// PermissionCache is a model repository that remembers for a while whether users may access models, so that
+// Authorized does not query the database on every request. Changes of the users of a model through the cache,
+// like creating the model, invalidate the permissions of that model right away. Changes through the repositories
+// of a unit of work bypass the cache and become visible once the permissions expire.
+type PermissionCache struct {
+	domain.ModelRepository
+	ttl time.Duration
+	now func() time.Time
+
+	mu          sync.Mutex
+	permissions map[permissionKey]permission
+}
+
+// NewPermissionCache keeps permissions for ttl, a ttl of 0 disables the cache.
+func NewPermissionCache(models domain.ModelRepository, ttl time.Duration) *PermissionCache {
+	return &PermissionCache{
+		ModelRepository: models,
+		ttl:             ttl,
+		now:             time.Now,
+		permissions:     make(map[permissionKey]permission),
+	}
+}
+
+func (pc *PermissionCache) HasAccess(ctx context.Context, modelId int, userEmail string) (bool, error) {
+	if pc.ttl <= 0 {
+		return pc.ModelRepository.HasAccess(ctx, modelId, userEmail)
+	}
+
+	key := permissionKey{modelId, userEmail}
+	pc.mu.Lock()
+	cached, ok := pc.permissions[key]
+	pc.mu.Unlock()
+	if ok && pc.now().Before(cached.expiresAt) {
+		return cached.hasAccess, nil
+	}
+
+	hasAccess, err := pc.ModelRepository.HasAccess(ctx, modelId, userEmail)
+	if err != nil {
+		return false, err
+	}
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if len(pc.permissions) >= maxCachedPermissions {
+		pc.removeExpired()
+	}
+	if len(pc.permissions) < maxCachedPermissions {
+		pc.permissions[key] = permission{hasAccess, pc.now().Add(pc.ttl)}
+	}
+	return hasAccess, nil
+}
+
+func (pc *PermissionCache) SaveModel(ctx context.Context, userEmail string, cmr domain.ModelCreationRequest) (int, error) {
+	modelId, err := pc.ModelRepository.SaveModel(ctx, userEmail, cmr)
+	if err == nil {
+		pc.Invalidate(modelId)
+	}
+	return modelId, err
+}
+
+func (pc *PermissionCache) SyncGroupGrants(ctx context.Context, userEmail string, modelIds []int) ([]int, error) {
+	changed, err := pc.ModelRepository.SyncGroupGrants(ctx, userEmail, modelIds)
+	for _, modelId := range changed {
+		pc.Invalidate(modelId)
+	}
+	return changed, err
+}
+
+// Invalidate forgets the permissions of the model, it has to be called when the users of the model change.
+func (pc *PermissionCache) Invalidate(modelId int) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	for key := range pc.permissions {
+		if key.modelId == modelId {
+			delete(pc.permissions, key)
+		}
+	}
+}
+
+func (pc *PermissionCache) removeExpired() {
+	now := pc.now()
+	for key, cached := range pc.permissions {
+		if !now.Before(cached.expiresAt) {
+			delete(pc.permissions, key)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gossie/modelling-service/domain"
+)
+
+// countingModelRepository gives the users access to the models they saved and counts the checks.
+type countingModelRepository struct {
+	domain.ModelRepository
+	lastId int
+	models map[int]string
+	checks int
+}
+
+func (cr *countingModelRepository) SaveModel(ctx context.Context, userEmail string, cmr domain.ModelCreationRequest) (int, error) {
+	cr.lastId++
+	cr.models[cr.lastId] = userEmail
+	return cr.lastId, nil
+}
+
+func (cr *countingModelRepository) HasAccess(ctx context.Context, modelId int, userEmail string) (bool, error) {
+	cr.checks++
+	return cr.models[modelId] == userEmail, nil
+}
+
+func TestPermissionsAreCachedUntilTheyExpireOrChange(t *testing.T) {
+	const owner = "owner@example.com"
+	ctx := context.Background()
+	repository := &countingModelRepository{models: make(map[int]string)}
+	cache := NewPermissionCache(repository, time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	expectAccess := func(modelId int, expectedAccess bool, expectedChecks int) {
+		t.Helper()
+		hasAccess, err := cache.HasAccess(ctx, modelId, owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hasAccess != expectedAccess {
+			t.Errorf("expected access to model %v to be %v", modelId, expectedAccess)
+		}
+		if repository.checks != expectedChecks {
+			t.Errorf("expected %v checks, got %v", expectedChecks, repository.checks)
+		}
+	}
+
+	modelId, err := cache.SaveModel(ctx, owner, domain.ModelCreationRequest{Name: "car"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectAccess(modelId, true, 1)
+	expectAccess(modelId, true, 1)
+
+	expectAccess(modelId+1, false, 2)
+	expectAccess(modelId+1, false, 2)
+	nextId, err := cache.SaveModel(ctx, owner, domain.ModelCreationRequest{Name: "bike"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectAccess(nextId, true, 3)
+
+	now = now.Add(time.Minute)
+	expectAccess(modelId, true, 4)
+
+	cache.Invalidate(modelId)
+	expectAccess(modelId, true, 5)
+}
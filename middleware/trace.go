@@ -2,37 +2,57 @@ package middleware
 
 import (
 	"context"
-	"fmt"
-	"log/slog"
 	"net/http"
-	"time"
+	"strings"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type requestId string
 
 const RequestIdKey = requestId("requestId")
 
-func traceRequest(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		newRequestId := uuid.NewString()
-		next(w, r.WithContext(context.WithValue(r.Context(), RequestIdKey, newRequestId)))
-	}
-}
+const tracerName = "github.com/gossie/modelling-service/middleware"
 
-func profile(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		defer func() { slog.InfoContext(r.Context(), fmt.Sprintf("took %v ms", time.Since(start).Milliseconds())) }()
-
-		next(w, r)
+// Trace starts the server span of a request, it continues the trace of an incoming traceparent header. The ID of the
+// trace becomes the request ID. Without a tracer provider and traceparent header, the request ID is random.
+func Trace(pattern string) Middleware {
+	route := pattern
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		route = path
 	}
-}
 
-func logIncomingRequests(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		slog.InfoContext(r.Context(), "handle incoming request", "method", r.Method, "url", r.URL.Path)
-		next(w, r)
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			ctx, span := otel.Tracer(tracerName).Start(ctx, pattern,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					semconv.HTTPRequestMethodKey.String(r.Method),
+					semconv.HTTPRoute(route),
+					semconv.URLPath(r.URL.Path),
+					semconv.UserAgentOriginal(r.UserAgent()),
+				),
+			)
+			defer span.End()
+
+			newRequestId := uuid.NewString()
+			if span.SpanContext().HasTraceID() {
+				newRequestId = span.SpanContext().TraceID().String()
+			}
+			recorder := RecordResponse(w)
+
+			next(recorder, r.WithContext(context.WithValue(ctx, RequestIdKey, newRequestId)))
+
+			span.SetAttributes(semconv.HTTPResponseStatusCode(recorder.Status()))
+			if recorder.Status() >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(recorder.Status()))
+			}
+		}
 	}
 }
@@ -0,0 +1,123 @@
+package persistence
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gossie/modelling-service/domain"
+)
+
+type sqlApiTokenRepository struct {
+	db session
+}
+
+const apiTokenColumns = "t.id, u.email, t.name, t.scopes, t.tokenHash, t.createdAt, t.expiresAt, t.lastUsedAt, t.lastUsedFrom, t.revokedAt"
+
+func (tr *sqlApiTokenRepository) SaveApiToken(ctx context.Context, userEmail string, name string, scopes []string, tokenHash string, expiresAt time.Time) (int, error) {
+	var userId int
+	err := tr.db.QueryRowContext(ctx, "SELECT id FROM users WHERE email = $1", userEmail).Scan(&userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return -1, domain.ErrNotFound
+	}
+	if err != nil {
+		return -1, err
+	}
+
+	var tokenId int
+	sqlStatement := `
+		INSERT INTO api_tokens (userId, name, scopes, tokenHash, createdAt, expiresAt)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`
+	err = tr.db.QueryRowContext(ctx, sqlStatement, userId, name, strings.Join(scopes, ","), tokenHash, time.Now().UTC(), expiresAt.UTC()).Scan(&tokenId)
+	return tokenId, err
+}
+
+func (tr *sqlApiTokenRepository) FindApiToken(ctx context.Context, tokenId int) (domain.ApiToken, error) {
+	sqlStatement := `
+		SELECT ` + apiTokenColumns + `
+		FROM api_tokens t
+		JOIN users u
+		ON u.id = t.userId
+		WHERE t.id = $1
+	`
+	token, err := scanApiToken(tr.db.QueryRowContext(ctx, sqlStatement, tokenId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.ApiToken{}, domain.ErrNotFound
+	}
+	return token, err
+}
+
+func (tr *sqlApiTokenRepository) FindApiTokensByUser(ctx context.Context, userEmail string) ([]domain.ApiToken, error) {
+	sqlStatement := `
+		SELECT ` + apiTokenColumns + `
+		FROM api_tokens t
+		JOIN users u
+		ON u.id = t.userId
+		WHERE u.email = $1
+		ORDER BY t.id DESC
+	`
+	rows, err := tr.db.QueryContext(ctx, sqlStatement, userEmail)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := make([]domain.ApiToken, 0)
+	for rows.Next() {
+		token, err := scanApiToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+func (tr *sqlApiTokenRepository) RecordApiTokenUse(ctx context.Context, tokenId int, usedAt time.Time, usedFrom string) error {
+	_, err := tr.db.ExecContext(ctx, "UPDATE api_tokens SET lastUsedAt = $1, lastUsedFrom = $2 WHERE id = $3", usedAt.UTC(), usedFrom, tokenId)
+	return err
+}
+
+func (tr *sqlApiTokenRepository) RevokeApiToken(ctx context.Context, userEmail string, tokenId int, revokedAt time.Time) error {
+	sqlStatement := `
+		UPDATE api_tokens
+		SET revokedAt = COALESCE(revokedAt, $1)
+		WHERE id = $2 AND userId = (SELECT id FROM users WHERE email = $3)
+	`
+	result, err := tr.db.ExecContext(ctx, sqlStatement, revokedAt.UTC(), tokenId, userEmail)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanApiToken(row scanner) (domain.ApiToken, error) {
+	var token domain.ApiToken
+	var scopes string
+	var lastUsedFrom sql.NullString
+	var lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(&token.Id, &token.UserEmail, &token.Name, &scopes, &token.TokenHash, &token.CreatedAt, &token.ExpiresAt, &lastUsedAt, &lastUsedFrom, &revokedAt)
+	if err != nil {
+		return domain.ApiToken{}, err
+	}
+	token.Scopes = strings.Split(scopes, ",")
+	token.LastUsedAt = lastUsedAt.Time
+	token.LastUsedFrom = lastUsedFrom.String
+	token.RevokedAt = revokedAt.Time
+	return token, nil
+}
@@ -7,25 +7,65 @@ import (
 	"github.com/gossie/modelling-service/domain"
 )
 
-type psqlConstraintRepository struct {
-	db *sql.DB
+type sqlConstraintRepository struct {
+	db session
 }
 
-func NewPsqlConstraintRepository(db *sql.DB) psqlConstraintRepository {
-	return psqlConstraintRepository{db: db}
+func NewPsqlConstraintRepository(db *sql.DB) sqlConstraintRepository {
+	return sqlConstraintRepository{db: dbSession{db}}
 }
 
-func (repo *psqlConstraintRepository) SaveConstraint(ctx context.Context, modelId string, ccr domain.ConstraintCreationRequest) (int, error) {
+func NewSqliteConstraintRepository(db *sql.DB) sqlConstraintRepository {
+	return sqlConstraintRepository{db: dbSession{db}}
+}
+
+func (repo *sqlConstraintRepository) SaveConstraint(ctx context.Context, modelId string, ccr domain.ConstraintCreationRequest) (int, error) {
+	for _, end := range [][2]int{{ccr.FromId, ccr.FromValueId}, {ccr.TargetId, ccr.TargetValueId}} {
+		var count int
+		var err error
+		if end[1] == 0 {
+			err = repo.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM parameters WHERE id = $1 AND modelId = $2", end[0], modelId).Scan(&count)
+		} else {
+			query := `
+				SELECT COUNT(*)
+				FROM parameters p
+				JOIN "values" v
+				ON v.parameterId = p.id
+				WHERE p.id = $1 AND p.modelId = $2 AND v.id = $3
+			`
+			err = repo.db.QueryRowContext(ctx, query, end[0], modelId, end[1]).Scan(&count)
+		}
+		if err != nil {
+			return -1, err
+		}
+		if count == 0 {
+			return -1, domain.ErrNotFound
+		}
+	}
+
 	var parameterId int
-	sql := `
+	query := `
 		INSERT INTO constraints (constraintType, fromId, fromValueId, targetId, targetValueId, modelId)
 		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id
 	`
-	err := repo.db.QueryRowContext(ctx, sql, ccr.Type, ccr.FromId, ccr.FromValueId, ccr.TargetId, ccr.TargetValueId, modelId).Scan(&parameterId)
+	err := repo.db.QueryRowContext(ctx, query, ccr.Type, ccr.FromId, nullableId(ccr.FromValueId), ccr.TargetId, nullableId(ccr.TargetValueId), modelId).Scan(&parameterId)
 	return parameterId, err
 }
 
-func (repo *psqlConstraintRepository) DeleteConstraint(ctx context.Context, modelId, constraintId string) error {
-	_, err := repo.db.ExecContext(ctx, "DELETE FROM constraints WHERE id = $1 AND modelId = $2", constraintId, modelId)
-	return err
+// nullableId stores a missing value as NULL, the value columns reference "values".
+func nullableId(id int) sql.NullInt32 {
+	return sql.NullInt32{Int32: int32(id), Valid: id != 0}
+}
+
+func (repo *sqlConstraintRepository) DeleteConstraint(ctx context.Context, modelId, constraintId string, expectedVersion int) error {
+	if expectedVersion == 0 {
+		_, err := repo.db.ExecContext(ctx, "DELETE FROM constraints WHERE id = $1 AND modelId = $2", constraintId, modelId)
+		return err
+	}
+
+	result, err := repo.db.ExecContext(ctx, "DELETE FROM constraints WHERE id = $1 AND modelId = $2 AND version = $3", constraintId, modelId, expectedVersion)
+	if err != nil {
+		return err
+	}
+	return conflictUnlessAffected(ctx, repo.db, result, "SELECT COUNT(*) FROM constraints WHERE id = $1 AND modelId = $2", constraintId, modelId)
 }
@@ -0,0 +1,37 @@
+package persistence
+
+// dialect covers the few places where the SQL of the supported databases differs.
+type dialect struct {
+	// name is also the directory of the dialect's migrations.
+	name string
+	// sortableTimestamp turns a timestamp column into text that sorts like the timestamp.
+	sortableTimestamp func(column string) string
+	// sortableInteger does the same for integer columns, SQLite never considers an integer greater than a string.
+	sortableInteger func(column string) string
+	// beginMigration starts the transaction the migrations run in and makes sure that no other process migrates at the same time.
+	beginMigration []string
+}
+
+var postgresDialect = dialect{
+	name: "postgres",
+	sortableTimestamp: func(column string) string {
+		return column
+	},
+	sortableInteger: func(column string) string {
+		return column
+	},
+	// the advisory lock is released when the transaction ends
+	beginMigration: []string{"BEGIN", "SELECT pg_advisory_xact_lock(8205342)"},
+}
+
+var sqliteDialect = dialect{
+	name: "sqlite",
+	sortableTimestamp: func(column string) string {
+		return "strftime('%Y-%m-%dT%H:%M:%f', " + column + ")"
+	},
+	sortableInteger: func(column string) string {
+		return "printf('%011d', " + column + ")"
+	},
+	// an immediate transaction holds the write lock of the database file until it ends
+	beginMigration: []string{"BEGIN IMMEDIATE"},
+}
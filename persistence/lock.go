@@ -0,0 +1,91 @@
+package persistence
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+
+	"github.com/gossie/modelling-service/domain"
+)
+
+// psqlLock is a session level advisory lock of Postgres, held on a connection it keeps for itself.
+type psqlLock struct {
+	db  *sql.DB
+	key int64
+
+	mu   sync.Mutex
+	conn *sql.Conn
+}
+
+// NewPsqlLock returns the advisory lock with the key. All instances that share the database must use the same key.
+func NewPsqlLock(db *sql.DB, key int64) domain.Lock {
+	return &psqlLock{db: db, key: key}
+}
+
+func (l *psqlLock) TryLock(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn != nil {
+		err := l.conn.PingContext(ctx)
+		if err == nil {
+			return true, nil
+		}
+		// the connection is gone and with it the lock
+		l.discard()
+		return false, err
+	}
+
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	var locked bool
+	err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", l.key).Scan(&locked)
+	if err != nil || !locked {
+		conn.Close()
+		return false, err
+	}
+	l.conn = conn
+	return true, nil
+}
+
+func (l *psqlLock) Unlock(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return nil
+	}
+	_, err := l.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", l.key)
+	if err != nil {
+		l.discard()
+		return err
+	}
+	err = l.conn.Close()
+	l.conn = nil
+	return err
+}
+
+// discard closes the connection instead of returning it to the pool, where it might still hold the lock.
+func (l *psqlLock) discard() {
+	_ = l.conn.Raw(func(any) error { return driver.ErrBadConn })
+	l.conn.Close()
+	l.conn = nil
+}
+
+// localLock is always held, the SQLite and the memory backend serve a single instance.
+type localLock struct{}
+
+func NewLocalLock() domain.Lock {
+	return localLock{}
+}
+
+func (localLock) TryLock(ctx context.Context) (bool, error) {
+	return true, nil
+}
+
+func (localLock) Unlock(ctx context.Context) error {
+	return nil
+}
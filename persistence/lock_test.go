@@ -0,0 +1,49 @@
+package persistence_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gossie/modelling-service/persistence"
+)
+
+func TestPsqlLockIsHeldByOneInstance(t *testing.T) {
+	dsn := os.Getenv(postgresDsnEnv)
+	if dsn == "" {
+		t.Skip("set " + postgresDsnEnv + " to test the lock against Postgres")
+	}
+	open := func() *sql.DB {
+		db, err := sql.Open("postgres", dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+	ctx := context.Background()
+	key := time.Now().UnixNano()
+	first, second := persistence.NewPsqlLock(open(), key), persistence.NewPsqlLock(open(), key)
+
+	if held, err := first.TryLock(ctx); err != nil || !held {
+		t.Fatalf("expected the first instance to get the lock, got %v, %v", held, err)
+	}
+	if held, err := first.TryLock(ctx); err != nil || !held {
+		t.Errorf("expected the first instance to keep the lock, got %v, %v", held, err)
+	}
+	if held, err := second.TryLock(ctx); err != nil || held {
+		t.Errorf("expected the second instance not to get the lock, got %v, %v", held, err)
+	}
+
+	if err := first.Unlock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if held, err := second.TryLock(ctx); err != nil || !held {
+		t.Errorf("expected the second instance to get the released lock, got %v, %v", held, err)
+	}
+	if err := second.Unlock(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
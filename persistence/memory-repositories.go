@@ -0,0 +1,1135 @@
+package persistence
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gossie/modelling-service/domain"
+)
+
+type memoryUserRepository struct {
+	store *memoryStore
+}
+
+func (ur *memoryUserRepository) FindByEmail(ctx context.Context, email string) (domain.User, error) {
+	ur.store.mu.RLock()
+	defer ur.store.mu.RUnlock()
+
+	user, ok := ur.store.findUser(email)
+	if !ok {
+		return domain.User{}, domain.ErrNotFound
+	}
+	return user, nil
+}
+
+func (ur *memoryUserRepository) SaveUser(ctx context.Context, email string) (domain.User, error) {
+	ur.store.mu.Lock()
+	defer ur.store.mu.Unlock()
+
+	if user, ok := ur.store.findUser(email); ok {
+		return user, nil
+	}
+	id := ur.store.nextId()
+	ur.store.users[id] = domain.User{Id: id, Email: email}
+	return ur.store.users[id], nil
+}
+
+type memoryModelRepository struct {
+	store *memoryStore
+}
+
+func (mr *memoryModelRepository) FindById(ctx context.Context, modelId int) (domain.Model, error) {
+	mr.store.mu.RLock()
+	defer mr.store.mu.RUnlock()
+
+	model, ok := mr.store.models[modelId]
+	if !ok {
+		return domain.Model{}, domain.ErrNotFound
+	}
+
+	result := mr.toModel(ctx, model)
+	for _, c := range mr.store.constraints {
+		if c.modelId == modelId {
+			result.Constraints = append(result.Constraints, c.constraint)
+		}
+	}
+	sort.Slice(result.Constraints, func(i, j int) bool { return result.Constraints[i].Id < result.Constraints[j].Id })
+	return result, nil
+}
+
+func (mr *memoryModelRepository) toModel(ctx context.Context, model *memoryModel) domain.Model {
+	translation, _ := translation(mr.store.modelTranslations, model.id, "name", languageOf(ctx))
+	return domain.Model{
+		Id:          model.id,
+		Name:        model.name,
+		Translation: translation,
+		Constraints: make([]domain.Constraint, 0),
+		CreatedAt:   model.createdAt,
+		UpdatedAt:   model.updatedAt,
+		Version:     model.version,
+	}
+}
+
+func (mr *memoryModelRepository) HasAccess(ctx context.Context, modelId int, userEmail string) (bool, error) {
+	mr.store.mu.RLock()
+	defer mr.store.mu.RUnlock()
+
+	user, ok := mr.store.findUser(userEmail)
+	model, exists := mr.store.models[modelId]
+	return ok && exists && model.userIds[user.Id], nil
+}
+
+func (mr *memoryModelRepository) SyncGroupGrants(ctx context.Context, userEmail string, modelIds []int) ([]int, error) {
+	mr.store.mu.Lock()
+	defer mr.store.mu.Unlock()
+
+	user, ok := mr.store.findUser(userEmail)
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+
+	changed := make([]int, 0)
+	for id, model := range mr.store.models {
+		if model.groupUserIds[user.Id] && !slices.Contains(modelIds, id) {
+			delete(model.userIds, user.Id)
+			delete(model.groupUserIds, user.Id)
+			changed = append(changed, id)
+		}
+	}
+	for _, modelId := range modelIds {
+		model, exists := mr.store.models[modelId]
+		if !exists || model.userIds[user.Id] {
+			continue
+		}
+		model.userIds[user.Id] = true
+		model.groupUserIds[user.Id] = true
+		changed = append(changed, modelId)
+	}
+	return changed, nil
+}
+
+func (mr *memoryModelRepository) FindAllByUser(ctx context.Context, userEmail string) ([]domain.Model, error) {
+	mr.store.mu.RLock()
+	defer mr.store.mu.RUnlock()
+
+	models := make([]domain.Model, 0)
+	user, ok := mr.store.findUser(userEmail)
+	if !ok {
+		return models, nil
+	}
+
+	for _, model := range mr.store.models {
+		if model.userIds[user.Id] {
+			models = append(models, mr.toModel(ctx, model))
+		}
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].Id < models[j].Id })
+	return models, nil
+}
+
+func (mr *memoryModelRepository) FindPageByUser(ctx context.Context, userEmail string, filter domain.ModelFilter, pr domain.PageRequest) (domain.Page[domain.Model], error) {
+	mr.store.mu.RLock()
+	defer mr.store.mu.RUnlock()
+
+	items := make([]memoryPageItem[domain.Model], 0)
+	user, ok := mr.store.findUser(userEmail)
+	for _, model := range mr.store.models {
+		if !ok || !model.userIds[user.Id] {
+			continue
+		}
+
+		result := mr.toModel(ctx, model)
+		if filter.Untranslated && result.Translation != "" {
+			continue
+		}
+		if filter.HasConstraints && !mr.store.hasConstraints(model.id) {
+			continue
+		}
+
+		var key string
+		switch pr.SortBy {
+		case domain.SortByName:
+			key = valueOrDefault(result.Translation, result.Name)
+		case domain.SortByUpdated:
+			key = sortableTime(result.UpdatedAt)
+		default:
+			key = sortableTime(result.CreatedAt)
+		}
+		items = append(items, memoryPageItem[domain.Model]{item: result, id: result.Id, keys: []string{key}})
+	}
+
+	return memoryPage(items, 1, pr)
+}
+
+func (mr *memoryModelRepository) SaveModel(ctx context.Context, userEmail string, cmr domain.ModelCreationRequest) (int, error) {
+	mr.store.mu.Lock()
+	defer mr.store.mu.Unlock()
+
+	user, ok := mr.store.findUser(userEmail)
+	if !ok {
+		return -1, domain.ErrNotFound
+	}
+
+	now := time.Now().UTC()
+	id := mr.store.nextId()
+	mr.store.models[id] = &memoryModel{
+		id:           id,
+		name:         cmr.Name,
+		userIds:      map[int]bool{user.Id: true},
+		groupUserIds: make(map[int]bool),
+		createdAt:    now,
+		updatedAt:    now,
+		version:      1,
+	}
+	return id, nil
+}
+
+func (mr *memoryModelRepository) Statistics(ctx context.Context) ([]domain.ModelStatistics, error) {
+	mr.store.mu.RLock()
+	defer mr.store.mu.RUnlock()
+
+	statistics := make(map[int]*domain.ModelStatistics, len(mr.store.models))
+	for id := range mr.store.models {
+		statistics[id] = &domain.ModelStatistics{ModelId: id}
+	}
+	for _, parameter := range mr.store.parameters {
+		statistics[parameter.modelId].Parameters++
+	}
+	for _, constraint := range mr.store.constraints {
+		statistics[constraint.modelId].Constraints++
+	}
+
+	result := make([]domain.ModelStatistics, 0, len(statistics))
+	for _, s := range statistics {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ModelId < result[j].ModelId })
+	return result, nil
+}
+
+type memoryParameterRepository struct {
+	store *memoryStore
+}
+
+func (pr *memoryParameterRepository) toParameter(language string, parameter *memoryParameter) domain.Parameter {
+	name, _ := translation(pr.store.parameterTranslations, parameter.id, "name", language)
+	description, _ := translation(pr.store.parameterTranslations, parameter.id, "description", language)
+
+	values := make([]domain.Value, 0)
+	for _, value := range pr.store.values {
+		if value.parameterId == parameter.id {
+			valueTranslation, _ := translation(pr.store.valueTranslations, value.id, "", language)
+			values = append(values, domain.Value{Id: value.id, Value: value.value, Translation: valueTranslation, Position: value.position, Version: value.version})
+		}
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Position != values[j].Position {
+			return values[i].Position < values[j].Position
+		}
+		return values[i].Id < values[j].Id
+	})
+
+	return domain.Parameter{
+		Id:           parameter.id,
+		Name:         parameter.name,
+		Translation:  name,
+		ValueType:    parameter.valueType,
+		Value:        domain.ParameterValue{Values: values},
+		GroupId:      parameter.groupId,
+		Position:     parameter.position,
+		Description:  description,
+		Unit:         parameter.unit,
+		Required:     parameter.required,
+		DefaultValue: parameter.defaultValue,
+		CreatedAt:    parameter.createdAt,
+		UpdatedAt:    parameter.updatedAt,
+		Version:      parameter.version,
+	}
+}
+
+func (pr *memoryParameterRepository) FindAllByModelId(ctx context.Context, modelId int, searchValue string) ([]domain.Parameter, error) {
+	pr.store.mu.RLock()
+	defer pr.store.mu.RUnlock()
+
+	language := languageOf(ctx)
+	parameters := make([]domain.Parameter, 0)
+	for _, parameter := range pr.store.parameters {
+		if parameter.modelId != modelId {
+			continue
+		}
+
+		result := pr.toParameter(language, parameter)
+		if matchesSearch(result, searchValue) {
+			parameters = append(parameters, result)
+		}
+	}
+	sort.Slice(parameters, func(i, j int) bool {
+		if parameters[i].Position != parameters[j].Position {
+			return parameters[i].Position < parameters[j].Position
+		}
+		return parameters[i].Id < parameters[j].Id
+	})
+	return parameters, nil
+}
+
+func matchesSearch(parameter domain.Parameter, searchValue string) bool {
+	if searchValue == "" || searchValue == "*" {
+		return true
+	}
+	return strings.Contains(parameter.Name, searchValue) || (parameter.Translation != "" && strings.Contains(parameter.Translation, searchValue))
+}
+
+func (pr *memoryParameterRepository) FindPageByModelId(ctx context.Context, modelId int, filter domain.ParameterFilter, pageRequest domain.PageRequest) (domain.Page[domain.Parameter], error) {
+	pr.store.mu.RLock()
+	defer pr.store.mu.RUnlock()
+
+	language := languageOf(ctx)
+	items := make([]memoryPageItem[domain.Parameter], 0)
+	for _, parameter := range pr.store.parameters {
+		if parameter.modelId != modelId {
+			continue
+		}
+
+		result := pr.toParameter(language, parameter)
+		if !matchesSearch(result, filter.SearchValue) {
+			continue
+		}
+		if filter.ValueType != nil && result.ValueType != *filter.ValueType {
+			continue
+		}
+		if filter.HasValues != nil && (len(result.Value.Values) > 0) != *filter.HasValues {
+			continue
+		}
+		if filter.HasConstraints != nil && pr.isConstrained(parameter.id) != *filter.HasConstraints {
+			continue
+		}
+		if filter.Untranslated && result.Translation != "" {
+			continue
+		}
+
+		var keys []string
+		switch pageRequest.SortBy {
+		case domain.SortByName:
+			keys = []string{valueOrDefault(result.Translation, result.Name)}
+		case domain.SortByCreated:
+			keys = []string{sortableTime(result.CreatedAt)}
+		case domain.SortByUpdated:
+			keys = []string{sortableTime(result.UpdatedAt)}
+		default:
+			// parameters without a group are listed after all groups
+			groupPosition := 2147483647
+			if group, ok := pr.store.parameterGroups[parameter.groupId]; ok {
+				groupPosition = group.position
+			}
+			keys = []string{sortablePosition(groupPosition), sortablePosition(result.Position)}
+		}
+		items = append(items, memoryPageItem[domain.Parameter]{item: result, id: result.Id, keys: keys})
+	}
+
+	keyCount := 1
+	if pageRequest.SortBy != domain.SortByName && pageRequest.SortBy != domain.SortByCreated && pageRequest.SortBy != domain.SortByUpdated {
+		keyCount = 2
+	}
+	return memoryPage(items, keyCount, pageRequest)
+}
+
+func (pr *memoryParameterRepository) isConstrained(parameterId int) bool {
+	for _, c := range pr.store.constraints {
+		if c.constraint.FromId == parameterId || c.constraint.TargetId == parameterId {
+			return true
+		}
+	}
+	return false
+}
+
+func (pr *memoryParameterRepository) FindById(ctx context.Context, modelId, parameterId int) (domain.Parameter, error) {
+	pr.store.mu.RLock()
+	defer pr.store.mu.RUnlock()
+
+	parameter, ok := pr.store.parameters[parameterId]
+	if !ok || parameter.modelId != modelId {
+		return domain.Parameter{}, domain.ErrNotFound
+	}
+	return pr.toParameter(languageOf(ctx), parameter), nil
+}
+
+func (pr *memoryParameterRepository) SaveParameter(ctx context.Context, modelId int, pmr domain.ParameterCreationRequest) (int, error) {
+	pr.store.mu.Lock()
+	defer pr.store.mu.Unlock()
+
+	position := 0
+	for _, parameter := range pr.store.parameters {
+		if parameter.modelId == modelId && parameter.position >= position {
+			position = parameter.position + 1
+		}
+	}
+
+	now := time.Now().UTC()
+	id := pr.store.nextId()
+	pr.store.parameters[id] = &memoryParameter{
+		id:        id,
+		modelId:   modelId,
+		name:      pmr.Name,
+		valueType: pmr.ValueType,
+		position:  position,
+		createdAt: now,
+		updatedAt: now,
+		version:   1,
+	}
+	pr.store.touchModel(modelId)
+	return id, nil
+}
+
+func (pr *memoryParameterRepository) DeleteParameter(ctx context.Context, modelId int, parameterId int, expectedVersion int) error {
+	pr.store.mu.Lock()
+	defer pr.store.mu.Unlock()
+
+	parameter, ok := pr.store.parameters[parameterId]
+	if !ok || parameter.modelId != modelId {
+		if expectedVersion == 0 {
+			return nil
+		}
+		return domain.ErrNotFound
+	}
+	if err := checkVersion(parameter.version, expectedVersion); err != nil {
+		return err
+	}
+
+	delete(pr.store.parameters, parameterId)
+	deleteTranslations(pr.store.parameterTranslations, parameterId)
+	for id, value := range pr.store.values {
+		if value.parameterId == parameterId {
+			pr.store.deleteValue(id)
+		}
+	}
+	pr.store.deleteConstraintsWhere(func(c domain.Constraint) bool {
+		return c.FromId == parameterId || c.TargetId == parameterId
+	})
+	pr.store.touchModel(modelId)
+	return nil
+}
+
+func (pr *memoryParameterRepository) UpdateParameter(ctx context.Context, modelId, parameterId int, pmr domain.ParameterModificationRequest) error {
+	pr.store.mu.Lock()
+	defer pr.store.mu.Unlock()
+
+	parameter, ok := pr.store.parameters[parameterId]
+	if !ok || parameter.modelId != modelId {
+		return domain.ErrNotFound
+	}
+	if err := checkVersion(parameter.version, pmr.Version); err != nil {
+		return err
+	}
+
+	parameter.unit = pmr.Unit
+	parameter.required = pmr.Required
+	parameter.defaultValue = pmr.DefaultValue
+	pr.store.touchParameter(parameterId)
+	setTranslation(pr.store, pr.store.parameterTranslations, parameterId, "description", languageOf(ctx), pmr.Description)
+	return nil
+}
+
+func (pr *memoryParameterRepository) FindAllTranslations(ctx context.Context, modelId, parameterId int) ([]domain.Translation, error) {
+	pr.store.mu.RLock()
+	defer pr.store.mu.RUnlock()
+
+	if parameter, ok := pr.store.parameters[parameterId]; !ok || parameter.modelId != modelId {
+		return nil, domain.ErrNotFound
+	}
+	return findAllTranslations(pr.store.parameterTranslations, parameterId), nil
+}
+
+func (pr *memoryParameterRepository) SaveTranslations(ctx context.Context, modelId, parameterId int, tmr domain.TranslationModificationRequest) error {
+	pr.store.mu.Lock()
+	defer pr.store.mu.Unlock()
+
+	if err := pr.checkParameterVersion(modelId, parameterId, tmr.Version); err != nil {
+		return err
+	}
+
+	saveTranslations(pr.store, pr.store.parameterTranslations, parameterId, "", tmr)
+	pr.store.touchParameter(parameterId)
+	return nil
+}
+
+func (pr *memoryParameterRepository) checkParameterVersion(modelId, parameterId, expectedVersion int) error {
+	parameter, ok := pr.store.parameters[parameterId]
+	if !ok || parameter.modelId != modelId {
+		return domain.ErrNotFound
+	}
+	return checkVersion(parameter.version, expectedVersion)
+}
+
+func (pr *memoryParameterRepository) SaveValues(ctx context.Context, modelId, parameterId int, vmr domain.ValueModificationRequest) error {
+	pr.store.mu.Lock()
+	defer pr.store.mu.Unlock()
+
+	if err := pr.checkParameterVersion(modelId, parameterId, vmr.Version); err != nil {
+		return err
+	}
+
+	// updates and deletions are checked first, so that a rejected request leaves the parameter untouched
+	for _, value := range vmr.UpdatedValues {
+		existing, ok := pr.store.values[value.Id]
+		if !ok || existing.parameterId != parameterId {
+			return domain.ErrNotFound
+		}
+		if err := checkVersion(existing.version, value.Version); err != nil {
+			return err
+		}
+	}
+
+	deleted := make([]int, 0, len(vmr.DeletedValues))
+	for _, valueId := range vmr.DeletedValues {
+		value, ok := pr.store.values[valueId]
+		if !ok || value.parameterId != parameterId {
+			return domain.ErrNotFound
+		}
+		if !vmr.Cascade && pr.isValueInUse(valueId) {
+			return domain.ErrValueInUse
+		}
+		deleted = append(deleted, valueId)
+	}
+
+	lastPosition := -1
+	for _, value := range pr.store.values {
+		if value.parameterId == parameterId && value.position > lastPosition {
+			lastPosition = value.position
+		}
+	}
+	for i, value := range vmr.NewValues {
+		valueId := pr.store.nextId()
+		pr.store.values[valueId] = &memoryValue{id: valueId, parameterId: parameterId, value: value, position: lastPosition + i + 1, version: 1}
+	}
+
+	language := languageOf(ctx)
+	for _, value := range vmr.UpdatedValues {
+		existing := pr.store.values[value.Id]
+		existing.value = value.Value
+		existing.version++
+		if value.Translation != "" {
+			setTranslation(pr.store, pr.store.valueTranslations, value.Id, "", language, value.Translation)
+		}
+	}
+
+	for _, valueId := range deleted {
+		pr.store.deleteConstraintsWhere(func(c domain.Constraint) bool {
+			return c.FromValueId == valueId || c.TargetValueId == valueId
+		})
+		pr.store.deleteValue(valueId)
+	}
+
+	for position, valueId := range vmr.ValueOrder {
+		if value, ok := pr.store.values[valueId]; ok && value.parameterId == parameterId {
+			value.position = position
+		}
+	}
+
+	pr.store.touchParameter(parameterId)
+	return nil
+}
+
+func (pr *memoryParameterRepository) isValueInUse(valueId int) bool {
+	for _, c := range pr.store.constraints {
+		if c.constraint.FromValueId == valueId || c.constraint.TargetValueId == valueId {
+			return true
+		}
+	}
+	return false
+}
+
+type memoryParameterGroupRepository struct {
+	store *memoryStore
+}
+
+func (gr *memoryParameterGroupRepository) FindAllByModelId(ctx context.Context, modelId int) ([]domain.ParameterGroup, error) {
+	gr.store.mu.RLock()
+	defer gr.store.mu.RUnlock()
+
+	language := languageOf(ctx)
+	groups := make([]domain.ParameterGroup, 0)
+	for _, group := range gr.store.parameterGroups {
+		if group.modelId == modelId {
+			translation, _ := translation(gr.store.parameterGroupTranslations, group.id, "name", language)
+			groups = append(groups, domain.ParameterGroup{Id: group.id, Name: group.name, Translation: translation, Position: group.position})
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Position != groups[j].Position {
+			return groups[i].Position < groups[j].Position
+		}
+		return groups[i].Id < groups[j].Id
+	})
+	return groups, nil
+}
+
+func (gr *memoryParameterGroupRepository) SaveGroup(ctx context.Context, modelId int, gcr domain.ParameterGroupCreationRequest) (int, error) {
+	gr.store.mu.Lock()
+	defer gr.store.mu.Unlock()
+
+	position := 0
+	for _, group := range gr.store.parameterGroups {
+		if group.modelId == modelId && group.position >= position {
+			position = group.position + 1
+		}
+	}
+
+	id := gr.store.nextId()
+	gr.store.parameterGroups[id] = &memoryParameterGroup{id: id, modelId: modelId, name: gcr.Name, position: position}
+	return id, nil
+}
+
+func (gr *memoryParameterGroupRepository) DeleteGroup(ctx context.Context, modelId, groupId int) error {
+	gr.store.mu.Lock()
+	defer gr.store.mu.Unlock()
+
+	group, ok := gr.store.parameterGroups[groupId]
+	if !ok || group.modelId != modelId {
+		return nil
+	}
+
+	for _, parameter := range gr.store.parameters {
+		if parameter.groupId == groupId {
+			parameter.groupId = 0
+		}
+	}
+	deleteTranslations(gr.store.parameterGroupTranslations, groupId)
+	delete(gr.store.parameterGroups, groupId)
+	return nil
+}
+
+func (gr *memoryParameterGroupRepository) FindAllTranslations(ctx context.Context, modelId, groupId int) ([]domain.Translation, error) {
+	gr.store.mu.RLock()
+	defer gr.store.mu.RUnlock()
+
+	if group, ok := gr.store.parameterGroups[groupId]; !ok || group.modelId != modelId {
+		return nil, domain.ErrNotFound
+	}
+	return findAllTranslations(gr.store.parameterGroupTranslations, groupId), nil
+}
+
+func (gr *memoryParameterGroupRepository) SaveTranslations(ctx context.Context, modelId, groupId int, tmr domain.TranslationModificationRequest) error {
+	gr.store.mu.Lock()
+	defer gr.store.mu.Unlock()
+
+	if group, ok := gr.store.parameterGroups[groupId]; !ok || group.modelId != modelId {
+		return domain.ErrNotFound
+	}
+	saveTranslations(gr.store, gr.store.parameterGroupTranslations, groupId, "name", tmr)
+	return nil
+}
+
+func (gr *memoryParameterGroupRepository) SaveLayout(ctx context.Context, modelId int, layout []domain.ParameterLayout) error {
+	gr.store.mu.Lock()
+	defer gr.store.mu.Unlock()
+
+	// the layout is checked first, so that a rejected layout leaves the parameters untouched
+	for _, entry := range layout {
+		if group, ok := gr.store.parameterGroups[entry.GroupId]; entry.GroupId != 0 && (!ok || group.modelId != modelId) {
+			return domain.ErrNotFound
+		}
+	}
+
+	groupPosition := 0
+	for _, entry := range layout {
+		if entry.GroupId != 0 {
+			gr.store.parameterGroups[entry.GroupId].position = groupPosition
+			groupPosition++
+		}
+
+		for position, parameterId := range entry.ParameterIds {
+			if parameter, ok := gr.store.parameters[parameterId]; ok && parameter.modelId == modelId {
+				parameter.groupId = entry.GroupId
+				parameter.position = position
+			}
+		}
+	}
+	return nil
+}
+
+type memoryConstraintRepository struct {
+	store *memoryStore
+}
+
+func (cr *memoryConstraintRepository) SaveConstraint(ctx context.Context, modelId string, ccr domain.ConstraintCreationRequest) (int, error) {
+	cr.store.mu.Lock()
+	defer cr.store.mu.Unlock()
+
+	model, err := strconv.Atoi(modelId)
+	if err != nil {
+		return -1, err
+	}
+	for _, end := range [][2]int{{ccr.FromId, ccr.FromValueId}, {ccr.TargetId, ccr.TargetValueId}} {
+		parameter, ok := cr.store.parameters[end[0]]
+		if !ok || parameter.modelId != model {
+			return -1, domain.ErrNotFound
+		}
+		if value, exists := cr.store.values[end[1]]; end[1] != 0 && (!exists || value.parameterId != parameter.id) {
+			return -1, domain.ErrNotFound
+		}
+	}
+
+	id := cr.store.nextId()
+	cr.store.constraints[id] = &memoryConstraint{
+		modelId: model,
+		constraint: domain.Constraint{
+			Id:            id,
+			Type:          ccr.Type,
+			FromId:        ccr.FromId,
+			FromValueId:   ccr.FromValueId,
+			TargetId:      ccr.TargetId,
+			TargetValueId: ccr.TargetValueId,
+			Version:       1,
+		},
+	}
+	return id, nil
+}
+
+func (cr *memoryConstraintRepository) DeleteConstraint(ctx context.Context, modelId, constraintId string, expectedVersion int) error {
+	cr.store.mu.Lock()
+	defer cr.store.mu.Unlock()
+
+	id, _ := strconv.Atoi(constraintId)
+	c, ok := cr.store.constraints[id]
+	if !ok || strconv.Itoa(c.modelId) != modelId {
+		if expectedVersion == 0 {
+			return nil
+		}
+		return domain.ErrNotFound
+	}
+	if err := checkVersion(c.constraint.Version, expectedVersion); err != nil {
+		return err
+	}
+
+	delete(cr.store.constraints, id)
+	return nil
+}
+
+type memoryWebhookRepository struct {
+	store *memoryStore
+}
+
+func (wr *memoryWebhookRepository) FindAllByModelId(ctx context.Context, modelId int) ([]domain.Webhook, error) {
+	wr.store.mu.RLock()
+	defer wr.store.mu.RUnlock()
+
+	webhooks := make([]domain.Webhook, 0)
+	for _, webhook := range wr.store.webhooks {
+		if webhook.ModelId == modelId {
+			webhooks = append(webhooks, *webhook)
+		}
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].Id < webhooks[j].Id })
+	return webhooks, nil
+}
+
+func (wr *memoryWebhookRepository) SaveWebhook(ctx context.Context, modelId int, wcr domain.WebhookCreationRequest) (int, error) {
+	wr.store.mu.Lock()
+	defer wr.store.mu.Unlock()
+
+	if _, ok := wr.store.models[modelId]; !ok {
+		return -1, domain.ErrNotFound
+	}
+
+	id := wr.store.nextId()
+	wr.store.webhooks[id] = &domain.Webhook{Id: id, ModelId: modelId, Url: wcr.Url, Secret: wcr.Secret, CreatedAt: time.Now().UTC()}
+	return id, nil
+}
+
+func (wr *memoryWebhookRepository) DeleteWebhook(ctx context.Context, modelId, webhookId int) error {
+	wr.store.mu.Lock()
+	defer wr.store.mu.Unlock()
+
+	webhook, ok := wr.store.webhooks[webhookId]
+	if !ok || webhook.ModelId != modelId {
+		return nil
+	}
+
+	delete(wr.store.webhooks, webhookId)
+	for id, delivery := range wr.store.webhookDeliveries {
+		if delivery.WebhookId == webhookId {
+			delete(wr.store.webhookDeliveries, id)
+		}
+	}
+	return nil
+}
+
+func (wr *memoryWebhookRepository) EnqueueDeliveries(ctx context.Context, modelId int, idempotencyKey string, event string, payload string) error {
+	wr.store.mu.Lock()
+	defer wr.store.mu.Unlock()
+
+	enqueued := make(map[int]bool)
+	for _, delivery := range wr.store.webhookDeliveries {
+		if idempotencyKey != "" && delivery.IdempotencyKey == idempotencyKey {
+			enqueued[delivery.WebhookId] = true
+		}
+	}
+
+	now := time.Now().UTC()
+	webhookIds := make([]int, 0)
+	for _, webhook := range wr.store.webhooks {
+		if webhook.ModelId == modelId && !enqueued[webhook.Id] {
+			webhookIds = append(webhookIds, webhook.Id)
+		}
+	}
+	// the IDs of the deliveries follow the order of the webhooks, like they do in the SQL backends
+	sort.Ints(webhookIds)
+
+	for _, webhookId := range webhookIds {
+		id := wr.store.nextId()
+		wr.store.webhookDeliveries[id] = &domain.WebhookDelivery{
+			Id:             id,
+			WebhookId:      webhookId,
+			Event:          event,
+			IdempotencyKey: idempotencyKey,
+			Payload:        payload,
+			Status:         domain.DeliveryPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+		}
+	}
+	return nil
+}
+
+func (wr *memoryWebhookRepository) FindDueDeliveries(ctx context.Context, now time.Time, limit int) ([]domain.DueDelivery, error) {
+	wr.store.mu.RLock()
+	defer wr.store.mu.RUnlock()
+
+	due := make([]domain.DueDelivery, 0)
+	for _, delivery := range wr.store.webhookDeliveries {
+		if delivery.Status == domain.DeliveryPending && !delivery.NextAttemptAt.After(now) {
+			webhook := *wr.store.webhooks[delivery.WebhookId]
+			due = append(due, domain.DueDelivery{Delivery: wr.withUrl(delivery), Webhook: webhook})
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		a, b := due[i].Delivery, due[j].Delivery
+		if !a.NextAttemptAt.Equal(b.NextAttemptAt) {
+			return a.NextAttemptAt.Before(b.NextAttemptAt)
+		}
+		return a.Id < b.Id
+	})
+
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+func (wr *memoryWebhookRepository) UpdateDelivery(ctx context.Context, delivery domain.WebhookDelivery) error {
+	wr.store.mu.Lock()
+	defer wr.store.mu.Unlock()
+
+	existing, ok := wr.store.webhookDeliveries[delivery.Id]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	existing.Status = delivery.Status
+	existing.Attempts = delivery.Attempts
+	existing.LastError = delivery.LastError
+	existing.ResponseStatus = delivery.ResponseStatus
+	existing.NextAttemptAt = delivery.NextAttemptAt.UTC()
+	existing.DeliveredAt = delivery.DeliveredAt.UTC()
+	return nil
+}
+
+func (wr *memoryWebhookRepository) FindDeliveriesByModelId(ctx context.Context, modelId int, limit int) ([]domain.WebhookDelivery, error) {
+	wr.store.mu.RLock()
+	defer wr.store.mu.RUnlock()
+
+	deliveries := make([]domain.WebhookDelivery, 0)
+	for _, delivery := range wr.store.webhookDeliveries {
+		if wr.store.webhooks[delivery.WebhookId].ModelId == modelId {
+			deliveries = append(deliveries, wr.withUrl(delivery))
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].Id > deliveries[j].Id })
+
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+func (wr *memoryWebhookRepository) RetryDelivery(ctx context.Context, modelId, deliveryId int) error {
+	wr.store.mu.Lock()
+	defer wr.store.mu.Unlock()
+
+	delivery, ok := wr.store.webhookDeliveries[deliveryId]
+	if !ok || wr.store.webhooks[delivery.WebhookId].ModelId != modelId {
+		return domain.ErrNotFound
+	}
+
+	delivery.Status = domain.DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now().UTC()
+	return nil
+}
+
+// withUrl copies the delivery and adds the URL of its webhook. The caller has to hold the lock.
+func (wr *memoryWebhookRepository) withUrl(delivery *domain.WebhookDelivery) domain.WebhookDelivery {
+	result := *delivery
+	result.Url = wr.store.webhooks[delivery.WebhookId].Url
+	return result
+}
+
+type memoryOutboxRepository struct {
+	store *memoryStore
+}
+
+func (or *memoryOutboxRepository) Append(ctx context.Context, modelId int, kind string, idempotencyKey string) error {
+	or.store.mu.Lock()
+	defer or.store.mu.Unlock()
+
+	for _, event := range or.store.outbox {
+		if event.IdempotencyKey == idempotencyKey {
+			return fmt.Errorf("idempotency key %v is already used", idempotencyKey)
+		}
+	}
+
+	id := or.store.nextId()
+	or.store.outbox[id] = &domain.OutboxEvent{Id: id, ModelId: modelId, Kind: kind, IdempotencyKey: idempotencyKey, CreatedAt: time.Now().UTC()}
+	return nil
+}
+
+func (or *memoryOutboxRepository) FindPending(ctx context.Context, limit int, excludedModelIds []int) ([]domain.OutboxEvent, error) {
+	or.store.mu.RLock()
+	defer or.store.mu.RUnlock()
+
+	pending := make([]domain.OutboxEvent, 0)
+	for _, event := range or.store.outbox {
+		if event.PublishedAt.IsZero() && !slices.Contains(excludedModelIds, event.ModelId) {
+			pending = append(pending, *event)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Id < pending[j].Id })
+
+	if len(pending) > limit {
+		pending = pending[:limit]
+	}
+	return pending, nil
+}
+
+func (or *memoryOutboxRepository) FindAfter(ctx context.Context, eventId int, limit int) ([]domain.OutboxEvent, error) {
+	or.store.mu.RLock()
+	defer or.store.mu.RUnlock()
+
+	events := make([]domain.OutboxEvent, 0)
+	for _, event := range or.store.outbox {
+		if event.Id > eventId {
+			events = append(events, *event)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Id < events[j].Id })
+
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+func (or *memoryOutboxRepository) LastId(ctx context.Context) (int, error) {
+	or.store.mu.RLock()
+	defer or.store.mu.RUnlock()
+
+	lastId := 0
+	for id := range or.store.outbox {
+		lastId = max(lastId, id)
+	}
+	return lastId, nil
+}
+
+func (or *memoryOutboxRepository) MarkPublished(ctx context.Context, eventId int, publishedAt time.Time) error {
+	or.store.mu.Lock()
+	defer or.store.mu.Unlock()
+
+	event, ok := or.store.outbox[eventId]
+	if !ok {
+		return domain.ErrNotFound
+	}
+	event.PublishedAt = publishedAt.UTC()
+	return nil
+}
+
+func (or *memoryOutboxRepository) PendingStats(ctx context.Context) (int, time.Time, error) {
+	or.store.mu.RLock()
+	defer or.store.mu.RUnlock()
+
+	count := 0
+	var oldest time.Time
+	for _, event := range or.store.outbox {
+		if !event.PublishedAt.IsZero() {
+			continue
+		}
+		count++
+		if oldest.IsZero() || event.CreatedAt.Before(oldest) {
+			oldest = event.CreatedAt
+		}
+	}
+	return count, oldest, nil
+}
+
+func (or *memoryOutboxRepository) DeletePublished(ctx context.Context, before time.Time) (int, error) {
+	or.store.mu.Lock()
+	defer or.store.mu.Unlock()
+
+	deleted := 0
+	for id, event := range or.store.outbox {
+		if !event.PublishedAt.IsZero() && event.PublishedAt.Before(before) {
+			delete(or.store.outbox, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+type memorySessionRepository struct {
+	store *memoryStore
+}
+
+func (sr *memorySessionRepository) SaveSession(ctx context.Context, userEmail string, refreshTokenHash string, expiresAt time.Time) (int, error) {
+	sr.store.mu.Lock()
+	defer sr.store.mu.Unlock()
+
+	if _, ok := sr.store.findUser(userEmail); !ok {
+		return -1, domain.ErrNotFound
+	}
+
+	id := sr.store.nextId()
+	sr.store.sessions[id] = &domain.Session{Id: id, UserEmail: userEmail, RefreshTokenHash: refreshTokenHash, CreatedAt: time.Now().UTC(), ExpiresAt: expiresAt.UTC()}
+	return id, nil
+}
+
+func (sr *memorySessionRepository) FindSession(ctx context.Context, sessionId int) (domain.Session, error) {
+	sr.store.mu.RLock()
+	defer sr.store.mu.RUnlock()
+
+	session, ok := sr.store.sessions[sessionId]
+	if !ok {
+		return domain.Session{}, domain.ErrNotFound
+	}
+	return *session, nil
+}
+
+func (sr *memorySessionRepository) RotateRefreshToken(ctx context.Context, sessionId int, expectedHash string, newHash string, rotatedAt time.Time, expiresAt time.Time) error {
+	sr.store.mu.Lock()
+	defer sr.store.mu.Unlock()
+
+	session, ok := sr.store.sessions[sessionId]
+	if !ok {
+		return domain.ErrNotFound
+	}
+	if session.RefreshTokenHash != expectedHash || !session.RevokedAt.IsZero() {
+		return domain.ErrConflict
+	}
+	session.PreviousRefreshTokenHash = session.RefreshTokenHash
+	session.RefreshTokenHash = newHash
+	session.RotatedAt = rotatedAt.UTC()
+	session.ExpiresAt = expiresAt.UTC()
+	return nil
+}
+
+func (sr *memorySessionRepository) RevokeSession(ctx context.Context, sessionId int, revokedAt time.Time) error {
+	sr.store.mu.Lock()
+	defer sr.store.mu.Unlock()
+
+	if session, ok := sr.store.sessions[sessionId]; ok && session.RevokedAt.IsZero() {
+		session.RevokedAt = revokedAt.UTC()
+	}
+	return nil
+}
+
+func (sr *memorySessionRepository) DeleteExpiredSessions(ctx context.Context, before time.Time) (int, error) {
+	sr.store.mu.Lock()
+	defer sr.store.mu.Unlock()
+
+	deleted := 0
+	for id, session := range sr.store.sessions {
+		if session.ExpiresAt.Before(before) || (!session.RevokedAt.IsZero() && session.RevokedAt.Before(before)) {
+			delete(sr.store.sessions, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+type memoryApiTokenRepository struct {
+	store *memoryStore
+}
+
+func (tr *memoryApiTokenRepository) SaveApiToken(ctx context.Context, userEmail string, name string, scopes []string, tokenHash string, expiresAt time.Time) (int, error) {
+	tr.store.mu.Lock()
+	defer tr.store.mu.Unlock()
+
+	if _, ok := tr.store.findUser(userEmail); !ok {
+		return -1, domain.ErrNotFound
+	}
+
+	id := tr.store.nextId()
+	tr.store.apiTokens[id] = &domain.ApiToken{
+		Id:        id,
+		UserEmail: userEmail,
+		Name:      name,
+		Scopes:    append([]string(nil), scopes...),
+		TokenHash: tokenHash,
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt.UTC(),
+	}
+	return id, nil
+}
+
+func (tr *memoryApiTokenRepository) FindApiToken(ctx context.Context, tokenId int) (domain.ApiToken, error) {
+	tr.store.mu.RLock()
+	defer tr.store.mu.RUnlock()
+
+	token, ok := tr.store.apiTokens[tokenId]
+	if !ok {
+		return domain.ApiToken{}, domain.ErrNotFound
+	}
+	return *token, nil
+}
+
+func (tr *memoryApiTokenRepository) FindApiTokensByUser(ctx context.Context, userEmail string) ([]domain.ApiToken, error) {
+	tr.store.mu.RLock()
+	defer tr.store.mu.RUnlock()
+
+	tokens := make([]domain.ApiToken, 0)
+	for _, token := range tr.store.apiTokens {
+		if token.UserEmail == userEmail {
+			tokens = append(tokens, *token)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].Id > tokens[j].Id })
+	return tokens, nil
+}
+
+func (tr *memoryApiTokenRepository) RecordApiTokenUse(ctx context.Context, tokenId int, usedAt time.Time, usedFrom string) error {
+	tr.store.mu.Lock()
+	defer tr.store.mu.Unlock()
+
+	if token, ok := tr.store.apiTokens[tokenId]; ok {
+		token.LastUsedAt = usedAt.UTC()
+		token.LastUsedFrom = usedFrom
+	}
+	return nil
+}
+
+func (tr *memoryApiTokenRepository) RevokeApiToken(ctx context.Context, userEmail string, tokenId int, revokedAt time.Time) error {
+	tr.store.mu.Lock()
+	defer tr.store.mu.Unlock()
+
+	token, ok := tr.store.apiTokens[tokenId]
+	if !ok || token.UserEmail != userEmail {
+		return domain.ErrNotFound
+	}
+	if token.RevokedAt.IsZero() {
+		token.RevokedAt = revokedAt.UTC()
+	}
+	return nil
+}
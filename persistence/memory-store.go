@@ -0,0 +1,400 @@
+package persistence
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	configurationmodel "github.com/gossie/configuration-model"
+	"github.com/gossie/modelling-service/domain"
+	"github.com/gossie/modelling-service/middleware"
+)
+
+// memoryStore keeps all data of the in-memory backend, every access is guarded by its mutex.
+type memoryStore struct {
+	mu     sync.RWMutex
+	lastId int
+
+	users                      map[int]domain.User
+	models                     map[int]*memoryModel
+	modelTranslations          map[int]*memoryTranslation
+	parameterGroups            map[int]*memoryParameterGroup
+	parameterGroupTranslations map[int]*memoryTranslation
+	parameters                 map[int]*memoryParameter
+	parameterTranslations      map[int]*memoryTranslation
+	values                     map[int]*memoryValue
+	valueTranslations          map[int]*memoryTranslation
+	constraints                map[int]*memoryConstraint
+	webhooks                   map[int]*domain.Webhook
+	webhookDeliveries          map[int]*domain.WebhookDelivery
+	outbox                     map[int]*domain.OutboxEvent
+	sessions                   map[int]*domain.Session
+	apiTokens                  map[int]*domain.ApiToken
+}
+
+type memoryModel struct {
+	id      int
+	name    string
+	userIds map[int]bool
+	// groupUserIds are the users whose access a group granted
+	groupUserIds map[int]bool
+	createdAt    time.Time
+	updatedAt    time.Time
+	version      int
+}
+
+// memoryTranslation is a translation of the entity with the ID ownerId.
+type memoryTranslation struct {
+	id       int
+	ownerId  int
+	field    string
+	language string
+	value    string
+}
+
+type memoryParameterGroup struct {
+	id       int
+	modelId  int
+	name     string
+	position int
+}
+
+type memoryParameter struct {
+	id           int
+	modelId      int
+	groupId      int
+	name         string
+	valueType    configurationmodel.ValueType
+	position     int
+	unit         string
+	required     bool
+	defaultValue string
+	createdAt    time.Time
+	updatedAt    time.Time
+	version      int
+}
+
+type memoryValue struct {
+	id          int
+	parameterId int
+	value       string
+	position    int
+	version     int
+}
+
+type memoryConstraint struct {
+	modelId    int
+	constraint domain.Constraint
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		users:                      make(map[int]domain.User),
+		models:                     make(map[int]*memoryModel),
+		modelTranslations:          make(map[int]*memoryTranslation),
+		parameterGroups:            make(map[int]*memoryParameterGroup),
+		parameterGroupTranslations: make(map[int]*memoryTranslation),
+		parameters:                 make(map[int]*memoryParameter),
+		parameterTranslations:      make(map[int]*memoryTranslation),
+		values:                     make(map[int]*memoryValue),
+		valueTranslations:          make(map[int]*memoryTranslation),
+		constraints:                make(map[int]*memoryConstraint),
+		webhooks:                   make(map[int]*domain.Webhook),
+		webhookDeliveries:          make(map[int]*domain.WebhookDelivery),
+		outbox:                     make(map[int]*domain.OutboxEvent),
+		sessions:                   make(map[int]*domain.Session),
+		apiTokens:                  make(map[int]*domain.ApiToken),
+	}
+}
+
+// NewMemoryRepositories creates repositories that keep their data in memory. The data is lost when the process ends.
+// The users with the passed emails are created up front, because there is no way to register users.
+func NewMemoryRepositories(userEmails ...string) domain.Repositories {
+	store := newMemoryStore()
+	for _, email := range userEmails {
+		id := store.nextId()
+		store.users[id] = domain.User{Id: id, Email: email}
+	}
+
+	return store.repositories()
+}
+
+func (s *memoryStore) repositories() domain.Repositories {
+	return domain.Repositories{
+		Users:           &memoryUserRepository{store: s},
+		Models:          &memoryModelRepository{store: s},
+		Parameters:      &memoryParameterRepository{store: s},
+		ParameterGroups: &memoryParameterGroupRepository{store: s},
+		Constraints:     &memoryConstraintRepository{store: s},
+		Webhooks:        &memoryWebhookRepository{store: s},
+		Outbox:          &memoryOutboxRepository{store: s},
+		Sessions:        &memorySessionRepository{store: s},
+		ApiTokens:       &memoryApiTokenRepository{store: s},
+		UnitOfWork:      &memoryUnitOfWork{store: s},
+	}
+}
+
+// memoryUnitOfWork lets the work change a copy of the store, which replaces the store's data if the work succeeds.
+type memoryUnitOfWork struct {
+	store *memoryStore
+}
+
+func (u *memoryUnitOfWork) Do(ctx context.Context, work func(domain.Repositories) error) error {
+	u.store.mu.Lock()
+	defer u.store.mu.Unlock()
+
+	working := u.store.clone()
+	err := work(working.repositories())
+	if err != nil {
+		return err
+	}
+
+	u.store.replaceData(working)
+	return nil
+}
+
+func (s *memoryStore) clone() *memoryStore {
+	c := newMemoryStore()
+	c.lastId = s.lastId
+	for id, user := range s.users {
+		c.users[id] = user
+	}
+	for id, model := range s.models {
+		modelCopy := *model
+		modelCopy.userIds = make(map[int]bool, len(model.userIds))
+		for userId := range model.userIds {
+			modelCopy.userIds[userId] = true
+		}
+		modelCopy.groupUserIds = make(map[int]bool, len(model.groupUserIds))
+		for userId := range model.groupUserIds {
+			modelCopy.groupUserIds[userId] = true
+		}
+		c.models[id] = &modelCopy
+	}
+	copyEntries(c.modelTranslations, s.modelTranslations)
+	copyEntries(c.parameterGroups, s.parameterGroups)
+	copyEntries(c.parameterGroupTranslations, s.parameterGroupTranslations)
+	copyEntries(c.parameters, s.parameters)
+	copyEntries(c.parameterTranslations, s.parameterTranslations)
+	copyEntries(c.values, s.values)
+	copyEntries(c.valueTranslations, s.valueTranslations)
+	copyEntries(c.constraints, s.constraints)
+	copyEntries(c.webhooks, s.webhooks)
+	copyEntries(c.webhookDeliveries, s.webhookDeliveries)
+	copyEntries(c.outbox, s.outbox)
+	copyEntries(c.sessions, s.sessions)
+	copyEntries(c.apiTokens, s.apiTokens)
+	return c
+}
+
+func copyEntries[T any](target, source map[int]*T) {
+	for id, entry := range source {
+		entryCopy := *entry
+		target[id] = &entryCopy
+	}
+}
+
+// replaceData takes over the data of other. The caller has to hold the lock.
+func (s *memoryStore) replaceData(other *memoryStore) {
+	s.lastId = other.lastId
+	s.users = other.users
+	s.models = other.models
+	s.modelTranslations = other.modelTranslations
+	s.parameterGroups = other.parameterGroups
+	s.parameterGroupTranslations = other.parameterGroupTranslations
+	s.parameters = other.parameters
+	s.parameterTranslations = other.parameterTranslations
+	s.values = other.values
+	s.valueTranslations = other.valueTranslations
+	s.constraints = other.constraints
+	s.webhooks = other.webhooks
+	s.webhookDeliveries = other.webhookDeliveries
+	s.outbox = other.outbox
+	s.sessions = other.sessions
+	s.apiTokens = other.apiTokens
+}
+
+func (s *memoryStore) nextId() int {
+	s.lastId++
+	return s.lastId
+}
+
+func (s *memoryStore) findUser(email string) (domain.User, bool) {
+	for _, user := range s.users {
+		if user.Email == email {
+			return user, true
+		}
+	}
+	return domain.User{}, false
+}
+
+func (s *memoryStore) touchModel(modelId int) {
+	if model, ok := s.models[modelId]; ok {
+		model.updatedAt = time.Now().UTC()
+		model.version++
+	}
+}
+
+func (s *memoryStore) touchParameter(parameterId int) {
+	if parameter, ok := s.parameters[parameterId]; ok {
+		parameter.updatedAt = time.Now().UTC()
+		parameter.version++
+	}
+}
+
+// checkVersion compares the version of an entity with the version a change is based on. An expected version of 0 skips the check.
+func checkVersion(version, expectedVersion int) error {
+	if expectedVersion != 0 && version != expectedVersion {
+		return domain.ErrConflict
+	}
+	return nil
+}
+
+func (s *memoryStore) hasConstraints(modelId int) bool {
+	for _, c := range s.constraints {
+		if c.modelId == modelId {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *memoryStore) deleteConstraintsWhere(matches func(domain.Constraint) bool) {
+	for id, c := range s.constraints {
+		if matches(c.constraint) {
+			delete(s.constraints, id)
+		}
+	}
+}
+
+func (s *memoryStore) deleteValue(valueId int) {
+	delete(s.values, valueId)
+	deleteTranslations(s.valueTranslations, valueId)
+}
+
+// translation returns the translation of the owner's field in the given language.
+func translation(translations map[int]*memoryTranslation, ownerId int, field, language string) (string, bool) {
+	for _, t := range translations {
+		if t.ownerId == ownerId && t.field == field && t.language == language {
+			return t.value, true
+		}
+	}
+	return "", false
+}
+
+func setTranslation(s *memoryStore, translations map[int]*memoryTranslation, ownerId int, field, language, value string) {
+	for id, t := range translations {
+		if t.ownerId == ownerId && t.field == field && t.language == language {
+			delete(translations, id)
+		}
+	}
+	if value != "" {
+		id := s.nextId()
+		translations[id] = &memoryTranslation{id: id, ownerId: ownerId, field: field, language: language, value: value}
+	}
+}
+
+func deleteTranslations(translations map[int]*memoryTranslation, ownerId int) {
+	for id, t := range translations {
+		if t.ownerId == ownerId {
+			delete(translations, id)
+		}
+	}
+}
+
+func findAllTranslations(translations map[int]*memoryTranslation, id int) []domain.Translation {
+	result := make([]domain.Translation, 0)
+	for _, t := range translations {
+		if t.ownerId == id {
+			result = append(result, domain.Translation{Id: t.id, Field: t.field, Language: t.language, Value: t.value})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Id < result[j].Id })
+	return result
+}
+
+// saveTranslations stores the translations of the owner, fixedField replaces the field of new translations if set.
+func saveTranslations(s *memoryStore, translations map[int]*memoryTranslation, id int, fixedField string, tmr domain.TranslationModificationRequest) {
+	for _, t := range tmr.NewTranslations {
+		field := t.Field
+		if fixedField != "" {
+			field = fixedField
+		}
+		translationId := s.nextId()
+		translations[translationId] = &memoryTranslation{id: translationId, ownerId: id, field: field, language: t.Language, value: t.Value}
+	}
+
+	for _, t := range tmr.UpdatedTranslations {
+		if existing, ok := translations[t.Id]; ok && existing.ownerId == id {
+			existing.language = t.Language
+			existing.value = t.Value
+		}
+	}
+}
+
+func languageOf(ctx context.Context) string {
+	language, _ := ctx.Value(middleware.LanguageKey).(string)
+	return language
+}
+
+// sortableTime and sortablePosition format sort keys so that their text representation sorts like the original value.
+func sortableTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000000000")
+}
+
+func sortablePosition(position int) string {
+	return fmt.Sprintf("%011d", position)
+}
+
+// memoryPageItem is an item of a page together with its sort keys.
+type memoryPageItem[T any] struct {
+	item T
+	id   int
+	keys []string
+}
+
+// memoryPage sorts the items by their keys and returns the page after the cursor, like the SQL backends.
+func memoryPage[T any](items []memoryPageItem[T], keyCount int, pr domain.PageRequest) (domain.Page[T], error) {
+	less := func(a, b memoryPageItem[T]) bool {
+		if pr.Descending {
+			a, b = b, a
+		}
+		for i := range a.keys {
+			if a.keys[i] != b.keys[i] {
+				return a.keys[i] < b.keys[i]
+			}
+		}
+		return a.id < b.id
+	}
+	sort.Slice(items, func(i, j int) bool { return less(items[i], items[j]) })
+
+	if pr.Cursor != "" {
+		c, err := decodeCursor(pr.Cursor, keyCount)
+		if err != nil {
+			return domain.Page[T]{}, err
+		}
+
+		after := memoryPageItem[T]{id: c.Id, keys: c.Values}
+		remaining := make([]memoryPageItem[T], 0, len(items))
+		for _, item := range items {
+			if less(after, item) {
+				remaining = append(remaining, item)
+			}
+		}
+		items = remaining
+	}
+
+	limit := pageLimit(pr)
+	page := domain.Page[T]{Items: make([]T, 0, limit)}
+	for i, item := range items {
+		if i == limit {
+			last := items[i-1]
+			page.NextCursor = encodeCursor(cursor{Values: last.keys, Id: last.id})
+			break
+		}
+		page.Items = append(page.Items, item.item)
+	}
+	return page, nil
+}
@@ -0,0 +1,225 @@
+package persistence
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+const createVersionTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		appliedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)
+`
+
+// Migration is one step of the database schema. The files of a migration are named <version>_<name>.up.sql and <version>_<name>.down.sql.
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+// Migrator applies the migrations embedded in the binary. The applied versions are recorded in the table schema_migrations.
+type Migrator struct {
+	db         *sql.DB
+	dialect    dialect
+	migrations []Migration
+}
+
+func NewPsqlMigrator(db *sql.DB) (*Migrator, error) {
+	return newMigrator(db, postgresDialect)
+}
+
+func NewSqliteMigrator(db *sql.DB) (*Migrator, error) {
+	return newMigrator(db, sqliteDialect)
+}
+
+func newMigrator(db *sql.DB, d dialect) (*Migrator, error) {
+	migrations, err := loadMigrations(d.name)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: d, migrations: migrations}, nil
+}
+
+func loadMigrations(dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, path.Join("migrations", dir))
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		base, direction, ok := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), ".")
+		version, name, found := strings.Cut(base, "_")
+		number, err := strconv.Atoi(version)
+		if !ok || !found || err != nil || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("invalid migration file name %v", entry.Name())
+		}
+
+		content, err := migrationFiles.ReadFile(path.Join("migrations", dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, exists := byVersion[number]
+		if !exists {
+			migration = &Migration{Version: number, Name: name}
+			byVersion[number] = migration
+		}
+		if migration.Name != name {
+			return nil, fmt.Errorf("migration %v has the names %v and %v", number, migration.Name, name)
+		}
+
+		if direction == "up" {
+			migration.up = string(content)
+		} else {
+			migration.down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.up == "" || migration.down == "" {
+			return nil, fmt.Errorf("migration %v_%v needs an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrations returns all embedded migrations ordered by version.
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Version returns the version of the latest applied migration, 0 if there is none.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	var version int
+	err := m.inLockedTransaction(ctx, func(conn *sql.Conn) error {
+		var err error
+		version, err = currentVersion(ctx, conn)
+		return err
+	})
+	return version, err
+}
+
+// Up applies all migrations that have not been applied yet.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.inLockedTransaction(ctx, func(conn *sql.Conn) error {
+		version, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		if latest := m.migrations[len(m.migrations)-1].Version; version > latest {
+			slog.WarnContext(ctx, fmt.Sprintf("database schema version %v is newer than the latest known migration %v", version, latest))
+		}
+
+		for _, migration := range m.migrations {
+			if migration.Version <= version {
+				continue
+			}
+
+			slog.InfoContext(ctx, fmt.Sprintf("applying migration %v_%v", migration.Version, migration.Name))
+			_, err = conn.ExecContext(ctx, migration.up)
+			if err != nil {
+				return fmt.Errorf("migration %v_%v failed: %w", migration.Version, migration.Name, err)
+			}
+
+			_, err = conn.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", migration.Version, migration.Name)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Down reverts the given number of applied migrations, starting with the latest one.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.inLockedTransaction(ctx, func(conn *sql.Conn) error {
+		version, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			migration := m.migrations[i]
+			if migration.Version > version {
+				continue
+			}
+
+			slog.InfoContext(ctx, fmt.Sprintf("reverting migration %v_%v", migration.Version, migration.Name))
+			_, err = conn.ExecContext(ctx, migration.down)
+			if err != nil {
+				return fmt.Errorf("reverting migration %v_%v failed: %w", migration.Version, migration.Name, err)
+			}
+
+			_, err = conn.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+			if err != nil {
+				return err
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// inLockedTransaction runs migrate in one transaction, concurrent callers wait for each other.
+func (m *Migrator) inLockedTransaction(ctx context.Context, migrate func(*sql.Conn) error) error {
+	if len(m.migrations) == 0 {
+		return fmt.Errorf("there are no migrations for %v", m.dialect.name)
+	}
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for _, statement := range m.dialect.beginMigration {
+		_, err = conn.ExecContext(ctx, statement)
+		if err != nil {
+			rollback(conn)
+			return err
+		}
+	}
+
+	_, err = conn.ExecContext(ctx, createVersionTable)
+	if err == nil {
+		err = migrate(conn)
+	}
+	if err != nil {
+		rollback(conn)
+		return err
+	}
+
+	_, err = conn.ExecContext(ctx, "COMMIT")
+	return err
+}
+
+func rollback(conn *sql.Conn) {
+	// the context of the migration might be canceled already
+	_, _ = conn.ExecContext(context.Background(), "ROLLBACK")
+}
+
+func currentVersion(ctx context.Context, conn *sql.Conn) (int, error) {
+	var version int
+	err := conn.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	return version, err
+}
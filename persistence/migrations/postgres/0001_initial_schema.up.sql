@@ -0,0 +1,96 @@
+-- Installations that predate the migrations already have some of the tables and columns,
+-- so everything is created only if it does not exist yet.
+
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    email TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS models (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS model_user_relations (
+    modelId INTEGER NOT NULL REFERENCES models (id) ON DELETE CASCADE,
+    userId INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    PRIMARY KEY (modelId, userId)
+);
+
+CREATE TABLE IF NOT EXISTS model_translations (
+    id SERIAL PRIMARY KEY,
+    modelId INTEGER NOT NULL REFERENCES models (id) ON DELETE CASCADE,
+    language TEXT NOT NULL,
+    translation TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS parameters (
+    id SERIAL PRIMARY KEY,
+    modelId INTEGER NOT NULL REFERENCES models (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    valueType INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS parameter_translations (
+    id SERIAL PRIMARY KEY,
+    parameterId INTEGER NOT NULL REFERENCES parameters (id) ON DELETE CASCADE,
+    language TEXT NOT NULL,
+    translation TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS "values" (
+    id SERIAL PRIMARY KEY,
+    parameterId INTEGER NOT NULL REFERENCES parameters (id) ON DELETE CASCADE,
+    value TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS value_translations (
+    id SERIAL PRIMARY KEY,
+    valueId INTEGER NOT NULL REFERENCES "values" (id) ON DELETE CASCADE,
+    language TEXT NOT NULL,
+    translation TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS constraints (
+    id SERIAL PRIMARY KEY,
+    modelId INTEGER NOT NULL REFERENCES models (id) ON DELETE CASCADE,
+    constraintType INTEGER NOT NULL,
+    fromId INTEGER NOT NULL REFERENCES parameters (id) ON DELETE CASCADE,
+    fromValueId INTEGER REFERENCES "values" (id) ON DELETE CASCADE,
+    targetId INTEGER NOT NULL REFERENCES parameters (id) ON DELETE CASCADE,
+    targetValueId INTEGER REFERENCES "values" (id) ON DELETE CASCADE
+);
+
+-- parameter groups and explicit ordering
+
+CREATE TABLE IF NOT EXISTS parameter_groups (
+    id SERIAL PRIMARY KEY,
+    modelId INTEGER NOT NULL REFERENCES models (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS parameter_group_translations (
+    id SERIAL PRIMARY KEY,
+    groupId INTEGER NOT NULL REFERENCES parameter_groups (id) ON DELETE CASCADE,
+    language TEXT NOT NULL,
+    translation TEXT NOT NULL
+);
+
+ALTER TABLE parameters ADD COLUMN IF NOT EXISTS groupId INTEGER REFERENCES parameter_groups (id) ON DELETE SET NULL;
+ALTER TABLE parameters ADD COLUMN IF NOT EXISTS position INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE "values" ADD COLUMN IF NOT EXISTS position INTEGER NOT NULL DEFAULT 0;
+
+-- parameter metadata
+
+ALTER TABLE parameters ADD COLUMN IF NOT EXISTS unit TEXT;
+ALTER TABLE parameters ADD COLUMN IF NOT EXISTS required BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE parameters ADD COLUMN IF NOT EXISTS defaultValue TEXT;
+ALTER TABLE parameter_translations ADD COLUMN IF NOT EXISTS field TEXT NOT NULL DEFAULT 'name';
+
+-- timestamps for sorting
+
+ALTER TABLE models ADD COLUMN IF NOT EXISTS createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE models ADD COLUMN IF NOT EXISTS updatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE parameters ADD COLUMN IF NOT EXISTS createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE parameters ADD COLUMN IF NOT EXISTS updatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
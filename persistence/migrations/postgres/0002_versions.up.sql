@@ -0,0 +1,5 @@
+-- Versions for optimistic concurrency control. They start at 1, because 0 stands for "do not check the version".
+ALTER TABLE models ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE parameters ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE "values" ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE constraints ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
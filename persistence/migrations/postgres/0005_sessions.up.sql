@@ -0,0 +1,12 @@
+CREATE TABLE sessions (
+    id SERIAL PRIMARY KEY,
+    userId INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    refreshTokenHash TEXT NOT NULL,
+    previousRefreshTokenHash TEXT,
+    createdAt TIMESTAMP NOT NULL,
+    rotatedAt TIMESTAMP,
+    expiresAt TIMESTAMP NOT NULL,
+    revokedAt TIMESTAMP
+);
+
+CREATE INDEX sessions_expiry ON sessions (expiresAt);
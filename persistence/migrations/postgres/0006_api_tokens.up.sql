@@ -0,0 +1,14 @@
+CREATE TABLE api_tokens (
+    id SERIAL PRIMARY KEY,
+    userId INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    scopes TEXT NOT NULL,
+    tokenHash TEXT NOT NULL,
+    createdAt TIMESTAMP NOT NULL,
+    expiresAt TIMESTAMP NOT NULL,
+    lastUsedAt TIMESTAMP,
+    lastUsedFrom TEXT,
+    revokedAt TIMESTAMP
+);
+
+CREATE INDEX api_tokens_user ON api_tokens (userId);
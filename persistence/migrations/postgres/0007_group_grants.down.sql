@@ -0,0 +1 @@
+ALTER TABLE model_user_relations DROP COLUMN grantedByGroup;
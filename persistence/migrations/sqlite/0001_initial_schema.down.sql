@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS constraints;
+DROP TABLE IF EXISTS value_translations;
+DROP TABLE IF EXISTS "values";
+DROP TABLE IF EXISTS parameter_translations;
+DROP TABLE IF EXISTS parameters;
+DROP TABLE IF EXISTS parameter_group_translations;
+DROP TABLE IF EXISTS parameter_groups;
+DROP TABLE IF EXISTS model_translations;
+DROP TABLE IF EXISTS model_user_relations;
+DROP TABLE IF EXISTS models;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,86 @@
+-- The tables are created only if they do not exist yet, so databases created before the migrations are adopted.
+
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    email TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS models (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS model_user_relations (
+    modelId INTEGER NOT NULL REFERENCES models (id) ON DELETE CASCADE,
+    userId INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    PRIMARY KEY (modelId, userId)
+);
+
+CREATE TABLE IF NOT EXISTS model_translations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    modelId INTEGER NOT NULL REFERENCES models (id) ON DELETE CASCADE,
+    language TEXT NOT NULL,
+    translation TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS parameter_groups (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    modelId INTEGER NOT NULL REFERENCES models (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS parameter_group_translations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    groupId INTEGER NOT NULL REFERENCES parameter_groups (id) ON DELETE CASCADE,
+    language TEXT NOT NULL,
+    translation TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS parameters (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    modelId INTEGER NOT NULL REFERENCES models (id) ON DELETE CASCADE,
+    groupId INTEGER REFERENCES parameter_groups (id) ON DELETE SET NULL,
+    name TEXT NOT NULL,
+    valueType INTEGER NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    unit TEXT,
+    required BOOLEAN NOT NULL DEFAULT FALSE,
+    defaultValue TEXT,
+    createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS parameter_translations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    parameterId INTEGER NOT NULL REFERENCES parameters (id) ON DELETE CASCADE,
+    field TEXT NOT NULL DEFAULT 'name',
+    language TEXT NOT NULL,
+    translation TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS "values" (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    parameterId INTEGER NOT NULL REFERENCES parameters (id) ON DELETE CASCADE,
+    value TEXT NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS value_translations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    valueId INTEGER NOT NULL REFERENCES "values" (id) ON DELETE CASCADE,
+    language TEXT NOT NULL,
+    translation TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS constraints (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    modelId INTEGER NOT NULL REFERENCES models (id) ON DELETE CASCADE,
+    constraintType INTEGER NOT NULL,
+    fromId INTEGER NOT NULL REFERENCES parameters (id) ON DELETE CASCADE,
+    fromValueId INTEGER REFERENCES "values" (id) ON DELETE CASCADE,
+    targetId INTEGER NOT NULL REFERENCES parameters (id) ON DELETE CASCADE,
+    targetValueId INTEGER REFERENCES "values" (id) ON DELETE CASCADE
+);
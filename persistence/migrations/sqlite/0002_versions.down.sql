@@ -0,0 +1,4 @@
+ALTER TABLE constraints DROP COLUMN version;
+ALTER TABLE "values" DROP COLUMN version;
+ALTER TABLE parameters DROP COLUMN version;
+ALTER TABLE models DROP COLUMN version;
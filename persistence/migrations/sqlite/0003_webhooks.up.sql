@@ -0,0 +1,24 @@
+CREATE TABLE webhooks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    modelId INTEGER NOT NULL REFERENCES models (id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE webhook_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    webhookId INTEGER NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    lastError TEXT,
+    responseStatus INTEGER,
+    nextAttemptAt TIMESTAMP NOT NULL,
+    createdAt TIMESTAMP NOT NULL,
+    deliveredAt TIMESTAMP
+);
+
+-- the dispatcher polls for pending deliveries that are due
+CREATE INDEX webhook_deliveries_due ON webhook_deliveries (status, nextAttemptAt);
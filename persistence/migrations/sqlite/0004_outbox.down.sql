@@ -0,0 +1,3 @@
+DROP INDEX webhook_deliveries_idempotency;
+ALTER TABLE webhook_deliveries DROP COLUMN idempotencyKey;
+DROP TABLE outbox;
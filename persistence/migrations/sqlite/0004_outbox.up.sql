@@ -0,0 +1,16 @@
+CREATE TABLE outbox (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    modelId INTEGER NOT NULL REFERENCES models (id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    idempotencyKey TEXT NOT NULL UNIQUE,
+    createdAt TIMESTAMP NOT NULL,
+    publishedAt TIMESTAMP
+);
+
+-- the relay polls for pending events in the order they were appended
+CREATE INDEX outbox_pending ON outbox (id) WHERE publishedAt IS NULL;
+
+ALTER TABLE webhook_deliveries ADD COLUMN idempotencyKey TEXT;
+
+-- an event that is published more than once is only delivered once to every webhook
+CREATE UNIQUE INDEX webhook_deliveries_idempotency ON webhook_deliveries (webhookId, idempotencyKey);
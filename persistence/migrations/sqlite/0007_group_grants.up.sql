@@ -0,0 +1 @@
+ALTER TABLE model_user_relations ADD COLUMN grantedByGroup BOOLEAN NOT NULL DEFAULT FALSE;
@@ -3,30 +3,38 @@ package persistence
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 
 	configurationmodel "github.com/gossie/configuration-model"
 	"github.com/gossie/modelling-service/domain"
 	"github.com/gossie/modelling-service/middleware"
 )
 
-type psqlModelRepository struct {
-	db *sql.DB
+type sqlModelRepository struct {
+	db      session
+	dialect dialect
 }
 
-func NewPsqlModelRepository(db *sql.DB) psqlModelRepository {
-	return psqlModelRepository{db: db}
+func NewPsqlModelRepository(db *sql.DB) sqlModelRepository {
+	return sqlModelRepository{db: dbSession{db}, dialect: postgresDialect}
 }
 
-func (mr *psqlModelRepository) FindById(ctx context.Context, modelId int) (domain.Model, error) {
+func NewSqliteModelRepository(db *sql.DB) sqlModelRepository {
+	return sqlModelRepository{db: dbSession{db}, dialect: sqliteDialect}
+}
+
+func (mr *sqlModelRepository) FindById(ctx context.Context, modelId int) (domain.Model, error) {
 	sqlStatement := `
-		SELECT m.id, m.name, t.translation, c.id, c.constraintType, c.fromId, c.fromValueId, c.targetId, c.targetValueId FROM models m
+		SELECT m.id, m.name, m.version, t.translation, c.id, c.constraintType, c.fromId, c.fromValueId, c.targetId, c.targetValueId, c.version FROM models m
 		LEFT JOIN model_translations t
-		ON m.id = t.modelId
+		ON m.id = t.modelId AND t.language = $2
 		LEFT JOIN constraints c
 		ON c.modelId = m.id
-		WHERE m.id = $1 AND t.language = $2
+		WHERE m.id = $1
+		ORDER BY c.id
 	`
 	rows, err := mr.db.QueryContext(ctx, sqlStatement, modelId, ctx.Value(middleware.LanguageKey))
 	if err != nil {
@@ -35,19 +43,23 @@ func (mr *psqlModelRepository) FindById(ctx context.Context, modelId int) (domai
 	}
 	defer rows.Close()
 
+	found := false
 	var id int
 	var name string
+	var version int
 	var translation sql.NullString
 	constraints := make([]domain.Constraint, 0)
 	for rows.Next() {
+		found = true
 		var constraintId sql.NullInt32
 		var contraintType sql.NullInt32
 		var fromId sql.NullInt32
 		var fromValueId sql.NullInt32
 		var targetId sql.NullInt32
 		var targetValueId sql.NullInt32
+		var constraintVersion sql.NullInt32
 
-		rows.Scan(&id, &name, &translation, &constraintId, &contraintType, &fromId, &fromValueId, &targetId, &targetValueId)
+		rows.Scan(&id, &name, &version, &translation, &constraintId, &contraintType, &fromId, &fromValueId, &targetId, &targetValueId, &constraintVersion)
 
 		if constraintId.Valid {
 			constraints = append(constraints, domain.Constraint{
@@ -57,20 +69,39 @@ func (mr *psqlModelRepository) FindById(ctx context.Context, modelId int) (domai
 				FromValueId:   int(fromValueId.Int32),
 				TargetId:      int(targetId.Int32),
 				TargetValueId: int(targetValueId.Int32),
+				Version:       int(constraintVersion.Int32),
 			})
 		}
 	}
 
-	return domain.Model{Id: id, Name: name, Translation: translation.String, Constraints: constraints}, rows.Err()
+	if err = rows.Err(); err != nil {
+		return domain.Model{}, err
+	}
+	if !found {
+		return domain.Model{}, domain.ErrNotFound
+	}
+
+	return domain.Model{Id: id, Name: name, Translation: translation.String, Constraints: constraints, Version: version}, nil
+}
+
+func (mr *sqlModelRepository) HasAccess(ctx context.Context, modelId int, userEmail string) (bool, error) {
+	sqlStatement := `
+		SELECT COUNT(*)
+		FROM model_user_relations
+		WHERE modelId = $1 AND userId = (SELECT id FROM users WHERE email = $2)`
+
+	var count int
+	err := mr.db.QueryRowContext(ctx, sqlStatement, modelId, userEmail).Scan(&count)
+	return count > 0, err
 }
 
-func (mr *psqlModelRepository) FindAllByUser(ctx context.Context, userEmail string) ([]domain.Model, error) {
+func (mr *sqlModelRepository) FindAllByUser(ctx context.Context, userEmail string) ([]domain.Model, error) {
 	language := ctx.Value(middleware.LanguageKey)
 
 	slog.InfoContext(ctx, fmt.Sprintf("retrieving models for user %v and language %v", userEmail, language))
 
 	sqlStatement := `
-		SELECT m.id, m.name, t.translation
+		SELECT m.id, m.name, m.version, t.translation
 		FROM models m
 		LEFT JOIN model_user_relations mur
 		ON m.id = mur.modelid
@@ -88,34 +119,41 @@ func (mr *psqlModelRepository) FindAllByUser(ctx context.Context, userEmail stri
 	for rows.Next() {
 		var id int
 		var name string
+		var version int
 		var translation sql.NullString
-		rows.Scan(&id, &name, &translation)
-		models = append(models, domain.Model{Id: id, Name: name, Translation: translation.String, Constraints: make([]domain.Constraint, 0)})
+		rows.Scan(&id, &name, &version, &translation)
+		models = append(models, domain.Model{Id: id, Name: name, Translation: translation.String, Constraints: make([]domain.Constraint, 0), Version: version})
 	}
 
 	return models, rows.Err()
 }
 
-func (mr *psqlModelRepository) SaveModel(ctx context.Context, userEmail string, cmr domain.ModelCreationRequest) (int, error) {
-	tx, _ := mr.db.BeginTx(ctx, nil)
+func (mr *sqlModelRepository) SaveModel(ctx context.Context, userEmail string, cmr domain.ModelCreationRequest) (int, error) {
+	tx, err := mr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return -1, err
+	}
 
 	var userId int
 	row := tx.QueryRowContext(ctx, "SELECT id FROM users WHERE email = $1", userEmail)
-	err := row.Scan(&userId)
+	err = row.Scan(&userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = domain.ErrNotFound
+	}
 	if err != nil {
 		_ = tx.Rollback()
 		return -1, err
 	}
 
 	var modelId int
-	err = mr.db.QueryRowContext(ctx, "INSERT INTO models (name) VALUES ($1) RETURNING id", cmr.Name).Scan(&modelId)
+	err = tx.QueryRowContext(ctx, "INSERT INTO models (name) VALUES ($1) RETURNING id", cmr.Name).Scan(&modelId)
 	if err != nil {
 		_ = tx.Rollback()
 		return -1, err
 	}
 	slog.InfoContext(ctx, fmt.Sprintf("created model with ID %v", modelId))
 
-	_, err = mr.db.ExecContext(ctx, "INSERT INTO model_user_relations (modelId, userId) VALUES ($1, $2)", modelId, userId)
+	_, err = tx.ExecContext(ctx, "INSERT INTO model_user_relations (modelId, userId) VALUES ($1, $2)", modelId, userId)
 	if err != nil {
 		_ = tx.Rollback()
 		return -1, err
@@ -128,3 +166,174 @@ func (mr *psqlModelRepository) SaveModel(ctx context.Context, userEmail string,
 
 	return modelId, nil
 }
+
+func (mr *sqlModelRepository) SyncGroupGrants(ctx context.Context, userEmail string, modelIds []int) ([]int, error) {
+	tx, err := mr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	changed, err := syncGroupGrants(ctx, tx, userEmail, modelIds)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	return changed, tx.Commit()
+}
+
+func syncGroupGrants(ctx context.Context, tx transaction, userEmail string, modelIds []int) ([]int, error) {
+	var userId int
+	err := tx.QueryRowContext(ctx, "SELECT id FROM users WHERE email = $1", userEmail).Scan(&userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT modelId FROM model_user_relations WHERE userId = $1 AND grantedByGroup = $2", userId, true)
+	if err != nil {
+		return nil, err
+	}
+	granted := make([]int, 0)
+	for rows.Next() {
+		var modelId int
+		if err = rows.Scan(&modelId); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		granted = append(granted, modelId)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	changed := make([]int, 0)
+	for _, modelId := range granted {
+		if slices.Contains(modelIds, modelId) {
+			continue
+		}
+		_, err = tx.ExecContext(ctx, "DELETE FROM model_user_relations WHERE modelId = $1 AND userId = $2 AND grantedByGroup = $3", modelId, userId, true)
+		if err != nil {
+			return nil, err
+		}
+		changed = append(changed, modelId)
+	}
+	for _, modelId := range modelIds {
+		// an existing relation is kept as it is, the access of the creator must not turn into a grant of a group
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO model_user_relations (modelId, userId, grantedByGroup)
+			SELECT id, $2, $3 FROM models WHERE id = $1
+			ON CONFLICT DO NOTHING`, modelId, userId, true)
+		if err != nil {
+			return nil, err
+		}
+		if inserted, _ := result.RowsAffected(); inserted > 0 {
+			changed = append(changed, modelId)
+		}
+	}
+	return changed, nil
+}
+
+func (mr *sqlModelRepository) FindPageByUser(ctx context.Context, userEmail string, filter domain.ModelFilter, pr domain.PageRequest) (domain.Page[domain.Model], error) {
+	slog.InfoContext(ctx, fmt.Sprintf("retrieving page of models for user %v, sorted by %v", userEmail, pr.SortBy))
+
+	qb := queryBuilder{}
+	language := qb.arg(ctx.Value(middleware.LanguageKey))
+	qb.where("mur.userId = (SELECT id FROM users WHERE email = " + qb.arg(userEmail) + ")")
+
+	if filter.Untranslated {
+		qb.where("t.translation IS NULL")
+	}
+	if filter.HasConstraints {
+		qb.where("EXISTS (SELECT 1 FROM constraints c WHERE c.modelId = m.id)")
+	}
+
+	keys := modelKeyset(mr.dialect, pr)
+	err := keys.after(&qb, pr.Cursor)
+	if err != nil {
+		return domain.Page[domain.Model]{}, err
+	}
+
+	limit := pageLimit(pr)
+	sqlStatement := fmt.Sprintf(`
+		SELECT m.id, m.name, t.translation, m.createdAt, m.updatedAt, m.version, %v
+		FROM models m
+		JOIN model_user_relations mur
+		ON m.id = mur.modelId
+		LEFT JOIN model_translations t
+		ON m.id = t.modelId AND t.language = %v
+		%v
+		%v
+		LIMIT %v
+	`, keys.selectColumns(), language, qb.whereClause(), keys.orderBy(), limit+1)
+
+	rows, err := mr.db.QueryContext(ctx, sqlStatement, qb.args...)
+	if err != nil {
+		return domain.Page[domain.Model]{}, err
+	}
+	defer rows.Close()
+
+	models := make([]domain.Model, 0, limit)
+	var next cursor
+	for rows.Next() {
+		var model domain.Model
+		var translation sql.NullString
+		var sortValue string
+		err = rows.Scan(&model.Id, &model.Name, &translation, &model.CreatedAt, &model.UpdatedAt, &model.Version, &sortValue)
+		if err != nil {
+			return domain.Page[domain.Model]{}, err
+		}
+
+		if len(models) == limit {
+			return domain.Page[domain.Model]{Items: models, NextCursor: encodeCursor(next)}, rows.Err()
+		}
+
+		model.Translation = translation.String
+		model.Constraints = make([]domain.Constraint, 0)
+		models = append(models, model)
+		next = cursor{Values: []string{sortValue}, Id: model.Id}
+	}
+
+	return domain.Page[domain.Model]{Items: models}, rows.Err()
+}
+
+func modelKeyset(d dialect, pr domain.PageRequest) keyset {
+	var column string
+	switch pr.SortBy {
+	case domain.SortByName:
+		column = "COALESCE(t.translation, m.name)"
+	case domain.SortByUpdated:
+		column = d.sortableTimestamp("m.updatedAt")
+	default:
+		column = d.sortableTimestamp("m.createdAt")
+	}
+	return keyset{columns: []string{column}, id: "m.id", descending: pr.Descending}
+}
+
+func (mr *sqlModelRepository) Statistics(ctx context.Context) ([]domain.ModelStatistics, error) {
+	sqlStatement := `
+		SELECT m.id,
+			(SELECT COUNT(*) FROM parameters p WHERE p.modelId = m.id),
+			(SELECT COUNT(*) FROM constraints c WHERE c.modelId = m.id)
+		FROM models m
+		ORDER BY m.id
+	`
+	rows, err := mr.db.QueryContext(ctx, sqlStatement)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	statistics := make([]domain.ModelStatistics, 0)
+	for rows.Next() {
+		var s domain.ModelStatistics
+		err = rows.Scan(&s.ModelId, &s.Parameters, &s.Constraints)
+		if err != nil {
+			return nil, err
+		}
+		statistics = append(statistics, s)
+	}
+	return statistics, rows.Err()
+}
@@ -0,0 +1,128 @@
+package persistence
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gossie/modelling-service/domain"
+)
+
+type sqlOutboxRepository struct {
+	db      session
+	dialect dialect
+}
+
+func (or *sqlOutboxRepository) Append(ctx context.Context, modelId int, kind string, idempotencyKey string) error {
+	sqlStatement := "INSERT INTO outbox (modelId, kind, idempotencyKey, createdAt) VALUES ($1, $2, $3, $4)"
+	_, err := or.db.ExecContext(ctx, sqlStatement, modelId, kind, idempotencyKey, time.Now().UTC())
+	return err
+}
+
+func (or *sqlOutboxRepository) FindPending(ctx context.Context, limit int, excludedModelIds []int) ([]domain.OutboxEvent, error) {
+	qb := queryBuilder{}
+	qb.where("publishedAt IS NULL")
+	if len(excludedModelIds) > 0 {
+		placeholders := make([]string, len(excludedModelIds))
+		for i, modelId := range excludedModelIds {
+			placeholders[i] = qb.arg(modelId)
+		}
+		qb.where(fmt.Sprintf("modelId NOT IN (%v)", strings.Join(placeholders, ", ")))
+	}
+	sqlStatement := fmt.Sprintf(`
+		SELECT id, modelId, kind, idempotencyKey, createdAt
+		FROM outbox
+		%v
+		ORDER BY id
+		LIMIT %v
+	`, qb.whereClause(), qb.arg(limit))
+	rows, err := or.db.QueryContext(ctx, sqlStatement, qb.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pending := make([]domain.OutboxEvent, 0)
+	for rows.Next() {
+		var event domain.OutboxEvent
+		err = rows.Scan(&event.Id, &event.ModelId, &event.Kind, &event.IdempotencyKey, &event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, event)
+	}
+	return pending, rows.Err()
+}
+
+func (or *sqlOutboxRepository) FindAfter(ctx context.Context, eventId int, limit int) ([]domain.OutboxEvent, error) {
+	sqlStatement := `
+		SELECT id, modelId, kind, idempotencyKey, createdAt
+		FROM outbox
+		WHERE id > $1
+		ORDER BY id
+		LIMIT $2
+	`
+	rows, err := or.db.QueryContext(ctx, sqlStatement, eventId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]domain.OutboxEvent, 0)
+	for rows.Next() {
+		var event domain.OutboxEvent
+		err = rows.Scan(&event.Id, &event.ModelId, &event.Kind, &event.IdempotencyKey, &event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+func (or *sqlOutboxRepository) LastId(ctx context.Context) (int, error) {
+	var lastId int
+	err := or.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(id), 0) FROM outbox").Scan(&lastId)
+	return lastId, err
+}
+
+func (or *sqlOutboxRepository) MarkPublished(ctx context.Context, eventId int, publishedAt time.Time) error {
+	result, err := or.db.ExecContext(ctx, "UPDATE outbox SET publishedAt = $1 WHERE id = $2", publishedAt.UTC(), eventId)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (or *sqlOutboxRepository) PendingStats(ctx context.Context) (int, time.Time, error) {
+	var count int
+	err := or.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM outbox WHERE publishedAt IS NULL").Scan(&count)
+	if err != nil || count == 0 {
+		return count, time.Time{}, err
+	}
+
+	// the events are appended in the order of their IDs, so the first pending one is the oldest
+	var oldest time.Time
+	err = or.db.QueryRowContext(ctx, "SELECT createdAt FROM outbox WHERE publishedAt IS NULL ORDER BY id LIMIT 1").Scan(&oldest)
+	if errors.Is(err, sql.ErrNoRows) {
+		// published in the meantime
+		return 0, time.Time{}, nil
+	}
+	return count, oldest, err
+}
+
+func (or *sqlOutboxRepository) DeletePublished(ctx context.Context, before time.Time) (int, error) {
+	sqlStatement := fmt.Sprintf("DELETE FROM outbox WHERE publishedAt IS NOT NULL AND %v < %v", or.dialect.sortableTimestamp("publishedAt"), or.dialect.sortableTimestamp("$1"))
+	result, err := or.db.ExecContext(ctx, sqlStatement, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	return int(deleted), err
+}
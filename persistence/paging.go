@@ -0,0 +1,113 @@
+package persistence
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gossie/modelling-service/domain"
+)
+
+// cursor points behind the last item of a page with its sort keys, Id breaks ties.
+type cursor struct {
+	Values []string `json:"v"`
+	Id     int      `json:"id"`
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(encoded string, keys int) (cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return cursor{}, domain.ErrInvalidCursor
+	}
+
+	var c cursor
+	err = json.Unmarshal(data, &c)
+	if err != nil || len(c.Values) != keys {
+		return cursor{}, domain.ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// queryBuilder collects the arguments of a dynamically built statement and hands out their placeholders.
+type queryBuilder struct {
+	conditions []string
+	args       []any
+}
+
+func (qb *queryBuilder) arg(value any) string {
+	qb.args = append(qb.args, value)
+	return fmt.Sprintf("$%v", len(qb.args))
+}
+
+func (qb *queryBuilder) where(condition string) {
+	qb.conditions = append(qb.conditions, condition)
+}
+
+func (qb *queryBuilder) whereClause() string {
+	if len(qb.conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(qb.conditions, " AND ")
+}
+
+// keyset describes the sort keys of a paged query, the id column is always the last key.
+type keyset struct {
+	columns    []string
+	id         string
+	descending bool
+}
+
+func (k keyset) after(qb *queryBuilder, encodedCursor string) error {
+	if encodedCursor == "" {
+		return nil
+	}
+
+	c, err := decodeCursor(encodedCursor, len(k.columns))
+	if err != nil {
+		return err
+	}
+
+	placeholders := make([]string, 0, len(k.columns)+1)
+	for _, value := range c.Values {
+		placeholders = append(placeholders, qb.arg(value))
+	}
+	placeholders = append(placeholders, qb.arg(c.Id))
+
+	operator := ">"
+	if k.descending {
+		operator = "<"
+	}
+	qb.where(fmt.Sprintf("(%v, %v) %v (%v)", strings.Join(k.columns, ", "), k.id, operator, strings.Join(placeholders, ", ")))
+	return nil
+}
+
+func (k keyset) orderBy() string {
+	direction := "ASC"
+	if k.descending {
+		direction = "DESC"
+	}
+
+	columns := make([]string, 0, len(k.columns)+1)
+	for _, column := range k.columns {
+		columns = append(columns, column+" "+direction)
+	}
+	columns = append(columns, k.id+" "+direction)
+	return "ORDER BY " + strings.Join(columns, ", ")
+}
+
+func (k keyset) selectColumns() string {
+	return strings.Join(k.columns, ", ")
+}
+
+func pageLimit(pr domain.PageRequest) int {
+	if pr.Limit <= 0 {
+		return domain.DefaultPageSize
+	}
+	return min(pr.Limit, domain.MaxPageSize)
+}
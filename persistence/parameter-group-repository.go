@@ -0,0 +1,223 @@
+package persistence
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/gossie/modelling-service/domain"
+	"github.com/gossie/modelling-service/middleware"
+)
+
+type sqlParameterGroupRepository struct {
+	db session
+}
+
+func NewPsqlParameterGroupRepository(db *sql.DB) sqlParameterGroupRepository {
+	return sqlParameterGroupRepository{db: dbSession{db}}
+}
+
+func NewSqliteParameterGroupRepository(db *sql.DB) sqlParameterGroupRepository {
+	return sqlParameterGroupRepository{db: dbSession{db}}
+}
+
+func (gr *sqlParameterGroupRepository) FindAllByModelId(ctx context.Context, modelId int) ([]domain.ParameterGroup, error) {
+	slog.InfoContext(ctx, fmt.Sprintf("retrieving all parameter groups of model with ID %v", modelId))
+
+	sqlStatement := `
+		SELECT g.id, g.name, g.position, gt.translation
+		FROM parameter_groups g
+		LEFT JOIN parameter_group_translations gt
+		ON g.id = gt.groupId AND gt.language = $2
+		WHERE g.modelId = $1
+		ORDER BY g.position, g.id
+	`
+	rows, err := gr.db.QueryContext(ctx, sqlStatement, modelId, ctx.Value(middleware.LanguageKey))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := make([]domain.ParameterGroup, 0)
+	for rows.Next() {
+		var id int
+		var name string
+		var position int
+		var translation sql.NullString
+		err = rows.Scan(&id, &name, &position, &translation)
+		if err != nil {
+			return nil, err
+		}
+
+		groups = append(groups, domain.ParameterGroup{Id: id, Name: name, Translation: translation.String, Position: position})
+	}
+
+	return groups, rows.Err()
+}
+
+func (gr *sqlParameterGroupRepository) SaveGroup(ctx context.Context, modelId int, gcr domain.ParameterGroupCreationRequest) (int, error) {
+	var groupId int
+	sqlStatement := `
+		INSERT INTO parameter_groups (name, modelId, position)
+		VALUES ($1, $2, (SELECT COALESCE(MAX(position), -1) + 1 FROM parameter_groups WHERE modelId = $2))
+		RETURNING id
+	`
+	err := gr.db.QueryRowContext(ctx, sqlStatement, gcr.Name, modelId).Scan(&groupId)
+	return groupId, err
+}
+
+func (gr *sqlParameterGroupRepository) DeleteGroup(ctx context.Context, modelId, groupId int) error {
+	tx, err := gr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE parameters SET groupId = NULL WHERE groupId = $1 AND modelId = $2", groupId, modelId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM parameter_group_translations WHERE groupId = (SELECT id FROM parameter_groups WHERE id = $1 AND modelId = $2)", groupId, modelId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM parameter_groups WHERE id = $1 AND modelId = $2", groupId, modelId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (gr *sqlParameterGroupRepository) FindAllTranslations(ctx context.Context, modelId, groupId int) ([]domain.Translation, error) {
+	err := requireOfModel(ctx, gr.db, "parameter_groups", modelId, groupId)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlStatement := `
+		SELECT id, language, translation
+		FROM parameter_group_translations
+		WHERE groupId = $1
+	`
+	rows, err := gr.db.QueryContext(ctx, sqlStatement, groupId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	translations := make([]domain.Translation, 0)
+
+	for rows.Next() {
+		var id int
+		var language string
+		var value string
+		err = rows.Scan(&id, &language, &value)
+		if err != nil {
+			return nil, err
+		}
+
+		translations = append(translations, domain.Translation{Id: id, Field: "name", Language: language, Value: value})
+	}
+
+	return translations, rows.Err()
+}
+
+func (gr *sqlParameterGroupRepository) SaveTranslations(ctx context.Context, modelId, groupId int, tmr domain.TranslationModificationRequest) error {
+	tx, err := gr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = requireOfModel(ctx, tx, "parameter_groups", modelId, groupId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if len(tmr.NewTranslations) > 0 {
+		args := make([]any, 0, len(tmr.NewTranslations)*3)
+		valueStrings := make([]string, 0, len(tmr.NewTranslations))
+		for _, translation := range tmr.NewTranslations {
+			valueStrings = append(valueStrings, fmt.Sprintf("($%v, $%v, $%v)", len(args)+1, len(args)+2, len(args)+3))
+			args = append(args, groupId, translation.Language, translation.Value)
+		}
+
+		sqlStatement := `
+			INSERT INTO parameter_group_translations (groupId, language, translation)
+			VALUES ` + strings.Join(valueStrings, ", ")
+		_, err = tx.ExecContext(ctx, sqlStatement, args...)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	for _, translation := range tmr.UpdatedTranslations {
+		sqlStatement := `
+			UPDATE parameter_group_translations
+			SET language = $1, translation = $2
+			WHERE id = $3 AND groupId = $4
+		`
+		_, err = tx.ExecContext(ctx, sqlStatement, translation.Language, translation.Value, translation.Id, groupId)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (gr *sqlParameterGroupRepository) SaveLayout(ctx context.Context, modelId int, layout []domain.ParameterLayout) error {
+	slog.InfoContext(ctx, fmt.Sprintf("saving parameter layout of model with ID %v", modelId))
+
+	tx, err := gr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	groupPosition := 0
+	for _, group := range layout {
+		var groupId sql.NullInt32
+		if group.GroupId != 0 {
+			groupId = sql.NullInt32{Int32: int32(group.GroupId), Valid: true}
+
+			result, err := tx.ExecContext(ctx, "UPDATE parameter_groups SET position = $1 WHERE id = $2 AND modelId = $3", groupPosition, group.GroupId, modelId)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+			// otherwise the parameters would be moved into a group of another model
+			if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+				tx.Rollback()
+				if err != nil {
+					return err
+				}
+				return domain.ErrNotFound
+			}
+			groupPosition++
+		}
+
+		for position, parameterId := range group.ParameterIds {
+			sqlStatement := `
+				UPDATE parameters
+				SET groupId = $1, position = $2
+				WHERE id = $3 AND modelId = $4
+			`
+			_, err = tx.ExecContext(ctx, sqlStatement, groupId, position, parameterId, modelId)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
@@ -6,21 +6,41 @@ import (
 	"fmt"
 	"log/slog"
 	"strings"
+	"time"
 
 	configurationmodel "github.com/gossie/configuration-model"
 	"github.com/gossie/modelling-service/domain"
 	"github.com/gossie/modelling-service/middleware"
 )
 
-type psqlParameterRepository struct {
-	db *sql.DB
+// selectParameters joins the parameters, their values and the translations in the language passed as $1.
+const selectParameters = `
+	SELECT p.id, p.name, p.valueType, p.groupId, p.position, p.unit, p.required, p.defaultValue, p.createdAt, p.updatedAt, p.version, pt.translation, pd.translation, v.id, v.value, v.position, v.version, vt.translation
+	FROM parameters p
+	LEFT JOIN parameter_translations pt
+	ON p.id = pt.parameterId AND pt.field = 'name' AND pt.language = $1
+	LEFT JOIN parameter_translations pd
+	ON p.id = pd.parameterId AND pd.field = 'description' AND pd.language = $1
+	LEFT JOIN "values" v
+	ON v.parameterId = p.id
+	LEFT JOIN value_translations vt
+	ON vt.valueId = v.id AND vt.language = $1
+`
+
+type sqlParameterRepository struct {
+	db      session
+	dialect dialect
+}
+
+func NewPsqlParameterRepository(db *sql.DB) sqlParameterRepository {
+	return sqlParameterRepository{db: dbSession{db}, dialect: postgresDialect}
 }
 
-func NewPsqlParameterRepository(db *sql.DB) psqlParameterRepository {
-	return psqlParameterRepository{db: db}
+func NewSqliteParameterRepository(db *sql.DB) sqlParameterRepository {
+	return sqlParameterRepository{db: dbSession{db}, dialect: sqliteDialect}
 }
 
-func (pr *psqlParameterRepository) FindAllByModelId(ctx context.Context, modelId int, searchValue string) ([]domain.Parameter, error) {
+func (pr *sqlParameterRepository) FindAllByModelId(ctx context.Context, modelId int, searchValue string) ([]domain.Parameter, error) {
 	if searchValue == "*" {
 		searchValue = ""
 	}
@@ -33,57 +53,215 @@ func (pr *psqlParameterRepository) FindAllByModelId(ctx context.Context, modelId
 	if searchValue == "" {
 		slog.InfoContext(ctx, fmt.Sprintf("retrieving all parameters of model with ID %v", modelId))
 
-		sqlStatement := `
-			SELECT p.id, p.name, p.valueType, pt.translation, v.id, v.value, vt.translation
-			FROM parameters p
-			LEFT JOIN parameter_translations pt
-			ON p.id = pt.parameterId
-			LEFT JOIN values v
-			ON v.parameterId = p.id
-			LEFT JOIN value_translations vt
-			ON vt.valueId = v.id
-			WHERE p.modelId = $1
-			AND (pt.language = $2 OR pt.language IS NULL)
-			AND (vt.language = $2 OR vt.language IS NULL)
-			ORDER BY p.id
+		sqlStatement := selectParameters + `
+			WHERE p.modelId = $2
+			ORDER BY p.position, p.id, v.position, v.id
 		`
-		rows, err = pr.db.QueryContext(ctx, sqlStatement, modelId, ctx.Value(middleware.LanguageKey))
+		rows, err = pr.db.QueryContext(ctx, sqlStatement, ctx.Value(middleware.LanguageKey), modelId)
 	} else {
 		slog.InfoContext(ctx, fmt.Sprintf("searching for parameters containing '%v' at model with ID %v", searchValue, modelId))
 
-		sqlStatement := `
-			SELECT p.id, p.name, p.valueType, pt.translation, v.id, v.value, vt.translation
-			FROM parameters p
-			LEFT JOIN parameter_translations pt
-			ON p.id = pt.parameterId
-			LEFT JOIN values v
-			ON v.parameterId = p.id
-			LEFT JOIN value_translations vt
-			ON vt.valueId = v.id
-			WHERE p.modelId = $1
-			AND (pt.language = $2 OR pt.language IS NULL)
-			AND (vt.language = $2 OR vt.language IS NULL)
+		sqlStatement := selectParameters + `
+			WHERE p.modelId = $2
 			AND (p.name LIKE '%' || $3 || '%' OR pt.translation LIKE '%' || $3 || '%')
-			ORDER BY p.id
+			ORDER BY p.position, p.id, v.position, v.id
 		`
-		rows, err = pr.db.QueryContext(ctx, sqlStatement, modelId, ctx.Value(middleware.LanguageKey), searchValue)
+		rows, err = pr.db.QueryContext(ctx, sqlStatement, ctx.Value(middleware.LanguageKey), modelId, searchValue)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanParameters(rows)
+}
+
+func (pr *sqlParameterRepository) FindPageByModelId(ctx context.Context, modelId int, filter domain.ParameterFilter, pageRequest domain.PageRequest) (domain.Page[domain.Parameter], error) {
+	slog.InfoContext(ctx, fmt.Sprintf("retrieving page of parameters of model with ID %v, sorted by %v", modelId, pageRequest.SortBy))
+
+	qb := queryBuilder{}
+	language := qb.arg(ctx.Value(middleware.LanguageKey))
+	qb.where("p.modelId = " + qb.arg(modelId))
+
+	if filter.SearchValue != "" && filter.SearchValue != "*" {
+		searchValue := qb.arg(filter.SearchValue)
+		qb.where(fmt.Sprintf("(p.name LIKE '%%' || %v || '%%' OR pt.translation LIKE '%%' || %v || '%%')", searchValue, searchValue))
+	}
+	if filter.ValueType != nil {
+		qb.where("p.valueType = " + qb.arg(*filter.ValueType))
+	}
+	if filter.HasValues != nil {
+		qb.where(negateUnless(*filter.HasValues, `EXISTS (SELECT 1 FROM "values" v WHERE v.parameterId = p.id)`))
+	}
+	if filter.HasConstraints != nil {
+		qb.where(negateUnless(*filter.HasConstraints, "EXISTS (SELECT 1 FROM constraints c WHERE c.fromId = p.id OR c.targetId = p.id)"))
+	}
+	if filter.Untranslated {
+		qb.where("pt.translation IS NULL")
+	}
+
+	keys := parameterKeyset(pr.dialect, pageRequest)
+	err := keys.after(&qb, pageRequest.Cursor)
+	if err != nil {
+		return domain.Page[domain.Parameter]{}, err
+	}
+
+	limit := pageLimit(pageRequest)
+	sqlStatement := fmt.Sprintf(`
+		SELECT p.id, %v
+		FROM parameters p
+		LEFT JOIN parameter_groups g
+		ON g.id = p.groupId
+		LEFT JOIN parameter_translations pt
+		ON p.id = pt.parameterId AND pt.field = 'name' AND pt.language = %v
+		%v
+		%v
+		LIMIT %v
+	`, keys.selectColumns(), language, qb.whereClause(), keys.orderBy(), limit+1)
+
+	rows, err := pr.db.QueryContext(ctx, sqlStatement, qb.args...)
+	if err != nil {
+		return domain.Page[domain.Parameter]{}, err
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0, limit)
+	var next cursor
+	nextCursor := ""
+	for rows.Next() {
+		var id int
+		sortValues := make([]string, len(keys.columns))
+		dest := []any{&id}
+		for i := range sortValues {
+			dest = append(dest, &sortValues[i])
+		}
+
+		err = rows.Scan(dest...)
+		if err != nil {
+			return domain.Page[domain.Parameter]{}, err
+		}
+
+		if len(ids) == limit {
+			nextCursor = encodeCursor(next)
+			break
+		}
+		ids = append(ids, id)
+		next = cursor{Values: sortValues, Id: id}
+	}
+	if err = rows.Err(); err != nil {
+		return domain.Page[domain.Parameter]{}, err
+	}
+
+	parameters, err := pr.findAllByIds(ctx, ids)
+	if err != nil {
+		return domain.Page[domain.Parameter]{}, err
+	}
+	return domain.Page[domain.Parameter]{Items: parameters, NextCursor: nextCursor}, nil
+}
+
+// findAllByIds loads the parameters with the given IDs and returns them in the order of the IDs.
+func (pr *sqlParameterRepository) findAllByIds(ctx context.Context, ids []int) ([]domain.Parameter, error) {
+	if len(ids) == 0 {
+		return make([]domain.Parameter, 0), nil
+	}
+
+	qb := queryBuilder{}
+	qb.arg(ctx.Value(middleware.LanguageKey))
+	placeholders := make([]string, len(ids))
+	for i, id := range ids {
+		placeholders[i] = qb.arg(id)
 	}
 
+	sqlStatement := selectParameters + `
+		WHERE p.id IN (` + strings.Join(placeholders, ", ") + `)
+		ORDER BY p.id, v.position, v.id
+	`
+	rows, err := pr.db.QueryContext(ctx, sqlStatement, qb.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	parameters, err := scanParameters(rows)
 	if err != nil {
 		return nil, err
 	}
+
+	byId := make(map[int]domain.Parameter, len(parameters))
+	for _, parameter := range parameters {
+		byId[parameter.Id] = parameter
+	}
+
+	ordered := make([]domain.Parameter, 0, len(ids))
+	for _, id := range ids {
+		if parameter, ok := byId[id]; ok {
+			ordered = append(ordered, parameter)
+		}
+	}
+	return ordered, nil
+}
+
+func parameterKeyset(d dialect, pr domain.PageRequest) keyset {
+	var columns []string
+	switch pr.SortBy {
+	case domain.SortByName:
+		columns = []string{"COALESCE(pt.translation, p.name)"}
+	case domain.SortByCreated:
+		columns = []string{d.sortableTimestamp("p.createdAt")}
+	case domain.SortByUpdated:
+		columns = []string{d.sortableTimestamp("p.updatedAt")}
+	default:
+		// parameters without a group are listed after all groups
+		columns = []string{d.sortableInteger("COALESCE(g.position, 2147483647)"), d.sortableInteger("p.position")}
+	}
+	return keyset{columns: columns, id: "p.id", descending: pr.Descending}
+}
+
+func (pr *sqlParameterRepository) FindById(ctx context.Context, modelId, parameterId int) (domain.Parameter, error) {
+	slog.InfoContext(ctx, fmt.Sprintf("retrieving parameter with ID %v of model with ID %v", parameterId, modelId))
+
+	sqlStatement := selectParameters + `
+		WHERE p.modelId = $2 AND p.id = $3
+		ORDER BY v.position, v.id
+	`
+	rows, err := pr.db.QueryContext(ctx, sqlStatement, ctx.Value(middleware.LanguageKey), modelId, parameterId)
+	if err != nil {
+		return domain.Parameter{}, err
+	}
 	defer rows.Close()
 
+	parameters, err := scanParameters(rows)
+	if err != nil {
+		return domain.Parameter{}, err
+	}
+	if len(parameters) == 0 {
+		return domain.Parameter{}, domain.ErrNotFound
+	}
+	return parameters[0], nil
+}
+
+func scanParameters(rows *sql.Rows) ([]domain.Parameter, error) {
 	parameters := make([]domain.Parameter, 0)
 	for rows.Next() {
 		var id int
 		var name string
 		var valueType configurationmodel.ValueType
+		var groupId sql.NullInt32
+		var position int
+		var unit sql.NullString
+		var required bool
+		var defaultValue sql.NullString
+		var createdAt, updatedAt time.Time
+		var version int
 		var paramTranslation sql.NullString
+		var paramDescription sql.NullString
 		var valueId sql.NullInt32
 		var paramValue sql.NullString
+		var valuePosition sql.NullInt32
+		var valueVersion sql.NullInt32
 		var valueTranslation sql.NullString
-		err = rows.Scan(&id, &name, &valueType, &paramTranslation, &valueId, &paramValue, &valueTranslation)
+		err := rows.Scan(&id, &name, &valueType, &groupId, &position, &unit, &required, &defaultValue, &createdAt, &updatedAt, &version, &paramTranslation, &paramDescription, &valueId, &paramValue, &valuePosition, &valueVersion, &valueTranslation)
 		if err != nil {
 			return nil, err
 		}
@@ -98,30 +276,114 @@ func (pr *psqlParameterRepository) FindAllByModelId(ctx context.Context, modelId
 				Value: domain.ParameterValue{
 					Values: make([]domain.Value, 0),
 				},
+				GroupId:      int(groupId.Int32),
+				Position:     position,
+				Description:  paramDescription.String,
+				Unit:         unit.String,
+				Required:     required,
+				DefaultValue: defaultValue.String,
+				CreatedAt:    createdAt,
+				UpdatedAt:    updatedAt,
+				Version:      version,
 			})
 			lastIndex++
 		}
 
 		if valueId.Valid {
-			parameters[lastIndex].Value.Values = append(parameters[lastIndex].Value.Values, domain.Value{Id: int(valueId.Int32), Value: paramValue.String, Translation: valueTranslation.String})
+			parameters[lastIndex].Value.Values = append(parameters[lastIndex].Value.Values, domain.Value{Id: int(valueId.Int32), Value: paramValue.String, Translation: valueTranslation.String, Position: int(valuePosition.Int32), Version: int(valueVersion.Int32)})
 		}
 	}
 
-	return parameters, nil
+	return parameters, rows.Err()
 }
 
-func (pr *psqlParameterRepository) SaveParameter(ctx context.Context, modelId int, pmr domain.ParameterCreationRequest) (int, error) {
+func (pr *sqlParameterRepository) SaveParameter(ctx context.Context, modelId int, pmr domain.ParameterCreationRequest) (int, error) {
 	var parameterId int
-	err := pr.db.QueryRowContext(ctx, "INSERT INTO parameters (name, valueType, modelId) VALUES ($1, $2, $3) RETURNING id", pmr.Name, pmr.ValueType, modelId).Scan(&parameterId)
-	return parameterId, err
+	sqlStatement := `
+		INSERT INTO parameters (name, valueType, modelId, position)
+		VALUES ($1, $2, $3, (SELECT COALESCE(MAX(position), -1) + 1 FROM parameters WHERE modelId = $3))
+		RETURNING id
+	`
+	err := pr.db.QueryRowContext(ctx, sqlStatement, pmr.Name, pmr.ValueType, modelId).Scan(&parameterId)
+	if err != nil {
+		return -1, err
+	}
+
+	return parameterId, touchModel(ctx, pr.db, modelId)
 }
 
-func (pr *psqlParameterRepository) DeleteParameter(ctx context.Context, modelId int, parameterId int) error {
-	_, err := pr.db.ExecContext(ctx, "DELETE FROM parameters WHERE id = $1 AND modelId = $2", parameterId, modelId)
-	return err
+func (pr *sqlParameterRepository) DeleteParameter(ctx context.Context, modelId int, parameterId int, expectedVersion int) error {
+	if expectedVersion == 0 {
+		_, err := pr.db.ExecContext(ctx, "DELETE FROM parameters WHERE id = $1 AND modelId = $2", parameterId, modelId)
+		if err != nil {
+			return err
+		}
+		return touchModel(ctx, pr.db, modelId)
+	}
+
+	result, err := pr.db.ExecContext(ctx, "DELETE FROM parameters WHERE id = $1 AND modelId = $2 AND version = $3", parameterId, modelId, expectedVersion)
+	if err != nil {
+		return err
+	}
+	err = conflictUnlessAffected(ctx, pr.db, result, "SELECT COUNT(*) FROM parameters WHERE id = $1 AND modelId = $2", parameterId, modelId)
+	if err != nil {
+		return err
+	}
+
+	return touchModel(ctx, pr.db, modelId)
+}
+
+func (pr *sqlParameterRepository) UpdateParameter(ctx context.Context, modelId, parameterId int, pmr domain.ParameterModificationRequest) error {
+	tx, err := pr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	sqlStatement := `
+		UPDATE parameters
+		SET unit = $1, required = $2, defaultValue = $3, updatedAt = CURRENT_TIMESTAMP, version = version + 1
+		WHERE id = $4 AND modelId = $5
+	`
+	args := []any{nullString(pmr.Unit), pmr.Required, nullString(pmr.DefaultValue), parameterId, modelId}
+	if pmr.Version != 0 {
+		sqlStatement += " AND version = $6"
+		args = append(args, pmr.Version)
+	}
+	result, err := tx.ExecContext(ctx, sqlStatement, args...)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = conflictUnlessAffected(ctx, tx, result, "SELECT COUNT(*) FROM parameters WHERE id = $1 AND modelId = $2", parameterId, modelId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	language := ctx.Value(middleware.LanguageKey)
+	_, err = tx.ExecContext(ctx, "DELETE FROM parameter_translations WHERE parameterId = $1 AND field = 'description' AND language = $2", parameterId, language)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if pmr.Description != "" {
+		_, err = tx.ExecContext(ctx, "INSERT INTO parameter_translations (parameterId, field, language, translation) VALUES ($1, 'description', $2, $3)", parameterId, language, pmr.Description)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
-func (pr *psqlParameterRepository) FindAllTranslations(ctx context.Context, parameterId string) ([]domain.Translation, error) {
+func (pr *sqlParameterRepository) FindAllTranslations(ctx context.Context, modelId, parameterId int) ([]domain.Translation, error) {
+	err := requireOfModel(ctx, pr.db, "parameters", modelId, parameterId)
+	if err != nil {
+		return nil, err
+	}
+
 	sqlStatement := `
 		SELECT id, field, language, translation
 		FROM parameter_translations
@@ -148,12 +410,18 @@ func (pr *psqlParameterRepository) FindAllTranslations(ctx context.Context, para
 	return translations, rows.Err()
 }
 
-func (pr *psqlParameterRepository) SaveTranslations(ctx context.Context, parameterId string, tmr domain.TranslationModificationRequest) error {
+func (pr *sqlParameterRepository) SaveTranslations(ctx context.Context, modelId, parameterId int, tmr domain.TranslationModificationRequest) error {
 	tx, err := pr.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
+	err = touchParameter(ctx, tx, modelId, parameterId, tmr.Version)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	if len(tmr.NewTranslations) > 0 {
 		args := make([]any, 0, len(tmr.NewTranslations)*4)
 		valueStrings := make([]string, 0, len(tmr.NewTranslations))
@@ -177,9 +445,9 @@ func (pr *psqlParameterRepository) SaveTranslations(ctx context.Context, paramet
 			sqlStatement := `
 				UPDATE parameter_translations
 				SET language = $1, translation = $2
-				WHERE id = $3
+				WHERE id = $3 AND parameterId = $4
 			`
-			_, err = tx.ExecContext(ctx, sqlStatement, translation.Language, translation.Value, translation.Id)
+			_, err = tx.ExecContext(ctx, sqlStatement, translation.Language, translation.Value, translation.Id, parameterId)
 			if err != nil {
 				tx.Rollback()
 				return err
@@ -190,22 +458,35 @@ func (pr *psqlParameterRepository) SaveTranslations(ctx context.Context, paramet
 	return tx.Commit()
 }
 
-func (pr *psqlParameterRepository) SaveValues(ctx context.Context, parameterId string, vmr domain.ValueModificationRequest) error {
+func (pr *sqlParameterRepository) SaveValues(ctx context.Context, modelId, parameterId int, vmr domain.ValueModificationRequest) error {
 	tx, err := pr.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
+	err = touchParameter(ctx, tx, modelId, parameterId, vmr.Version)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	if len(vmr.NewValues) > 0 {
-		args := make([]any, 0, len(vmr.NewValues)*2)
+		var lastPosition int
+		err = tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(position), -1) FROM "values" WHERE parameterId = $1`, parameterId).Scan(&lastPosition)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		args := make([]any, 0, len(vmr.NewValues)*3)
 		valueStrings := make([]string, 0, len(vmr.NewValues))
-		for _, value := range vmr.NewValues {
-			valueStrings = append(valueStrings, fmt.Sprintf("($%v, $%v)", len(args)+1, len(args)+2))
-			args = append(args, value, parameterId)
+		for i, value := range vmr.NewValues {
+			valueStrings = append(valueStrings, fmt.Sprintf("($%v, $%v, $%v)", len(args)+1, len(args)+2, len(args)+3))
+			args = append(args, value, parameterId, lastPosition+i+1)
 		}
 
 		sqlStatement := `
-			INSERT INTO values (value, parameterId)
+			INSERT INTO "values" (value, parameterId, position)
 			VALUES ` + strings.Join(valueStrings, ", ")
 		_, err = tx.ExecContext(ctx, sqlStatement, args...)
 		if err != nil {
@@ -217,17 +498,106 @@ func (pr *psqlParameterRepository) SaveValues(ctx context.Context, parameterId s
 	if len(vmr.UpdatedValues) > 0 {
 		for _, value := range vmr.UpdatedValues {
 			sqlStatement := `
-				UPDATE values
-				SET value = $1
-				WHERE id = $2
+				UPDATE "values"
+				SET value = $1, version = version + 1
+				WHERE id = $2 AND parameterId = $3
 			`
-			_, err = tx.ExecContext(ctx, sqlStatement, value.Value, value.Id)
+			args := []any{value.Value, value.Id, parameterId}
+			if value.Version != 0 {
+				sqlStatement += " AND version = $4"
+				args = append(args, value.Version)
+			}
+			result, err := tx.ExecContext(ctx, sqlStatement, args...)
 			if err != nil {
 				tx.Rollback()
 				return err
 			}
+			err = conflictUnlessAffected(ctx, tx, result, `SELECT COUNT(*) FROM "values" WHERE id = $1 AND parameterId = $2`, value.Id, parameterId)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+
+			if value.Translation != "" {
+				err = saveValueTranslation(ctx, tx, value.Id, value.Translation)
+				if err != nil {
+					tx.Rollback()
+					return err
+				}
+			}
+		}
+	}
+
+	if len(vmr.DeletedValues) > 0 {
+		err = deleteValues(ctx, tx, parameterId, vmr.DeletedValues, vmr.Cascade)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	for position, valueId := range vmr.ValueOrder {
+		_, err = tx.ExecContext(ctx, `UPDATE "values" SET position = $1 WHERE id = $2 AND parameterId = $3`, position, valueId, parameterId)
+		if err != nil {
+			tx.Rollback()
+			return err
 		}
 	}
 
 	return tx.Commit()
 }
+
+func deleteValues(ctx context.Context, tx executor, parameterId int, valueIds []int, cascade bool) error {
+	for _, valueId := range valueIds {
+		var valueCount int
+		err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM "values" WHERE id = $1 AND parameterId = $2`, valueId, parameterId).Scan(&valueCount)
+		if err != nil {
+			return err
+		}
+		if valueCount == 0 {
+			slog.InfoContext(ctx, fmt.Sprintf("value with ID %v does not belong to parameter with ID %v", valueId, parameterId))
+			return domain.ErrNotFound
+		}
+
+		var constraintCount int
+		err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM constraints WHERE fromValueId = $1 OR targetValueId = $1", valueId).Scan(&constraintCount)
+		if err != nil {
+			return err
+		}
+
+		if constraintCount > 0 {
+			if !cascade {
+				slog.InfoContext(ctx, fmt.Sprintf("value with ID %v is referenced by %v constraints", valueId, constraintCount))
+				return domain.ErrValueInUse
+			}
+
+			_, err = tx.ExecContext(ctx, "DELETE FROM constraints WHERE fromValueId = $1 OR targetValueId = $1", valueId)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, "DELETE FROM value_translations WHERE valueId = $1", valueId)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM "values" WHERE id = $1 AND parameterId = $2`, valueId, parameterId)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func saveValueTranslation(ctx context.Context, tx executor, valueId int, translation string) error {
+	language := ctx.Value(middleware.LanguageKey)
+
+	_, err := tx.ExecContext(ctx, "DELETE FROM value_translations WHERE valueId = $1 AND language = $2", valueId, language)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO value_translations (valueId, language, translation) VALUES ($1, $2, $3)", valueId, language, translation)
+	return err
+}
@@ -1 +1,78 @@
 package persistence
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/gossie/modelling-service/domain"
+)
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+func negateUnless(condition bool, expression string) string {
+	if condition {
+		return expression
+	}
+	return "NOT " + expression
+}
+
+func touchModel(ctx context.Context, db executor, modelId int) error {
+	_, err := db.ExecContext(ctx, "UPDATE models SET updatedAt = CURRENT_TIMESTAMP, version = version + 1 WHERE id = $1", modelId)
+	return err
+}
+
+// touchParameter increases the version of the parameter, which has to be expectedVersion unless that is 0.
+func touchParameter(ctx context.Context, db executor, modelId, parameterId int, expectedVersion int) error {
+	sqlStatement := "UPDATE parameters SET updatedAt = CURRENT_TIMESTAMP, version = version + 1 WHERE id = $1 AND modelId = $2"
+	args := []any{parameterId, modelId}
+	if expectedVersion != 0 {
+		sqlStatement += " AND version = $3"
+		args = append(args, expectedVersion)
+	}
+
+	result, err := db.ExecContext(ctx, sqlStatement, args...)
+	if err != nil {
+		return err
+	}
+	return conflictUnlessAffected(ctx, db, result, "SELECT COUNT(*) FROM parameters WHERE id = $1 AND modelId = $2", parameterId, modelId)
+}
+
+// requireOfModel returns ErrNotFound unless the row of the table with the ID belongs to the model.
+func requireOfModel(ctx context.Context, db executor, table string, modelId, id int) error {
+	var count int
+	err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+table+" WHERE id = $1 AND modelId = $2", id, modelId).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// conflictUnlessAffected returns ErrNotFound if the count finds no entity and ErrConflict if it does.
+func conflictUnlessAffected(ctx context.Context, db executor, result sql.Result, countStatement string, args ...any) error {
+	affected, err := result.RowsAffected()
+	if err != nil || affected > 0 {
+		return err
+	}
+
+	var count int
+	err = db.QueryRowContext(ctx, countStatement, args...).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return domain.ErrNotFound
+	}
+	return domain.ErrConflict
+}
+
+func valueOrDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
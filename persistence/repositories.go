@@ -0,0 +1,34 @@
+package persistence
+
+import (
+	"database/sql"
+
+	"github.com/gossie/modelling-service/domain"
+)
+
+func NewPsqlRepositories(db *sql.DB) domain.Repositories {
+	repositories := sqlRepositories(dbSession{db}, postgresDialect)
+	repositories.UnitOfWork = &sqlUnitOfWork{db: db, dialect: postgresDialect}
+	return repositories
+}
+
+func NewSqliteRepositories(db *sql.DB) domain.Repositories {
+	repositories := sqlRepositories(dbSession{db}, sqliteDialect)
+	repositories.UnitOfWork = &sqlUnitOfWork{db: db, dialect: sqliteDialect}
+	return repositories
+}
+
+func sqlRepositories(s session, d dialect) domain.Repositories {
+	s = newTracedSession(s, d)
+	return domain.Repositories{
+		Users:           &sqlUserRepository{db: s},
+		Models:          &sqlModelRepository{db: s, dialect: d},
+		Parameters:      &sqlParameterRepository{db: s, dialect: d},
+		ParameterGroups: &sqlParameterGroupRepository{db: s},
+		Constraints:     &sqlConstraintRepository{db: s},
+		Webhooks:        &sqlWebhookRepository{db: s, dialect: d},
+		Outbox:          &sqlOutboxRepository{db: s, dialect: d},
+		Sessions:        &sqlSessionRepository{db: s, dialect: d},
+		ApiTokens:       &sqlApiTokenRepository{db: s},
+	}
+}
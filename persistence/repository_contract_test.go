@@ -0,0 +1,1405 @@
+package persistence_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	configurationmodel "github.com/gossie/configuration-model"
+	"github.com/gossie/modelling-service/domain"
+	"github.com/gossie/modelling-service/middleware"
+	"github.com/gossie/modelling-service/persistence"
+	_ "github.com/lib/pq"
+)
+
+// postgresDsnEnv names the environment variable with the DSN of a Postgres database for the contract tests.
+// Each run creates its own schema in that database and drops it afterwards.
+const postgresDsnEnv = "MODELLING_TEST_POSTGRES_DSN"
+
+const (
+	owner    = "owner@example.com"
+	stranger = "stranger@example.com"
+)
+
+// newRepositories creates an empty backend that knows the users owner and stranger.
+type newRepositories func(t *testing.T) domain.Repositories
+
+func TestMemoryRepositories(t *testing.T) {
+	runRepositoryContract(t, func(t *testing.T) domain.Repositories {
+		return persistence.NewMemoryRepositories(owner, stranger)
+	})
+}
+
+func TestSqliteRepositories(t *testing.T) {
+	runRepositoryContract(t, func(t *testing.T) domain.Repositories {
+		db, err := persistence.OpenSqlite(context.Background(), t.TempDir()+"/modelling.db")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		migrator, err := persistence.NewSqliteMigrator(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prepareDatabase(t, db, migrator)
+		return persistence.NewSqliteRepositories(db)
+	})
+}
+
+func TestPsqlRepositories(t *testing.T) {
+	dsn := os.Getenv(postgresDsnEnv)
+	if dsn == "" {
+		t.Skip("set " + postgresDsnEnv + " to run the contract against Postgres")
+	}
+
+	runRepositoryContract(t, func(t *testing.T) domain.Repositories {
+		db := openPostgresSchema(t, dsn)
+		migrator, err := persistence.NewPsqlMigrator(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prepareDatabase(t, db, migrator)
+		return persistence.NewPsqlRepositories(db)
+	})
+}
+
+// openPostgresSchema creates a schema that only lives as long as the test and connects to it.
+func openPostgresSchema(t *testing.T, dsn string) *sql.DB {
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	schema := fmt.Sprintf("contract_%v", time.Now().UnixNano())
+	_, err = admin.Exec("CREATE SCHEMA " + schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		query := u.Query()
+		query.Set("search_path", schema)
+		u.RawQuery = query.Encode()
+		dsn = u.String()
+	} else {
+		dsn += " search_path=" + schema
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func prepareDatabase(t *testing.T, db *sql.DB, migrator *persistence.Migrator) {
+	err := migrator.Up(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, email := range []string{owner, stranger} {
+		_, err = db.Exec("INSERT INTO users (email) VALUES ($1)", email)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func inLanguage(language string) context.Context {
+	return context.WithValue(context.Background(), middleware.LanguageKey, language)
+}
+
+func runRepositoryContract(t *testing.T, newRepos newRepositories) {
+	t.Run("users", func(t *testing.T) { testUsers(t, newRepos(t)) })
+	t.Run("user provisioning", func(t *testing.T) { testUserProvisioning(t, newRepos(t)) })
+	t.Run("model ownership", func(t *testing.T) { testModelOwnership(t, newRepos(t)) })
+	t.Run("model paging", func(t *testing.T) { testModelPaging(t, newRepos(t)) })
+	t.Run("page limit", func(t *testing.T) { testPageLimit(t, newRepos(t)) })
+	t.Run("parameter scoping", func(t *testing.T) { testParameterScoping(t, newRepos(t)) })
+	t.Run("language fallback", func(t *testing.T) { testLanguageFallback(t, newRepos(t)) })
+	t.Run("search", func(t *testing.T) { testSearch(t, newRepos(t)) })
+	t.Run("translations", func(t *testing.T) { testTranslations(t, newRepos(t)) })
+	t.Run("value updates", func(t *testing.T) { testValueUpdates(t, newRepos(t)) })
+	t.Run("values in use", func(t *testing.T) { testValuesInUse(t, newRepos(t)) })
+	t.Run("constraint scoping", func(t *testing.T) { testConstraintScoping(t, newRepos(t)) })
+	t.Run("constraints without source value", func(t *testing.T) { testConstraintsWithoutSourceValue(t, newRepos(t)) })
+	t.Run("nested resource scoping", func(t *testing.T) { testNestedResourceScoping(t, newRepos(t)) })
+	t.Run("unit of work", func(t *testing.T) { testUnitOfWork(t, newRepos(t)) })
+	t.Run("optimistic concurrency", func(t *testing.T) { testOptimisticConcurrency(t, newRepos(t)) })
+	t.Run("webhook deliveries", func(t *testing.T) { testWebhookDeliveries(t, newRepos(t)) })
+	t.Run("outbox", func(t *testing.T) { testOutbox(t, newRepos(t)) })
+	t.Run("sessions", func(t *testing.T) { testSessions(t, newRepos(t)) })
+	t.Run("api tokens", func(t *testing.T) { testApiTokens(t, newRepos(t)) })
+}
+
+func testUsers(t *testing.T, repos domain.Repositories) {
+	ctx := inLanguage("de")
+
+	user, err := repos.Users.FindByEmail(ctx, owner)
+	if err != nil || user.Email != owner || user.Id == 0 {
+		t.Fatalf("expected to find %v, got %v, %v", owner, user, err)
+	}
+
+	_, err = repos.Users.FindByEmail(ctx, "nobody@example.com")
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for an unknown user, got %v", err)
+	}
+}
+
+func testUserProvisioning(t *testing.T, repos domain.Repositories) {
+	ctx := inLanguage("de")
+	const newcomer = "newcomer@example.com"
+
+	user, err := repos.Users.SaveUser(ctx, newcomer)
+	if err != nil || user.Email != newcomer || user.Id == 0 {
+		t.Fatalf("expected %v to be created, got %v, %v", newcomer, user, err)
+	}
+	again, err := repos.Users.SaveUser(ctx, newcomer)
+	if err != nil || again != user {
+		t.Errorf("expected the existing user %v, got %v, %v", user, again, err)
+	}
+	existing, err := repos.Users.SaveUser(ctx, owner)
+	if err != nil || existing.Email != owner {
+		t.Errorf("expected the existing user %v, got %v, %v", owner, existing, err)
+	}
+
+	modelId := saveModel(t, repos, owner, "car")
+	for i := 0; i < 2; i++ {
+		changed, err := repos.Models.SyncGroupGrants(ctx, newcomer, []int{modelId, modelId + 1000})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := map[int][]int{0: {modelId}, 1: {}}[i]; !slices.Equal(changed, expected) {
+			t.Errorf("expected the sync %v to change %v, got %v", i+1, expected, changed)
+		}
+	}
+	if hasAccess, err := repos.Models.HasAccess(ctx, modelId, newcomer); err != nil || !hasAccess {
+		t.Errorf("expected %v to have access, got %v, %v", newcomer, hasAccess, err)
+	}
+	if models, err := repos.Models.FindAllByUser(ctx, owner); err != nil || len(models) != 1 {
+		t.Errorf("expected the owner to keep the model, got %v, %v", models, err)
+	}
+
+	// the groups take back what they granted, but not the access of the creator
+	if changed, err := repos.Models.SyncGroupGrants(ctx, newcomer, nil); err != nil || !slices.Equal(changed, []int{modelId}) {
+		t.Errorf("expected the grant of %v to be revoked, got %v, %v", modelId, changed, err)
+	}
+	if hasAccess, err := repos.Models.HasAccess(ctx, modelId, newcomer); err != nil || hasAccess {
+		t.Errorf("expected %v to lose access, got %v, %v", newcomer, hasAccess, err)
+	}
+	if changed, err := repos.Models.SyncGroupGrants(ctx, owner, []int{modelId}); err != nil || len(changed) != 0 {
+		t.Errorf("expected the creator to be unchanged, got %v, %v", changed, err)
+	}
+	if changed, err := repos.Models.SyncGroupGrants(ctx, owner, nil); err != nil || len(changed) != 0 {
+		t.Errorf("expected the creator to keep the model, got %v, %v", changed, err)
+	}
+	if hasAccess, err := repos.Models.HasAccess(ctx, modelId, owner); err != nil || !hasAccess {
+		t.Errorf("expected %v to keep access, got %v, %v", owner, hasAccess, err)
+	}
+
+	if _, err = repos.Models.SyncGroupGrants(ctx, "nobody@example.com", []int{modelId}); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown user, got %v", err)
+	}
+}
+
+func testModelOwnership(t *testing.T, repos domain.Repositories) {
+	ctx := inLanguage("de")
+
+	modelId := saveModel(t, repos, owner, "car")
+	model, err := repos.Models.FindById(ctx, modelId)
+	if err != nil || model.Id != modelId || model.Name != "car" || len(model.Constraints) != 0 {
+		t.Fatalf("unexpected model %v, %v", model, err)
+	}
+
+	_, err = repos.Models.FindById(ctx, modelId+1000)
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for an unknown model, got %v", err)
+	}
+
+	_, err = repos.Models.SaveModel(ctx, "nobody@example.com", domain.ModelCreationRequest{Name: "orphan"})
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound when saving a model for an unknown user, got %v", err)
+	}
+
+	accessTests := []struct {
+		email     string
+		modelId   int
+		hasAccess bool
+	}{
+		{owner, modelId, true},
+		{stranger, modelId, false},
+		{"nobody@example.com", modelId, false},
+		{owner, modelId + 1000, false},
+	}
+	for _, test := range accessTests {
+		hasAccess, err := repos.Models.HasAccess(ctx, test.modelId, test.email)
+		if err != nil || hasAccess != test.hasAccess {
+			t.Errorf("expected access of %v to model %v to be %v, got %v, %v", test.email, test.modelId, test.hasAccess, hasAccess, err)
+		}
+	}
+
+	models, err := repos.Models.FindAllByUser(ctx, owner)
+	if err != nil || len(models) != 1 || models[0].Id != modelId {
+		t.Errorf("expected the owner to see exactly model %v, got %v, %v", modelId, models, err)
+	}
+
+	models, err = repos.Models.FindAllByUser(ctx, stranger)
+	if err != nil || len(models) != 0 {
+		t.Errorf("expected the stranger to see no models, got %v, %v", models, err)
+	}
+
+	page, err := repos.Models.FindPageByUser(ctx, stranger, domain.ModelFilter{}, domain.PageRequest{})
+	if err != nil || len(page.Items) != 0 {
+		t.Errorf("expected the stranger's page to be empty, got %v, %v", page, err)
+	}
+}
+
+func testModelPaging(t *testing.T, repos domain.Repositories) {
+	ctx := inLanguage("de")
+
+	for _, name := range []string{"charlie", "alpha", "echo", "bravo", "delta"} {
+		saveModel(t, repos, owner, name)
+	}
+	saveModel(t, repos, stranger, "foxtrot")
+
+	names := func(pr domain.PageRequest) []string {
+		result := make([]string, 0)
+		for {
+			page, err := repos.Models.FindPageByUser(ctx, owner, domain.ModelFilter{}, pr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, model := range page.Items {
+				result = append(result, model.Name)
+			}
+			if page.NextCursor == "" {
+				return result
+			}
+			pr.Cursor = page.NextCursor
+		}
+	}
+
+	assertStrings(t, names(domain.PageRequest{Limit: 2, SortBy: domain.SortByName}), "alpha", "bravo", "charlie", "delta", "echo")
+	assertStrings(t, names(domain.PageRequest{Limit: 2, SortBy: domain.SortByName, Descending: true}), "echo", "delta", "charlie", "bravo", "alpha")
+	assertStrings(t, names(domain.PageRequest{Limit: 3}), "charlie", "alpha", "echo", "bravo", "delta")
+
+	_, err := repos.Models.FindPageByUser(ctx, owner, domain.ModelFilter{}, domain.PageRequest{Cursor: "not a cursor"})
+	if !errors.Is(err, domain.ErrInvalidCursor) {
+		t.Errorf("expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func testPageLimit(t *testing.T, repos domain.Repositories) {
+	ctx := inLanguage("de")
+
+	for i := range domain.MaxPageSize + 1 {
+		saveModel(t, repos, owner, fmt.Sprintf("model %v", i))
+	}
+
+	page, err := repos.Models.FindPageByUser(ctx, owner, domain.ModelFilter{}, domain.PageRequest{Limit: 1_000_000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != domain.MaxPageSize || page.NextCursor == "" {
+		t.Errorf("expected a page of %v models and a cursor, got %v models and the cursor %q", domain.MaxPageSize, len(page.Items), page.NextCursor)
+	}
+}
+
+func testParameterScoping(t *testing.T, repos domain.Repositories) {
+	ctx := inLanguage("de")
+
+	modelId := saveModel(t, repos, owner, "car")
+	otherModelId := saveModel(t, repos, stranger, "bike")
+	parameterId := saveParameter(t, repos, modelId, "color")
+
+	_, err := repos.Parameters.FindById(ctx, otherModelId, parameterId)
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected ErrNotFound when reading the parameter through another model, got %v", err)
+	}
+
+	err = repos.Parameters.UpdateParameter(ctx, otherModelId, parameterId, domain.ParameterModificationRequest{Unit: "mm"})
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected ErrNotFound when updating the parameter through another model, got %v", err)
+	}
+
+	err = repos.Parameters.DeleteParameter(ctx, otherModelId, parameterId, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parameter := findParameter(t, repos, ctx, modelId, parameterId)
+	if parameter.Unit != "" {
+		t.Errorf("expected the parameter to be unchanged, got %v", parameter)
+	}
+
+	parameters, err := repos.Parameters.FindAllByModelId(ctx, otherModelId, "")
+	if err != nil || len(parameters) != 0 {
+		t.Errorf("expected the other model to have no parameters, got %v, %v", parameters, err)
+	}
+
+	err = repos.Parameters.DeleteParameter(ctx, modelId, parameterId, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repos.Parameters.FindById(ctx, modelId, parameterId)
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected the parameter to be deleted, got %v", err)
+	}
+}
+
+func testLanguageFallback(t *testing.T, repos domain.Repositories) {
+	de := inLanguage("de")
+	en := inLanguage("en")
+
+	modelId := saveModel(t, repos, owner, "car")
+	parameterId := saveParameter(t, repos, modelId, "color")
+	saveTranslation(t, repos, modelId, parameterId, "name", "de", "Farbe")
+	saveValues(t, repos, modelId, parameterId, "red")
+
+	valueId := findParameter(t, repos, de, modelId, parameterId).Value.Values[0].Id
+	err := repos.Parameters.SaveValues(de, modelId, parameterId, domain.ValueModificationRequest{
+		UpdatedValues: []domain.Value{{Id: valueId, Value: "red", Translation: "rot"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repos.Parameters.UpdateParameter(de, modelId, parameterId, domain.ParameterModificationRequest{Description: "Lackierung"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parameter := findParameter(t, repos, de, modelId, parameterId)
+	if parameter.Name != "color" || parameter.Translation != "Farbe" || parameter.Description != "Lackierung" || parameter.Value.Values[0].Translation != "rot" {
+		t.Errorf("expected the German translations, got %+v", parameter)
+	}
+
+	// without a translation the original name is kept and the translations are empty
+	parameter = findParameter(t, repos, en, modelId, parameterId)
+	if parameter.Name != "color" || parameter.Translation != "" || parameter.Description != "" || parameter.Value.Values[0].Translation != "" {
+		t.Errorf("expected no English translations, got %+v", parameter)
+	}
+
+	parameters, err := repos.Parameters.FindAllByModelId(en, modelId, "")
+	if err != nil || len(parameters) != 1 {
+		t.Errorf("expected untranslated parameters to be listed, got %v, %v", parameters, err)
+	}
+
+	page, err := repos.Parameters.FindPageByModelId(en, modelId, domain.ParameterFilter{Untranslated: true}, domain.PageRequest{})
+	if err != nil || len(page.Items) != 1 {
+		t.Errorf("expected the parameter to be untranslated in English, got %v, %v", page, err)
+	}
+
+	page, err = repos.Parameters.FindPageByModelId(de, modelId, domain.ParameterFilter{Untranslated: true}, domain.PageRequest{})
+	if err != nil || len(page.Items) != 0 {
+		t.Errorf("expected the parameter to be translated in German, got %v, %v", page, err)
+	}
+
+	model, err := repos.Models.FindById(en, modelId)
+	if err != nil || model.Name != "car" || model.Translation != "" {
+		t.Errorf("expected the untranslated model, got %v, %v", model, err)
+	}
+}
+
+func testSearch(t *testing.T, repos domain.Repositories) {
+	de := inLanguage("de")
+	en := inLanguage("en")
+
+	modelId := saveModel(t, repos, owner, "car")
+	lengthId := saveParameter(t, repos, modelId, "length")
+	widthId := saveParameter(t, repos, modelId, "width")
+	saveParameter(t, repos, modelId, "height")
+	saveTranslation(t, repos, modelId, widthId, "name", "de", "Breite")
+
+	otherModelId := saveModel(t, repos, owner, "bike")
+	saveParameter(t, repos, otherModelId, "width")
+
+	searchTests := []struct {
+		ctx         context.Context
+		searchValue string
+		expected    []int
+	}{
+		{de, "idt", []int{widthId}},
+		{de, "Breite", []int{widthId}},
+		{en, "Breite", []int{}},
+		{de, "ngth", []int{lengthId}},
+		{de, "unknown", []int{}},
+	}
+	for _, test := range searchTests {
+		parameters, err := repos.Parameters.FindAllByModelId(test.ctx, modelId, test.searchValue)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertParameterIds(t, "search "+test.searchValue, parameters, test.expected...)
+
+		page, err := repos.Parameters.FindPageByModelId(test.ctx, modelId, domain.ParameterFilter{SearchValue: test.searchValue}, domain.PageRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertParameterIds(t, "paged search "+test.searchValue, page.Items, test.expected...)
+	}
+
+	for _, searchValue := range []string{"", "*"} {
+		parameters, err := repos.Parameters.FindAllByModelId(de, modelId, searchValue)
+		if err != nil || len(parameters) != 3 {
+			t.Errorf("expected %q to find all parameters of the model, got %v, %v", searchValue, parameters, err)
+		}
+	}
+}
+
+func testTranslations(t *testing.T, repos domain.Repositories) {
+	ctx := inLanguage("de")
+
+	modelId := saveModel(t, repos, owner, "car")
+	parameterId := saveParameter(t, repos, modelId, "color")
+	saveTranslation(t, repos, modelId, parameterId, "name", "de", "Farbe")
+	saveTranslation(t, repos, modelId, parameterId, "name", "fr", "couleur")
+
+	translations, err := repos.Parameters.FindAllTranslations(ctx, modelId, parameterId)
+	if err != nil || len(translations) != 2 {
+		t.Fatalf("expected two translations, got %v, %v", translations, err)
+	}
+
+	var french domain.Translation
+	for _, translation := range translations {
+		if translation.Language == "fr" {
+			french = translation
+		}
+	}
+	if french.Value != "couleur" || french.Field != "name" {
+		t.Fatalf("expected the French translation, got %v", translations)
+	}
+
+	french.Value = "teinte"
+	err = repos.Parameters.SaveTranslations(ctx, modelId, parameterId, domain.TranslationModificationRequest{UpdatedTranslations: []domain.Translation{french}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parameter := findParameter(t, repos, inLanguage("fr"), modelId, parameterId)
+	if parameter.Translation != "teinte" {
+		t.Errorf("expected the updated French translation, got %v", parameter.Translation)
+	}
+
+	groupId, err := repos.ParameterGroups.SaveGroup(ctx, modelId, domain.ParameterGroupCreationRequest{Name: "exterior"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repos.ParameterGroups.SaveTranslations(ctx, modelId, groupId, domain.TranslationModificationRequest{NewTranslations: []domain.Translation{{Language: "de", Value: "Außen"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups, err := repos.ParameterGroups.FindAllByModelId(ctx, modelId)
+	if err != nil || len(groups) != 1 || groups[0].Name != "exterior" || groups[0].Translation != "Außen" {
+		t.Errorf("expected the translated group, got %v, %v", groups, err)
+	}
+
+	groups, err = repos.ParameterGroups.FindAllByModelId(inLanguage("en"), modelId)
+	if err != nil || len(groups) != 1 || groups[0].Translation != "" {
+		t.Errorf("expected the untranslated group, got %v, %v", groups, err)
+	}
+}
+
+func testValueUpdates(t *testing.T, repos domain.Repositories) {
+	ctx := inLanguage("de")
+
+	modelId := saveModel(t, repos, owner, "car")
+	parameterId := saveParameter(t, repos, modelId, "color")
+	saveValues(t, repos, modelId, parameterId, "red", "green")
+	saveValues(t, repos, modelId, parameterId, "blue")
+
+	values := findParameter(t, repos, ctx, modelId, parameterId).Value.Values
+	assertValues(t, values, "red", "green", "blue")
+	red, green, blue := values[0].Id, values[1].Id, values[2].Id
+
+	err := repos.Parameters.SaveValues(ctx, modelId, parameterId, domain.ValueModificationRequest{
+		UpdatedValues: []domain.Value{{Id: green, Value: "yellow", Translation: "gelb"}},
+		DeletedValues: []int{red},
+		ValueOrder:    []int{blue, green},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values = findParameter(t, repos, ctx, modelId, parameterId).Value.Values
+	assertValues(t, values, "blue", "yellow")
+	if values[1].Translation != "gelb" {
+		t.Errorf("expected the translation of the updated value, got %v", values[1])
+	}
+}
+
+func testValuesInUse(t *testing.T, repos domain.Repositories) {
+	ctx := inLanguage("de")
+
+	modelId := saveModel(t, repos, owner, "car")
+	colorId := saveParameter(t, repos, modelId, "color")
+	roofId := saveParameter(t, repos, modelId, "roof")
+	saveValues(t, repos, modelId, colorId, "red", "green")
+	saveValues(t, repos, modelId, roofId, "open")
+
+	red := findParameter(t, repos, ctx, modelId, colorId).Value.Values[0].Id
+	open := findParameter(t, repos, ctx, modelId, roofId).Value.Values[0].Id
+	saveConstraint(t, repos, modelId, colorId, red, roofId, open)
+
+	err := repos.Parameters.SaveValues(ctx, modelId, colorId, domain.ValueModificationRequest{DeletedValues: []int{red}})
+	if !errors.Is(err, domain.ErrValueInUse) {
+		t.Fatalf("expected ErrValueInUse, got %v", err)
+	}
+	assertValues(t, findParameter(t, repos, ctx, modelId, colorId).Value.Values, "red", "green")
+
+	page, err := repos.Parameters.FindPageByModelId(ctx, modelId, domain.ParameterFilter{HasConstraints: boolPointer(true)}, domain.PageRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertParameterIds(t, "constrained parameters", page.Items, colorId, roofId)
+
+	err = repos.Parameters.SaveValues(ctx, modelId, colorId, domain.ValueModificationRequest{DeletedValues: []int{red}, Cascade: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, findParameter(t, repos, ctx, modelId, colorId).Value.Values, "green")
+
+	model, err := repos.Models.FindById(ctx, modelId)
+	if err != nil || len(model.Constraints) != 0 {
+		t.Errorf("expected the constraint to be deleted with the value, got %v, %v", model.Constraints, err)
+	}
+}
+
+func testConstraintsWithoutSourceValue(t *testing.T, repos domain.Repositories) {
+	ctx := inLanguage("de")
+
+	modelId := saveModel(t, repos, owner, "car")
+	otherModelId := saveModel(t, repos, stranger, "bike")
+	finalId := saveParameter(t, repos, modelId, "final")
+	roofId := saveParameter(t, repos, modelId, "roof")
+	frameId := saveParameter(t, repos, otherModelId, "frame")
+	saveValues(t, repos, modelId, roofId, "open")
+	open := findParameter(t, repos, ctx, modelId, roofId).Value.Values[0].Id
+
+	constraintId := saveConstraint(t, repos, modelId, finalId, 0, roofId, open)
+	model, err := repos.Models.FindById(ctx, modelId)
+	if err != nil || len(model.Constraints) != 1 {
+		t.Fatalf("expected one constraint, got %v, %v", model.Constraints, err)
+	}
+	expected := domain.Constraint{Id: constraintId, FromId: finalId, TargetId: roofId, TargetValueId: open, Version: 1}
+	if model.Constraints[0] != expected {
+		t.Errorf("expected %v, got %v", expected, model.Constraints[0])
+	}
+
+	_, err = repos.Constraints.SaveConstraint(ctx, strconv.Itoa(modelId), domain.ConstraintCreationRequest{FromId: frameId, TargetId: roofId, TargetValueId: open})
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a parameter of another model, got %v", err)
+	}
+}
+
+func testConstraintScoping(t *testing.T, repos domain.Repositories) {
+	ctx := inLanguage("de")
+
+	modelId := saveModel(t, repos, owner, "car")
+	otherModelId := saveModel(t, repos, stranger, "bike")
+	colorId := saveParameter(t, repos, modelId, "color")
+	roofId := saveParameter(t, repos, modelId, "roof")
+	saveValues(t, repos, modelId, colorId, "red")
+	saveValues(t, repos, modelId, roofId, "open")
+
+	red := findParameter(t, repos, ctx, modelId, colorId).Value.Values[0].Id
+	open := findParameter(t, repos, ctx, modelId, roofId).Value.Values[0].Id
+	constraintId := saveConstraint(t, repos, modelId, colorId, red, roofId, open)
+
+	model, err := repos.Models.FindById(ctx, modelId)
+	if err != nil || len(model.Constraints) != 1 {
+		t.Fatalf("expected one constraint, got %v, %v", model.Constraints, err)
+	}
+	expected := domain.Constraint{Id: constraintId, Type: configurationmodel.ConstraintType(0), FromId: colorId, FromValueId: red, TargetId: roofId, TargetValueId: open, Version: 1}
+	if model.Constraints[0] != expected {
+		t.Errorf("expected %v, got %v", expected, model.Constraints[0])
+	}
+
+	other, err := repos.Models.FindById(ctx, otherModelId)
+	if err != nil || len(other.Constraints) != 0 {
+		t.Errorf("expected the other model to have no constraints, got %v, %v", other.Constraints, err)
+	}
+
+	statistics, err := repos.Models.Statistics(ctx)
+	expectedStatistics := []domain.ModelStatistics{{ModelId: modelId, Parameters: 2, Constraints: 1}, {ModelId: otherModelId}}
+	if err != nil || !slices.Equal(statistics, expectedStatistics) {
+		t.Errorf("expected the statistics %v, got %v, %v", expectedStatistics, statistics, err)
+	}
+
+	err = repos.Constraints.DeleteConstraint(ctx, strconv.Itoa(otherModelId), strconv.Itoa(constraintId), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err = repos.Models.FindById(ctx, modelId)
+	if err != nil || len(model.Constraints) != 1 {
+		t.Errorf("expected the constraint to survive a deletion through another model, got %v, %v", model.Constraints, err)
+	}
+
+	err = repos.Constraints.DeleteConstraint(ctx, strconv.Itoa(modelId), strconv.Itoa(constraintId), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err = repos.Models.FindById(ctx, modelId)
+	if err != nil || len(model.Constraints) != 0 {
+		t.Errorf("expected the constraint to be deleted, got %v, %v", model.Constraints, err)
+	}
+}
+
+// testNestedResourceScoping uses the IDs of one model's parameters, values, translations and groups through another
+// model, like a user who is authorized for the other model and guesses IDs.
+func testNestedResourceScoping(t *testing.T, repos domain.Repositories) {
+	ctx := inLanguage("de")
+
+	modelId := saveModel(t, repos, owner, "car")
+	otherModelId := saveModel(t, repos, stranger, "bike")
+	colorId := saveParameter(t, repos, modelId, "color")
+	roofId := saveParameter(t, repos, modelId, "roof")
+	frameId := saveParameter(t, repos, otherModelId, "frame")
+	saveTranslation(t, repos, modelId, colorId, "name", "de", "Farbe")
+	saveTranslation(t, repos, modelId, roofId, "name", "de", "Dach")
+	saveValues(t, repos, modelId, colorId, "red", "green")
+	saveValues(t, repos, modelId, roofId, "open")
+	saveValues(t, repos, otherModelId, frameId, "steel")
+
+	values := findParameter(t, repos, ctx, modelId, colorId).Value.Values
+	red, green := values[0].Id, values[1].Id
+	open := findParameter(t, repos, ctx, modelId, roofId).Value.Values[0].Id
+	steel := findParameter(t, repos, ctx, otherModelId, frameId).Value.Values[0].Id
+	translations, err := repos.Parameters.FindAllTranslations(ctx, modelId, colorId)
+	if err != nil || len(translations) != 1 {
+		t.Fatalf("expected one translation, got %v, %v", translations, err)
+	}
+	farbe := translations[0]
+
+	_, err = repos.Parameters.FindAllTranslations(ctx, otherModelId, colorId)
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected ErrNotFound when reading the translations through another model, got %v", err)
+	}
+
+	err = repos.Parameters.SaveTranslations(ctx, otherModelId, colorId, domain.TranslationModificationRequest{
+		NewTranslations:     []domain.Translation{{Field: "name", Language: "fr", Value: "couleur"}},
+		UpdatedTranslations: []domain.Translation{{Id: farbe.Id, Language: "de", Value: "Tönung"}},
+	})
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected ErrNotFound when translating the parameter through another model, got %v", err)
+	}
+
+	err = repos.Parameters.SaveValues(ctx, otherModelId, colorId, domain.ValueModificationRequest{
+		NewValues:     []string{"blue"},
+		UpdatedValues: []domain.Value{{Id: red, Value: "pink"}},
+		DeletedValues: []int{green},
+		ValueOrder:    []int{green, red},
+	})
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected ErrNotFound when changing the values through another model, got %v", err)
+	}
+
+	// the IDs of rows of another parameter are not accepted through an authorized parameter
+	err = repos.Parameters.SaveValues(ctx, otherModelId, frameId, domain.ValueModificationRequest{UpdatedValues: []domain.Value{{Id: red, Value: "pink"}}})
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected ErrNotFound when updating a value of another parameter, got %v", err)
+	}
+	err = repos.Parameters.SaveValues(ctx, otherModelId, frameId, domain.ValueModificationRequest{DeletedValues: []int{green}})
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected ErrNotFound when deleting a value of another parameter, got %v", err)
+	}
+	err = repos.Parameters.SaveValues(ctx, otherModelId, frameId, domain.ValueModificationRequest{ValueOrder: []int{steel, red}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repos.Parameters.SaveTranslations(ctx, otherModelId, frameId, domain.TranslationModificationRequest{
+		UpdatedTranslations: []domain.Translation{{Id: farbe.Id, Language: "de", Value: "Tönung"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	color := findParameter(t, repos, ctx, modelId, colorId)
+	assertValues(t, color.Value.Values, "red", "green")
+	if color.Translation != "Farbe" {
+		t.Errorf("expected the translation to be unchanged, got %v", color.Translation)
+	}
+
+	groupId, err := repos.ParameterGroups.SaveGroup(ctx, modelId, domain.ParameterGroupCreationRequest{Name: "exterior"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherGroupId, err := repos.ParameterGroups.SaveGroup(ctx, otherModelId, domain.ParameterGroupCreationRequest{Name: "parts"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repos.ParameterGroups.SaveTranslations(ctx, modelId, groupId, domain.TranslationModificationRequest{NewTranslations: []domain.Translation{{Language: "de", Value: "Außen"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	groupTranslations, err := repos.ParameterGroups.FindAllTranslations(ctx, modelId, groupId)
+	if err != nil || len(groupTranslations) != 1 {
+		t.Fatalf("expected one group translation, got %v, %v", groupTranslations, err)
+	}
+
+	_, err = repos.ParameterGroups.FindAllTranslations(ctx, otherModelId, groupId)
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected ErrNotFound when reading the group translations through another model, got %v", err)
+	}
+	err = repos.ParameterGroups.SaveTranslations(ctx, otherModelId, groupId, domain.TranslationModificationRequest{NewTranslations: []domain.Translation{{Language: "fr", Value: "extérieur"}}})
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected ErrNotFound when translating the group through another model, got %v", err)
+	}
+	err = repos.ParameterGroups.SaveTranslations(ctx, otherModelId, otherGroupId, domain.TranslationModificationRequest{
+		UpdatedTranslations: []domain.Translation{{Id: groupTranslations[0].Id, Language: "de", Value: "Draußen"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups, err := repos.ParameterGroups.FindAllByModelId(ctx, modelId)
+	if err != nil || len(groups) != 1 || groups[0].Translation != "Außen" {
+		t.Errorf("expected the group translation to be unchanged, got %v, %v", groups, err)
+	}
+
+	err = repos.ParameterGroups.SaveLayout(ctx, otherModelId, []domain.ParameterLayout{{GroupId: groupId, ParameterIds: []int{frameId}}})
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected ErrNotFound when moving a parameter into a group of another model, got %v", err)
+	}
+	err = repos.ParameterGroups.SaveLayout(ctx, otherModelId, []domain.ParameterLayout{{GroupId: otherGroupId, ParameterIds: []int{colorId, frameId}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame := findParameter(t, repos, ctx, otherModelId, frameId); frame.GroupId != otherGroupId {
+		t.Errorf("expected the own parameter to be moved, got %v", frame)
+	}
+	if color := findParameter(t, repos, ctx, modelId, colorId); color.GroupId != 0 {
+		t.Errorf("expected the parameter of the other model to stay ungrouped, got %v", color)
+	}
+
+	for name, ccr := range map[string]domain.ConstraintCreationRequest{
+		"foreign parameters":         {FromId: colorId, FromValueId: red, TargetId: roofId, TargetValueId: open},
+		"foreign target":             {FromId: frameId, FromValueId: steel, TargetId: roofId, TargetValueId: open},
+		"value of another parameter": {FromId: frameId, FromValueId: red, TargetId: frameId, TargetValueId: steel},
+	} {
+		_, err = repos.Constraints.SaveConstraint(ctx, strconv.Itoa(otherModelId), ccr)
+		if !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("%v: expected ErrNotFound when creating the constraint, got %v", name, err)
+		}
+	}
+	other, err := repos.Models.FindById(ctx, otherModelId)
+	if err != nil || len(other.Constraints) != 0 {
+		t.Errorf("expected the other model to have no constraints, got %v, %v", other.Constraints, err)
+	}
+}
+
+func testUnitOfWork(t *testing.T, repos domain.Repositories) {
+	ctx := inLanguage("de")
+	errAbort := errors.New("abort")
+
+	err := repos.UnitOfWork.Do(ctx, func(tx domain.Repositories) error {
+		modelId := saveModel(t, tx, owner, "discarded")
+		saveParameter(t, tx, modelId, "color")
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("expected the error of the work, got %v", err)
+	}
+
+	models, err := repos.Models.FindAllByUser(ctx, owner)
+	if err != nil || len(models) != 0 {
+		t.Fatalf("expected the rolled back model to be gone, got %v, %v", models, err)
+	}
+
+	var modelId, parameterId int
+	err = repos.UnitOfWork.Do(ctx, func(tx domain.Repositories) error {
+		modelId = saveModel(t, tx, owner, "car")
+		parameterId = saveParameter(t, tx, modelId, "color")
+		saveValues(t, tx, modelId, parameterId, "red")
+
+		red := findParameter(t, tx, ctx, modelId, parameterId).Value.Values[0].Id
+		saveConstraint(t, tx, modelId, parameterId, red, parameterId, red)
+
+		// a failed call only undoes its own changes
+		err := tx.Parameters.SaveValues(ctx, modelId, parameterId, domain.ValueModificationRequest{NewValues: []string{"green"}, DeletedValues: []int{red}})
+		if !errors.Is(err, domain.ErrValueInUse) {
+			t.Errorf("expected ErrValueInUse, got %v", err)
+		}
+
+		return tx.UnitOfWork.Do(ctx, func(nested domain.Repositories) error {
+			saveValues(t, nested, modelId, parameterId, "blue")
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hasAccess, err := repos.Models.HasAccess(ctx, modelId, owner)
+	if err != nil || !hasAccess {
+		t.Errorf("expected the committed model to belong to the owner, got %v, %v", hasAccess, err)
+	}
+	assertValues(t, findParameter(t, repos, ctx, modelId, parameterId).Value.Values, "red", "blue")
+}
+
+func testOptimisticConcurrency(t *testing.T, repos domain.Repositories) {
+	ctx := inLanguage("de")
+
+	modelId := saveModel(t, repos, owner, "car")
+	parameterId := saveParameter(t, repos, modelId, "color")
+	saveValues(t, repos, modelId, parameterId, "red")
+
+	parameter := findParameter(t, repos, ctx, modelId, parameterId)
+	stale := parameter.Version
+	red := parameter.Value.Values[0]
+
+	err := repos.Parameters.UpdateParameter(ctx, modelId, parameterId, domain.ParameterModificationRequest{Unit: "RAL", Version: stale})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parameter = findParameter(t, repos, ctx, modelId, parameterId)
+	if parameter.Version != stale+1 || parameter.Unit != "RAL" {
+		t.Fatalf("expected the update to increase the version, got %v", parameter)
+	}
+
+	err = repos.Parameters.UpdateParameter(ctx, modelId, parameterId, domain.ParameterModificationRequest{Unit: "HKS", Version: stale})
+	if !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("expected ErrConflict for a stale parameter update, got %v", err)
+	}
+	err = repos.Parameters.SaveValues(ctx, modelId, parameterId, domain.ValueModificationRequest{NewValues: []string{"green"}, Version: stale})
+	if !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("expected ErrConflict for stale values, got %v", err)
+	}
+	err = repos.Parameters.SaveTranslations(ctx, modelId, parameterId, domain.TranslationModificationRequest{
+		NewTranslations: []domain.Translation{{Field: "name", Language: "de", Value: "Farbe"}},
+		Version:         stale,
+	})
+	if !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("expected ErrConflict for stale translations, got %v", err)
+	}
+
+	err = repos.Parameters.SaveValues(ctx, modelId, parameterId, domain.ValueModificationRequest{
+		UpdatedValues: []domain.Value{{Id: red.Id, Value: "crimson", Version: red.Version}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repos.Parameters.SaveValues(ctx, modelId, parameterId, domain.ValueModificationRequest{
+		NewValues:     []string{"green"},
+		UpdatedValues: []domain.Value{{Id: red.Id, Value: "scarlet", Version: red.Version}},
+	})
+	if !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("expected ErrConflict for a stale value, got %v", err)
+	}
+
+	parameter = findParameter(t, repos, ctx, modelId, parameterId)
+	assertValues(t, parameter.Value.Values, "crimson")
+	if parameter.Translation != "" || parameter.Unit != "RAL" {
+		t.Errorf("expected rejected changes to leave the parameter untouched, got %v", parameter)
+	}
+
+	saveValues(t, repos, modelId, parameterId, "green")
+	values := findParameter(t, repos, ctx, modelId, parameterId).Value.Values
+	constraintId := saveConstraint(t, repos, modelId, parameterId, values[0].Id, parameterId, values[1].Id)
+
+	err = repos.Constraints.DeleteConstraint(ctx, strconv.Itoa(modelId), strconv.Itoa(constraintId), 2)
+	if !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("expected ErrConflict for a stale constraint, got %v", err)
+	}
+	err = repos.Constraints.DeleteConstraint(ctx, strconv.Itoa(modelId), strconv.Itoa(constraintId), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	model, err := repos.Models.FindById(ctx, modelId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repos.Parameters.DeleteParameter(ctx, modelId, parameterId, stale)
+	if !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("expected ErrConflict for a stale parameter deletion, got %v", err)
+	}
+	err = repos.Parameters.DeleteParameter(ctx, modelId, parameterId, findParameter(t, repos, ctx, modelId, parameterId).Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repos.Parameters.DeleteParameter(ctx, modelId, parameterId, 1)
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a deleted parameter, got %v", err)
+	}
+
+	updated, err := repos.Models.FindById(ctx, modelId)
+	if err != nil || updated.Version <= model.Version {
+		t.Errorf("expected the deletion to increase the model version beyond %v, got %v, %v", model.Version, updated.Version, err)
+	}
+}
+
+func testWebhookDeliveries(t *testing.T, repos domain.Repositories) {
+	ctx := inLanguage("de")
+
+	modelId := saveModel(t, repos, owner, "car")
+	otherModelId := saveModel(t, repos, stranger, "bike")
+	webhookId, err := repos.Webhooks.SaveWebhook(ctx, modelId, domain.WebhookCreationRequest{Url: "http://pim.example/hook", Secret: "s3cret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repos.Webhooks.SaveWebhook(ctx, otherModelId, domain.WebhookCreationRequest{Url: "http://other.example/hook", Secret: "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	webhooks, err := repos.Webhooks.FindAllByModelId(ctx, modelId)
+	if err != nil || len(webhooks) != 1 || webhooks[0].Url != "http://pim.example/hook" || webhooks[0].Secret != "s3cret" {
+		t.Fatalf("expected the webhook of the model, got %v, %v", webhooks, err)
+	}
+
+	err = repos.Webhooks.EnqueueDeliveries(ctx, modelId, "event-1", "model.changed", `{"modelId":1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the same event published again
+	err = repos.Webhooks.EnqueueDeliveries(ctx, modelId, "event-1", "model.changed", `{"modelId":1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	due, err := repos.Webhooks.FindDueDeliveries(ctx, now.Add(time.Second), 10)
+	if err != nil || len(due) != 1 {
+		t.Fatalf("expected one due delivery, got %v, %v", due, err)
+	}
+	delivery := due[0].Delivery
+	if due[0].Webhook.Id != webhookId || due[0].Webhook.Secret != "s3cret" || delivery.Url != "http://pim.example/hook" || delivery.Event != "model.changed" || delivery.IdempotencyKey != "event-1" || delivery.Payload != `{"modelId":1}` || delivery.Status != domain.DeliveryPending {
+		t.Errorf("expected the pending delivery to the webhook, got %v", due[0])
+	}
+
+	delivery.Attempts = 1
+	delivery.LastError = "receiver answered with 500"
+	delivery.ResponseStatus = 500
+	delivery.NextAttemptAt = now.Add(time.Hour)
+	err = repos.Webhooks.UpdateDelivery(ctx, delivery)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	due, err = repos.Webhooks.FindDueDeliveries(ctx, now.Add(time.Second), 10)
+	if err != nil || len(due) != 0 {
+		t.Errorf("expected the postponed delivery not to be due, got %v, %v", due, err)
+	}
+	due, err = repos.Webhooks.FindDueDeliveries(ctx, now.Add(2*time.Hour), 10)
+	if err != nil || len(due) != 1 || due[0].Delivery.Attempts != 1 {
+		t.Fatalf("expected the postponed delivery to be due later, got %v, %v", due, err)
+	}
+
+	delivery.Status = domain.DeliveryDead
+	err = repos.Webhooks.UpdateDelivery(ctx, delivery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	due, err = repos.Webhooks.FindDueDeliveries(ctx, now.Add(2*time.Hour), 10)
+	if err != nil || len(due) != 0 {
+		t.Errorf("expected dead deliveries not to be due, got %v, %v", due, err)
+	}
+
+	deliveries, err := repos.Webhooks.FindDeliveriesByModelId(ctx, modelId, 10)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("expected the delivery in the log, got %v, %v", deliveries, err)
+	}
+	if deliveries[0].Status != domain.DeliveryDead || deliveries[0].ResponseStatus != 500 || deliveries[0].LastError != "receiver answered with 500" || !deliveries[0].DeliveredAt.IsZero() {
+		t.Errorf("expected the outcome of the attempts, got %v", deliveries[0])
+	}
+
+	err = repos.Webhooks.RetryDelivery(ctx, otherModelId, delivery.Id)
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected ErrNotFound when retrying through another model, got %v", err)
+	}
+	err = repos.Webhooks.RetryDelivery(ctx, modelId, delivery.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	due, err = repos.Webhooks.FindDueDeliveries(ctx, time.Now().UTC().Add(time.Second), 10)
+	if err != nil || len(due) != 1 || due[0].Delivery.Attempts != 0 {
+		t.Errorf("expected the retried delivery to be due with fresh attempts, got %v, %v", due, err)
+	}
+
+	err = repos.Webhooks.DeleteWebhook(ctx, otherModelId, webhookId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deliveries, err = repos.Webhooks.FindDeliveriesByModelId(ctx, modelId, 10)
+	if err != nil || len(deliveries) != 1 {
+		t.Errorf("expected the webhook to survive a deletion through another model, got %v, %v", deliveries, err)
+	}
+
+	err = repos.Webhooks.DeleteWebhook(ctx, modelId, webhookId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deliveries, err = repos.Webhooks.FindDeliveriesByModelId(ctx, modelId, 10)
+	if err != nil || len(deliveries) != 0 {
+		t.Errorf("expected the deliveries to be deleted with the webhook, got %v, %v", deliveries, err)
+	}
+}
+
+func testOutbox(t *testing.T, repos domain.Repositories) {
+	ctx := inLanguage("de")
+
+	carId := saveModel(t, repos, owner, "car")
+	bikeId := saveModel(t, repos, owner, "bike")
+
+	err := repos.Outbox.Append(ctx, carId, "parameters", "car-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repos.UnitOfWork.Do(ctx, func(tx domain.Repositories) error {
+		if err := tx.Outbox.Append(ctx, bikeId, "parameters", "bike-1"); err != nil {
+			return err
+		}
+		return tx.Outbox.Append(ctx, carId, "constraints", "car-2")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repos.UnitOfWork.Do(ctx, func(tx domain.Repositories) error {
+		if err := tx.Outbox.Append(ctx, carId, "parameters", "car-3"); err != nil {
+			return err
+		}
+		return errors.New("rolled back")
+	})
+	if err == nil {
+		t.Fatal("expected the failing unit of work to return its error")
+	}
+
+	err = repos.Outbox.Append(ctx, carId, "parameters", "car-1")
+	if err == nil {
+		t.Error("expected an idempotency key to be unique")
+	}
+
+	keys := func(events []domain.OutboxEvent) []string {
+		result := make([]string, len(events))
+		for i, event := range events {
+			result[i] = event.IdempotencyKey
+		}
+		return result
+	}
+
+	pending, err := repos.Outbox.FindPending(ctx, 10, nil)
+	if err != nil || fmt.Sprint(keys(pending)) != "[car-1 bike-1 car-2]" {
+		t.Fatalf("expected the committed events in the order they were appended, got %v, %v", keys(pending), err)
+	}
+	if pending[0].ModelId != carId || pending[0].Kind != "parameters" || pending[0].CreatedAt.IsZero() || !pending[0].PublishedAt.IsZero() {
+		t.Errorf("unexpected pending event %v", pending[0])
+	}
+
+	limited, err := repos.Outbox.FindPending(ctx, 2, nil)
+	if err != nil || fmt.Sprint(keys(limited)) != "[car-1 bike-1]" {
+		t.Errorf("expected the limit to keep the oldest events, got %v, %v", keys(limited), err)
+	}
+
+	excluded, err := repos.Outbox.FindPending(ctx, 10, []int{carId})
+	if err != nil || fmt.Sprint(keys(excluded)) != "[bike-1]" {
+		t.Errorf("expected the events of the excluded model to be skipped, got %v, %v", keys(excluded), err)
+	}
+
+	lastId, err := repos.Outbox.LastId(ctx)
+	if err != nil || lastId != pending[2].Id {
+		t.Errorf("expected the ID %v of the last event, got %v, %v", pending[2].Id, lastId, err)
+	}
+	after, err := repos.Outbox.FindAfter(ctx, pending[0].Id, 1)
+	if err != nil || fmt.Sprint(keys(after)) != "[bike-1]" {
+		t.Errorf("expected the next event after the first one, got %v, %v", keys(after), err)
+	}
+
+	count, oldest, err := repos.Outbox.PendingStats(ctx)
+	if err != nil || count != 3 || !oldest.Equal(pending[0].CreatedAt) {
+		t.Errorf("expected 3 pending events since %v, got %v since %v, %v", pending[0].CreatedAt, count, oldest, err)
+	}
+
+	publishedAt := time.Now().UTC()
+	for _, event := range pending[:2] {
+		err = repos.Outbox.MarkPublished(ctx, event.Id, publishedAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = repos.Outbox.MarkPublished(ctx, pending[2].Id+1000, publishedAt)
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown event, got %v", err)
+	}
+
+	pending, err = repos.Outbox.FindPending(ctx, 10, nil)
+	if err != nil || fmt.Sprint(keys(pending)) != "[car-2]" {
+		t.Errorf("expected published events not to be pending, got %v, %v", keys(pending), err)
+	}
+	after, err = repos.Outbox.FindAfter(ctx, 0, 10)
+	if err != nil || fmt.Sprint(keys(after)) != "[car-1 bike-1 car-2]" {
+		t.Errorf("expected published events to be followed as well, got %v, %v", keys(after), err)
+	}
+
+	deleted, err := repos.Outbox.DeletePublished(ctx, publishedAt.Add(-time.Minute))
+	if err != nil || deleted != 0 {
+		t.Errorf("expected events published later to be kept, got %v deleted, %v", deleted, err)
+	}
+	deleted, err = repos.Outbox.DeletePublished(ctx, publishedAt.Add(time.Minute))
+	if err != nil || deleted != 2 {
+		t.Errorf("expected the published events to be deleted, got %v deleted, %v", deleted, err)
+	}
+
+	err = repos.Outbox.MarkPublished(ctx, pending[0].Id, publishedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, oldest, err = repos.Outbox.PendingStats(ctx)
+	if err != nil || count != 0 || !oldest.IsZero() {
+		t.Errorf("expected no pending events, got %v since %v, %v", count, oldest, err)
+	}
+}
+
+func saveModel(t *testing.T, repos domain.Repositories, email, name string) int {
+	t.Helper()
+	modelId, err := repos.Models.SaveModel(inLanguage("de"), email, domain.ModelCreationRequest{Name: name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return modelId
+}
+
+func saveParameter(t *testing.T, repos domain.Repositories, modelId int, name string) int {
+	t.Helper()
+	parameterId, err := repos.Parameters.SaveParameter(inLanguage("de"), modelId, domain.ParameterCreationRequest{Name: name, ValueType: configurationmodel.StringSetType})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parameterId
+}
+
+func saveTranslation(t *testing.T, repos domain.Repositories, modelId, parameterId int, field, language, value string) {
+	t.Helper()
+	err := repos.Parameters.SaveTranslations(inLanguage("de"), modelId, parameterId, domain.TranslationModificationRequest{
+		NewTranslations: []domain.Translation{{Field: field, Language: language, Value: value}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func saveValues(t *testing.T, repos domain.Repositories, modelId, parameterId int, values ...string) {
+	t.Helper()
+	err := repos.Parameters.SaveValues(inLanguage("de"), modelId, parameterId, domain.ValueModificationRequest{NewValues: values})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func saveConstraint(t *testing.T, repos domain.Repositories, modelId, fromId, fromValueId, targetId, targetValueId int) int {
+	t.Helper()
+	constraintId, err := repos.Constraints.SaveConstraint(inLanguage("de"), strconv.Itoa(modelId), domain.ConstraintCreationRequest{
+		FromId:        fromId,
+		FromValueId:   fromValueId,
+		TargetId:      targetId,
+		TargetValueId: targetValueId,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return constraintId
+}
+
+func findParameter(t *testing.T, repos domain.Repositories, ctx context.Context, modelId, parameterId int) domain.Parameter {
+	t.Helper()
+	parameter, err := repos.Parameters.FindById(ctx, modelId, parameterId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parameter
+}
+
+func assertStrings(t *testing.T, actual []string, expected ...string) {
+	t.Helper()
+	if strings.Join(actual, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
+func assertValues(t *testing.T, values []domain.Value, expected ...string) {
+	t.Helper()
+	actual := make([]string, len(values))
+	for i, value := range values {
+		actual[i] = value.Value
+	}
+	assertStrings(t, actual, expected...)
+}
+
+func assertParameterIds(t *testing.T, description string, parameters []domain.Parameter, expected ...int) {
+	t.Helper()
+	actual := make([]int, len(parameters))
+	for i, parameter := range parameters {
+		actual[i] = parameter.Id
+	}
+	if fmt.Sprint(actual) != fmt.Sprint(expected) {
+		t.Errorf("%v: expected parameters %v, got %v", description, expected, actual)
+	}
+}
+
+func boolPointer(value bool) *bool {
+	return &value
+}
+
+func testSessions(t *testing.T, repos domain.Repositories) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	_, err := repos.Sessions.SaveSession(ctx, "nobody@example.com", "hash", now.Add(time.Hour))
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown user, got %v", err)
+	}
+
+	sessionId, err := repos.Sessions.SaveSession(ctx, owner, "first", now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := repos.Sessions.FindSession(ctx, sessionId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.UserEmail != owner || session.RefreshTokenHash != "first" || session.PreviousRefreshTokenHash != "" || session.CreatedAt.IsZero() || !session.RotatedAt.IsZero() || !session.RevokedAt.IsZero() {
+		t.Errorf("unexpected session %v", session)
+	}
+	if session.ExpiresAt.Sub(now.Add(time.Hour)).Abs() > time.Second {
+		t.Errorf("expected the session to expire at %v, got %v", now.Add(time.Hour), session.ExpiresAt)
+	}
+	_, err = repos.Sessions.FindSession(ctx, sessionId+1000)
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown session, got %v", err)
+	}
+
+	err = repos.Sessions.RotateRefreshToken(ctx, sessionId, "first", "second", now, now.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repos.Sessions.RotateRefreshToken(ctx, sessionId, "first", "third", now, now.Add(2*time.Hour))
+	if !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("expected ErrConflict for a replaced refresh token, got %v", err)
+	}
+	err = repos.Sessions.RotateRefreshToken(ctx, sessionId+1000, "first", "third", now, now.Add(2*time.Hour))
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown session, got %v", err)
+	}
+	session, err = repos.Sessions.FindSession(ctx, sessionId)
+	if err != nil || session.RefreshTokenHash != "second" || session.PreviousRefreshTokenHash != "first" || session.RotatedAt.Sub(now).Abs() > time.Second || session.ExpiresAt.Sub(now.Add(2*time.Hour)).Abs() > time.Second {
+		t.Errorf("expected the rotated refresh token, got %v, %v", session, err)
+	}
+
+	err = repos.Sessions.RevokeSession(ctx, sessionId, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repos.Sessions.RevokeSession(ctx, sessionId, now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err = repos.Sessions.FindSession(ctx, sessionId)
+	if err != nil || session.RevokedAt.Sub(now).Abs() > time.Second {
+		t.Errorf("expected the session to be revoked at %v, got %v, %v", now, session, err)
+	}
+	err = repos.Sessions.RotateRefreshToken(ctx, sessionId, "second", "third", now, now.Add(2*time.Hour))
+	if !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("expected ErrConflict for a revoked session, got %v", err)
+	}
+
+	expiredId, err := repos.Sessions.SaveSession(ctx, stranger, "expired", now.Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	activeId, err := repos.Sessions.SaveSession(ctx, stranger, "active", now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := repos.Sessions.DeleteExpiredSessions(ctx, now.Add(time.Second))
+	if err != nil || deleted != 2 {
+		t.Errorf("expected the expired and the revoked session to be deleted, got %v, %v", deleted, err)
+	}
+	for _, id := range []int{sessionId, expiredId} {
+		if _, err = repos.Sessions.FindSession(ctx, id); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("expected session %v to be deleted, got %v", id, err)
+		}
+	}
+	if _, err = repos.Sessions.FindSession(ctx, activeId); err != nil {
+		t.Errorf("expected the active session to be kept, got %v", err)
+	}
+}
+
+func testApiTokens(t *testing.T, repos domain.Repositories) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	_, err := repos.ApiTokens.SaveApiToken(ctx, "nobody@example.com", "ci", []string{domain.ScopeRead}, "hash", now.Add(time.Hour))
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown user, got %v", err)
+	}
+
+	firstId, err := repos.ApiTokens.SaveApiToken(ctx, owner, "ci", []string{domain.ScopeRead}, "first", now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondId, err := repos.ApiTokens.SaveApiToken(ctx, owner, "deploy", []string{domain.ScopeRead, domain.ScopeWrite}, "second", now.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = repos.ApiTokens.SaveApiToken(ctx, stranger, "other", []string{domain.ScopeRead}, "third", now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := repos.ApiTokens.FindApiToken(ctx, secondId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.UserEmail != owner || token.Name != "deploy" || !slices.Equal(token.Scopes, []string{domain.ScopeRead, domain.ScopeWrite}) || token.TokenHash != "second" {
+		t.Errorf("unexpected token %v", token)
+	}
+	if token.CreatedAt.IsZero() || token.ExpiresAt.Sub(now.Add(2*time.Hour)).Abs() > time.Second || !token.LastUsedAt.IsZero() || !token.RevokedAt.IsZero() {
+		t.Errorf("unexpected times of token %v", token)
+	}
+	if _, err = repos.ApiTokens.FindApiToken(ctx, secondId+1000); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown token, got %v", err)
+	}
+
+	tokens, err := repos.ApiTokens.FindApiTokensByUser(ctx, owner)
+	if err != nil || len(tokens) != 2 || tokens[0].Id != secondId || tokens[1].Id != firstId {
+		t.Errorf("expected the tokens of the owner, the newest first, got %v, %v", tokens, err)
+	}
+
+	if err = repos.ApiTokens.RecordApiTokenUse(ctx, firstId, now, "192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+	token, err = repos.ApiTokens.FindApiToken(ctx, firstId)
+	if err != nil || token.LastUsedAt.Sub(now).Abs() > time.Second || token.LastUsedFrom != "192.0.2.1" {
+		t.Errorf("expected the use to be recorded, got %v, %v", token, err)
+	}
+
+	if err = repos.ApiTokens.RevokeApiToken(ctx, stranger, firstId, now); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a token of another user, got %v", err)
+	}
+	for _, revokedAt := range []time.Time{now, now.Add(time.Minute)} {
+		if err = repos.ApiTokens.RevokeApiToken(ctx, owner, firstId, revokedAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	token, err = repos.ApiTokens.FindApiToken(ctx, firstId)
+	if err != nil || token.RevokedAt.Sub(now).Abs() > time.Second {
+		t.Errorf("expected the token to be revoked at %v, got %v, %v", now, token, err)
+	}
+	if token, err = repos.ApiTokens.FindApiToken(ctx, secondId); err != nil || !token.RevokedAt.IsZero() {
+		t.Errorf("expected the other token to stay active, got %v, %v", token, err)
+	}
+}
@@ -0,0 +1,92 @@
+package persistence
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gossie/modelling-service/domain"
+)
+
+type sqlSessionRepository struct {
+	db      session
+	dialect dialect
+}
+
+func (sr *sqlSessionRepository) SaveSession(ctx context.Context, userEmail string, refreshTokenHash string, expiresAt time.Time) (int, error) {
+	var userId int
+	err := sr.db.QueryRowContext(ctx, "SELECT id FROM users WHERE email = $1", userEmail).Scan(&userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return -1, domain.ErrNotFound
+	}
+	if err != nil {
+		return -1, err
+	}
+
+	var sessionId int
+	sqlStatement := `
+		INSERT INTO sessions (userId, refreshTokenHash, createdAt, expiresAt)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`
+	err = sr.db.QueryRowContext(ctx, sqlStatement, userId, refreshTokenHash, time.Now().UTC(), expiresAt.UTC()).Scan(&sessionId)
+	return sessionId, err
+}
+
+func (sr *sqlSessionRepository) FindSession(ctx context.Context, sessionId int) (domain.Session, error) {
+	sqlStatement := `
+		SELECT s.id, u.email, s.refreshTokenHash, s.previousRefreshTokenHash, s.createdAt, s.rotatedAt, s.expiresAt, s.revokedAt
+		FROM sessions s
+		JOIN users u
+		ON u.id = s.userId
+		WHERE s.id = $1
+	`
+	var session domain.Session
+	var previousRefreshTokenHash sql.NullString
+	var rotatedAt, revokedAt sql.NullTime
+	err := sr.db.QueryRowContext(ctx, sqlStatement, sessionId).Scan(&session.Id, &session.UserEmail, &session.RefreshTokenHash, &previousRefreshTokenHash, &session.CreatedAt, &rotatedAt, &session.ExpiresAt, &revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Session{}, domain.ErrNotFound
+	}
+	if err != nil {
+		return domain.Session{}, err
+	}
+	session.PreviousRefreshTokenHash = previousRefreshTokenHash.String
+	session.RotatedAt = rotatedAt.Time
+	session.RevokedAt = revokedAt.Time
+	return session, nil
+}
+
+func (sr *sqlSessionRepository) RotateRefreshToken(ctx context.Context, sessionId int, expectedHash string, newHash string, rotatedAt time.Time, expiresAt time.Time) error {
+	sqlStatement := `
+		UPDATE sessions
+		SET refreshTokenHash = $1, previousRefreshTokenHash = refreshTokenHash, rotatedAt = $2, expiresAt = $3
+		WHERE id = $4 AND refreshTokenHash = $5 AND revokedAt IS NULL
+	`
+	result, err := sr.db.ExecContext(ctx, sqlStatement, newHash, rotatedAt.UTC(), expiresAt.UTC(), sessionId, expectedHash)
+	if err != nil {
+		return err
+	}
+	return conflictUnlessAffected(ctx, sr.db, result, "SELECT COUNT(*) FROM sessions WHERE id = $1", sessionId)
+}
+
+func (sr *sqlSessionRepository) RevokeSession(ctx context.Context, sessionId int, revokedAt time.Time) error {
+	_, err := sr.db.ExecContext(ctx, "UPDATE sessions SET revokedAt = $1 WHERE id = $2 AND revokedAt IS NULL", revokedAt.UTC(), sessionId)
+	return err
+}
+
+func (sr *sqlSessionRepository) DeleteExpiredSessions(ctx context.Context, before time.Time) (int, error) {
+	sqlStatement := fmt.Sprintf(
+		"DELETE FROM sessions WHERE %v < %v OR %v < %v",
+		sr.dialect.sortableTimestamp("expiresAt"), sr.dialect.sortableTimestamp("$1"),
+		sr.dialect.sortableTimestamp("revokedAt"), sr.dialect.sortableTimestamp("$1"),
+	)
+	result, err := sr.db.ExecContext(ctx, sqlStatement, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	return int(deleted), err
+}
@@ -0,0 +1,25 @@
+package persistence
+
+import (
+	"context"
+	"database/sql"
+
+	_ "modernc.org/sqlite"
+)
+
+// OpenSqlite opens the SQLite database file at path. The file is created if it does not exist.
+func OpenSqlite(ctx context.Context, path string) (*sql.DB, error) {
+	// _time_format=sqlite stores times in a format that the date and time functions of SQLite understand
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_time_format=sqlite"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.PingContext(ctx)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
@@ -0,0 +1,106 @@
+package persistence
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/gossie/modelling-service/persistence"
+
+// tracedSession creates a span for every statement, reading the rows of a query is not part of it.
+type tracedSession struct {
+	session
+	system attribute.KeyValue
+}
+
+func newTracedSession(s session, d dialect) session {
+	system := semconv.DBSystemPostgreSQL
+	if d.name == sqliteDialect.name {
+		system = semconv.DBSystemSqlite
+	}
+	return tracedSession{s, system}
+}
+
+func (s tracedSession) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := startStatement(ctx, s.system, query)
+	result, err := s.session.ExecContext(ctx, query, args...)
+	endStatement(span, err)
+	return result, err
+}
+
+func (s tracedSession) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := startStatement(ctx, s.system, query)
+	rows, err := s.session.QueryContext(ctx, query, args...)
+	endStatement(span, err)
+	return rows, err
+}
+
+func (s tracedSession) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := startStatement(ctx, s.system, query)
+	row := s.session.QueryRowContext(ctx, query, args...)
+	endStatement(span, row.Err())
+	return row
+}
+
+func (s tracedSession) BeginTx(ctx context.Context, opts *sql.TxOptions) (transaction, error) {
+	tx, err := s.session.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return tracedTransaction{tx, s.system}, nil
+}
+
+// tracedTransaction is a transaction that a repository began itself.
+type tracedTransaction struct {
+	transaction
+	system attribute.KeyValue
+}
+
+func (tx tracedTransaction) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := startStatement(ctx, tx.system, query)
+	result, err := tx.transaction.ExecContext(ctx, query, args...)
+	endStatement(span, err)
+	return result, err
+}
+
+func (tx tracedTransaction) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := startStatement(ctx, tx.system, query)
+	rows, err := tx.transaction.QueryContext(ctx, query, args...)
+	endStatement(span, err)
+	return rows, err
+}
+
+func (tx tracedTransaction) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := startStatement(ctx, tx.system, query)
+	row := tx.transaction.QueryRowContext(ctx, query, args...)
+	endStatement(span, row.Err())
+	return row
+}
+
+// startStatement names the span after the operation and leaves out the arguments, they may contain personal data.
+func startStatement(ctx context.Context, system attribute.KeyValue, query string) (context.Context, trace.Span) {
+	query = strings.TrimSpace(query)
+	operation, _, _ := strings.Cut(query, " ")
+	operation = strings.ToUpper(strings.TrimSpace(operation))
+
+	return otel.Tracer(tracerName).Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(system, semconv.DBOperationName(operation), semconv.DBQueryText(query)),
+	)
+}
+
+func endStatement(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,105 @@
+package persistence
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/gossie/modelling-service/domain"
+)
+
+// executor runs statements, either directly on the database or inside a transaction.
+type executor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type transaction interface {
+	executor
+	Commit() error
+	Rollback() error
+}
+
+// session runs the statements of the SQL repositories, it is the database or the transaction of a unit of work.
+type session interface {
+	executor
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (transaction, error)
+}
+
+type dbSession struct {
+	*sql.DB
+}
+
+func (s dbSession) BeginTx(ctx context.Context, opts *sql.TxOptions) (transaction, error) {
+	tx, err := s.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// txSession belongs to a unit of work, transactions that are begun on it become savepoints.
+type txSession struct {
+	*sql.Tx
+	savepoints *int
+}
+
+func (s txSession) BeginTx(ctx context.Context, _ *sql.TxOptions) (transaction, error) {
+	*s.savepoints++
+	sp := savepoint{Tx: s.Tx, ctx: ctx, name: fmt.Sprintf("sp%v", *s.savepoints)}
+	_, err := s.ExecContext(ctx, "SAVEPOINT "+sp.name)
+	if err != nil {
+		return nil, err
+	}
+	return sp, nil
+}
+
+type savepoint struct {
+	*sql.Tx
+	ctx  context.Context
+	name string
+}
+
+func (sp savepoint) Commit() error {
+	_, err := sp.ExecContext(sp.ctx, "RELEASE SAVEPOINT "+sp.name)
+	return err
+}
+
+func (sp savepoint) Rollback() error {
+	_, err := sp.ExecContext(sp.ctx, "ROLLBACK TO SAVEPOINT "+sp.name)
+	return err
+}
+
+type sqlUnitOfWork struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+func (u *sqlUnitOfWork) Do(ctx context.Context, work func(domain.Repositories) error) error {
+	tx, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	joined := &joinedUnitOfWork{}
+	repositories := sqlRepositories(txSession{Tx: tx, savepoints: new(int)}, u.dialect)
+	repositories.UnitOfWork = joined
+	joined.repositories = repositories
+
+	err = work(repositories)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// joinedUnitOfWork is the unit of work of repositories that already belong to one, nested work joins it.
+type joinedUnitOfWork struct {
+	repositories domain.Repositories
+}
+
+func (u *joinedUnitOfWork) Do(ctx context.Context, work func(domain.Repositories) error) error {
+	return work(u.repositories)
+}
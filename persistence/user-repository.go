@@ -0,0 +1,38 @@
+package persistence
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/gossie/modelling-service/domain"
+)
+
+type sqlUserRepository struct {
+	db session
+}
+
+func NewPsqlUserRepository(db *sql.DB) sqlUserRepository {
+	return sqlUserRepository{db: dbSession{db}}
+}
+
+func NewSqliteUserRepository(db *sql.DB) sqlUserRepository {
+	return sqlUserRepository{db: dbSession{db}}
+}
+
+func (ur *sqlUserRepository) FindByEmail(ctx context.Context, email string) (domain.User, error) {
+	user := domain.User{Email: email}
+	err := ur.db.QueryRowContext(ctx, "SELECT id FROM users WHERE email = $1", email).Scan(&user.Id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.User{}, domain.ErrNotFound
+	}
+	return user, err
+}
+
+func (ur *sqlUserRepository) SaveUser(ctx context.Context, email string) (domain.User, error) {
+	_, err := ur.db.ExecContext(ctx, "INSERT INTO users (email) VALUES ($1) ON CONFLICT (email) DO NOTHING", email)
+	if err != nil {
+		return domain.User{}, err
+	}
+	return ur.FindByEmail(ctx, email)
+}
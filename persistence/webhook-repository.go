@@ -0,0 +1,171 @@
+package persistence
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gossie/modelling-service/domain"
+)
+
+// deliveryColumns are the columns that scanDelivery expects, d are the deliveries and w their webhooks.
+const deliveryColumns = "d.id, d.webhookId, w.url, d.event, d.idempotencyKey, d.payload, d.status, d.attempts, d.lastError, d.responseStatus, d.nextAttemptAt, d.createdAt, d.deliveredAt"
+
+type sqlWebhookRepository struct {
+	db      session
+	dialect dialect
+}
+
+func (wr *sqlWebhookRepository) FindAllByModelId(ctx context.Context, modelId int) ([]domain.Webhook, error) {
+	rows, err := wr.db.QueryContext(ctx, "SELECT id, modelId, url, secret, createdAt FROM webhooks WHERE modelId = $1 ORDER BY id", modelId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := make([]domain.Webhook, 0)
+	for rows.Next() {
+		var webhook domain.Webhook
+		err = rows.Scan(&webhook.Id, &webhook.ModelId, &webhook.Url, &webhook.Secret, &webhook.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+func (wr *sqlWebhookRepository) SaveWebhook(ctx context.Context, modelId int, wcr domain.WebhookCreationRequest) (int, error) {
+	var webhookId int
+	err := wr.db.QueryRowContext(ctx, "INSERT INTO webhooks (modelId, url, secret) VALUES ($1, $2, $3) RETURNING id", modelId, wcr.Url, wcr.Secret).Scan(&webhookId)
+	return webhookId, err
+}
+
+// DeleteWebhook also deletes the deliveries of the webhook, because they reference it with ON DELETE CASCADE.
+func (wr *sqlWebhookRepository) DeleteWebhook(ctx context.Context, modelId, webhookId int) error {
+	_, err := wr.db.ExecContext(ctx, "DELETE FROM webhooks WHERE id = $1 AND modelId = $2", webhookId, modelId)
+	return err
+}
+
+// EnqueueDeliveries relies on the unique index on webhookId and idempotencyKey to skip webhooks that already have a delivery of the event.
+func (wr *sqlWebhookRepository) EnqueueDeliveries(ctx context.Context, modelId int, idempotencyKey string, event string, payload string) error {
+	now := time.Now().UTC()
+	sqlStatement := `
+		INSERT INTO webhook_deliveries (webhookId, event, idempotencyKey, payload, status, nextAttemptAt, createdAt)
+		SELECT id, $1, $2, $3, $4, $5, $5 FROM webhooks WHERE modelId = $6
+		ON CONFLICT DO NOTHING
+	`
+	_, err := wr.db.ExecContext(ctx, sqlStatement, event, nullString(idempotencyKey), payload, domain.DeliveryPending, now, modelId)
+	return err
+}
+
+func (wr *sqlWebhookRepository) FindDueDeliveries(ctx context.Context, now time.Time, limit int) ([]domain.DueDelivery, error) {
+	sqlStatement := fmt.Sprintf(`
+		SELECT %v, w.modelId, w.secret, w.createdAt
+		FROM webhook_deliveries d
+		JOIN webhooks w
+		ON w.id = d.webhookId
+		WHERE d.status = $1 AND %v <= %v
+		ORDER BY d.nextAttemptAt, d.id
+		LIMIT $3
+	`, deliveryColumns, wr.dialect.sortableTimestamp("d.nextAttemptAt"), wr.dialect.sortableTimestamp("$2"))
+	rows, err := wr.db.QueryContext(ctx, sqlStatement, domain.DeliveryPending, now.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	due := make([]domain.DueDelivery, 0)
+	for rows.Next() {
+		var d domain.DueDelivery
+		err = scanDelivery(rows, &d.Delivery, &d.Webhook.ModelId, &d.Webhook.Secret, &d.Webhook.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		d.Webhook.Id = d.Delivery.WebhookId
+		d.Webhook.Url = d.Delivery.Url
+		due = append(due, d)
+	}
+	return due, rows.Err()
+}
+
+func (wr *sqlWebhookRepository) UpdateDelivery(ctx context.Context, delivery domain.WebhookDelivery) error {
+	sqlStatement := `
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = $2, lastError = $3, responseStatus = $4, nextAttemptAt = $5, deliveredAt = $6
+		WHERE id = $7
+	`
+	deliveredAt := sql.NullTime{Time: delivery.DeliveredAt.UTC(), Valid: !delivery.DeliveredAt.IsZero()}
+	responseStatus := sql.NullInt32{Int32: int32(delivery.ResponseStatus), Valid: delivery.ResponseStatus != 0}
+	result, err := wr.db.ExecContext(ctx, sqlStatement, delivery.Status, delivery.Attempts, nullString(delivery.LastError), responseStatus, delivery.NextAttemptAt.UTC(), deliveredAt, delivery.Id)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (wr *sqlWebhookRepository) FindDeliveriesByModelId(ctx context.Context, modelId int, limit int) ([]domain.WebhookDelivery, error) {
+	sqlStatement := `
+		SELECT ` + deliveryColumns + `
+		FROM webhook_deliveries d
+		JOIN webhooks w
+		ON w.id = d.webhookId
+		WHERE w.modelId = $1
+		ORDER BY d.id DESC
+		LIMIT $2
+	`
+	rows, err := wr.db.QueryContext(ctx, sqlStatement, modelId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]domain.WebhookDelivery, 0)
+	for rows.Next() {
+		var delivery domain.WebhookDelivery
+		err = scanDelivery(rows, &delivery)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+func (wr *sqlWebhookRepository) RetryDelivery(ctx context.Context, modelId, deliveryId int) error {
+	sqlStatement := `
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = 0, nextAttemptAt = $2
+		WHERE id = $3 AND webhookId IN (SELECT id FROM webhooks WHERE modelId = $4)
+	`
+	result, err := wr.db.ExecContext(ctx, sqlStatement, domain.DeliveryPending, time.Now().UTC(), deliveryId, modelId)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// scanDelivery scans the deliveryColumns followed by the extra destinations.
+func scanDelivery(rows *sql.Rows, delivery *domain.WebhookDelivery, extra ...any) error {
+	var idempotencyKey, lastError sql.NullString
+	var responseStatus sql.NullInt32
+	var deliveredAt sql.NullTime
+	dest := []any{&delivery.Id, &delivery.WebhookId, &delivery.Url, &delivery.Event, &idempotencyKey, &delivery.Payload, &delivery.Status, &delivery.Attempts, &lastError, &responseStatus, &delivery.NextAttemptAt, &delivery.CreatedAt, &deliveredAt}
+	err := rows.Scan(append(dest, extra...)...)
+	if err != nil {
+		return err
+	}
+
+	delivery.IdempotencyKey = idempotencyKey.String
+	delivery.LastError = lastError.String
+	delivery.ResponseStatus = int(responseStatus.Int32)
+	delivery.DeliveredAt = deliveredAt.Time
+	return nil
+}
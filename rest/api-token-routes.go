@@ -0,0 +1,133 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gossie/modelling-service/domain"
+	"github.com/gossie/modelling-service/middleware"
+	"github.com/gossie/modelling-service/views"
+)
+
+// maxApiTokenNameLength keeps the names readable in the token list.
+const maxApiTokenNameLength = 100
+
+func (s *Server) GetApiTokens(v *views.View) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		email := r.Context().Value(middleware.UserIdentifierKey).(string)
+		slog.InfoContext(r.Context(), fmt.Sprintf("retrieving API tokens of %v", email))
+
+		s.renderApiTokens(v, w, r, email, "", "")
+	}
+}
+
+func (s *Server) PostApiToken(v *views.View) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		email := r.Context().Value(middleware.UserIdentifierKey).(string)
+		slog.InfoContext(r.Context(), fmt.Sprintf("creating API token of %v", email))
+
+		name := strings.TrimSpace(r.FormValue("name"))
+		if name == "" || len(name) > maxApiTokenNameLength {
+			s.renderApiTokens(v, w, r, email, "", fmt.Sprintf("Der Name muss zwischen 1 und %v Zeichen lang sein", maxApiTokenNameLength))
+			return
+		}
+
+		var scopes []string
+		switch r.FormValue("scope") {
+		case domain.ScopeRead:
+			scopes = []string{domain.ScopeRead}
+		case domain.ScopeWrite:
+			scopes = []string{domain.ScopeRead, domain.ScopeWrite}
+		default:
+			s.renderApiTokens(v, w, r, email, "", "Unbekannte Berechtigung")
+			return
+		}
+
+		days, err := strconv.Atoi(r.FormValue("lifetimeDays"))
+		lifetime := time.Duration(days) * 24 * time.Hour
+		if err != nil || days < 1 || lifetime > s.config.ApiTokens.MaxLifetime {
+			s.renderApiTokens(v, w, r, email, "", fmt.Sprintf("Ein Token darf höchstens %v Tage gültig sein", int(s.config.ApiTokens.MaxLifetime.Hours()/24)))
+			return
+		}
+
+		token, err := s.apiTokens.Create(r.Context(), email, name, scopes, lifetime)
+		if err != nil {
+			slog.WarnContext(r.Context(), fmt.Sprintf("error creating API token of %v: %v", email, err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		s.renderApiTokens(v, w, r, email, token, "")
+	}
+}
+
+func (s *Server) DeleteApiToken(v *views.View) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		email := r.Context().Value(middleware.UserIdentifierKey).(string)
+		tokenId, err := strconv.Atoi(r.PathValue("tokenId"))
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		slog.InfoContext(r.Context(), fmt.Sprintf("revoking API token %v of %v", tokenId, email))
+
+		err = s.apiTokenRepository.RevokeApiToken(r.Context(), email, tokenId, time.Now())
+		if errors.Is(err, domain.ErrNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.WarnContext(r.Context(), fmt.Sprintf("error revoking API token %v: %v", tokenId, err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		s.renderApiTokens(v, w, r, email, "", "")
+	}
+}
+
+func (s *Server) renderApiTokens(v *views.View, w http.ResponseWriter, r *http.Request, email, newToken, errorMessage string) {
+	tokens, err := s.apiTokenRepository.FindApiTokensByUser(r.Context(), email)
+	if err != nil {
+		slog.WarnContext(r.Context(), fmt.Sprintf("could not retrieve API tokens of %v: %v", email, err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	tokensToRender := make([]RenderApiToken, len(tokens))
+	for i, token := range tokens {
+		tokensToRender[i] = RenderApiToken{
+			Id:        token.Id,
+			Name:      token.Name,
+			Scopes:    strings.Join(token.Scopes, ", "),
+			CreatedAt: formatTime(token.CreatedAt),
+			ExpiresAt: formatTime(token.ExpiresAt),
+			LastUsed:  "nie",
+			Status:    "Aktiv",
+			Active:    true,
+		}
+		if !token.LastUsedAt.IsZero() {
+			tokensToRender[i].LastUsed = fmt.Sprintf("%v von %v", formatTime(token.LastUsedAt), token.LastUsedFrom)
+		}
+		switch {
+		case !token.RevokedAt.IsZero():
+			tokensToRender[i].Status = "Widerrufen am " + formatTime(token.RevokedAt)
+			tokensToRender[i].Active = false
+		case !now.Before(token.ExpiresAt):
+			tokensToRender[i].Status = "Abgelaufen"
+			tokensToRender[i].Active = false
+		}
+	}
+
+	v.Render(r.Context(), w, ApiTokensRenderContext{
+		Tokens:   tokensToRender,
+		NewToken: newToken,
+		Error:    errorMessage,
+	})
+}
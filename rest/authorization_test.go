@@ -0,0 +1,395 @@
+package rest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gossie/modelling-service/config"
+	"github.com/gossie/modelling-service/domain"
+	"github.com/gossie/modelling-service/events"
+	"github.com/gossie/modelling-service/metrics"
+	"github.com/gossie/modelling-service/middleware"
+	"github.com/gossie/modelling-service/persistence"
+)
+
+const (
+	modelOwner = "owner@example.com"
+	stranger   = "stranger@example.com"
+	adminToken = "admin-token"
+)
+
+// adminRoutes only accept the admin token, a session does not get a user through.
+var adminRoutes = []string{"GET /metrics", "GET /stats/database"}
+
+var pathValue = regexp.MustCompile(`{[^}]+}`)
+
+// requestTo builds a request for the route pattern, the model ID is filled in and all other path values are 1.
+func requestTo(pattern string, modelId int) *http.Request {
+	method, path, _ := strings.Cut(pattern, " ")
+	path = strings.ReplaceAll(path, "{modelId}", fmt.Sprint(modelId))
+	path = pathValue.ReplaceAllString(path, "1")
+	return httptest.NewRequest(method, path, nil)
+}
+
+// authenticateAs starts a session of the user and adds its cookies to the request.
+func authenticateAs(t *testing.T, s *Server, r *http.Request, email string) {
+	t.Helper()
+	if email == "" {
+		return
+	}
+	recorder := httptest.NewRecorder()
+	err := s.sessions.Login(context.Background(), recorder, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cookie := range recorder.Result().Cookies() {
+		r.AddCookie(cookie)
+	}
+}
+
+func newAuthorizationTestServer(t *testing.T) (*Server, int) {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Jwt.Secret = "test-secret"
+	cfg.Logging.AccessLog.Enabled = false
+	cfg.Server.AdminToken = adminToken
+	repositories := events.Recording(persistence.NewMemoryRepositories(modelOwner, stranger), func() {})
+	s := NewServer(repositories, events.NewBroker(), cfg, metrics.New())
+
+	ctx := context.WithValue(context.Background(), middleware.LanguageKey, "en")
+	modelId, err := s.modelRepository.SaveModel(ctx, modelOwner, domain.ModelCreationRequest{Name: "car"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, modelId
+}
+
+// TestEveryRouteIsProtected sends a request to every route of the route table as an anonymous user, as the owner of
+// the model and as a user of another model. The handler of a route is replaced, so that the test only sees whether
+// the middleware of the route let the request through.
+func TestEveryRouteIsProtected(t *testing.T) {
+	s, modelId := newAuthorizationTestServer(t)
+
+	for _, route := range s.routeTable() {
+		modelMember := strings.Contains(route.pattern, "{modelId}")
+		admin := slices.Contains(adminRoutes, route.pattern)
+		public := !admin && !modelMember && !strings.Contains(route.pattern, " /models") && !strings.Contains(route.pattern, " /settings")
+
+		tests := []struct {
+			user           string
+			expectedStatus int
+		}{
+			{"", http.StatusUnauthorized},
+			{modelOwner, http.StatusTeapot},
+			{stranger, http.StatusForbidden},
+		}
+		for _, test := range tests {
+			switch {
+			case admin:
+				test.expectedStatus = http.StatusUnauthorized
+			case public:
+				test.expectedStatus = http.StatusTeapot
+			case !modelMember && test.user != "":
+				test.expectedStatus = http.StatusTeapot
+			}
+
+			name := test.user
+			if name == "" {
+				name = "anonymous"
+			}
+			t.Run(route.pattern+" as "+name, func(t *testing.T) {
+				handled := false
+				mux := http.NewServeMux()
+				mux.HandleFunc(route.pattern, middleware.Chain(func(w http.ResponseWriter, r *http.Request) {
+					handled = true
+					w.WriteHeader(http.StatusTeapot)
+				}, route.middleware...))
+
+				request := requestTo(route.pattern, modelId)
+				authenticateAs(t, s, request, test.user)
+				recorder := httptest.NewRecorder()
+				mux.ServeHTTP(recorder, request)
+
+				if recorder.Code != test.expectedStatus {
+					t.Errorf("expected %v, got %v", test.expectedStatus, recorder.Code)
+				}
+				if expected := test.expectedStatus == http.StatusTeapot; handled != expected {
+					t.Errorf("expected the handler to be called: %v, but it was called: %v", expected, handled)
+				}
+			})
+		}
+	}
+}
+
+func TestAdminRoutesAcceptTheAdminToken(t *testing.T) {
+	s, _ := newAuthorizationTestServer(t)
+	s.SetDatabaseStats(func() sql.DBStats { return sql.DBStats{} })
+
+	tests := []struct {
+		authorization  string
+		expectedStatus int
+	}{
+		{"Bearer " + adminToken, http.StatusOK},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{adminToken, http.StatusUnauthorized},
+		{"", http.StatusUnauthorized},
+	}
+	for _, route := range adminRoutes {
+		for _, test := range tests {
+			t.Run(route+" with "+test.authorization, func(t *testing.T) {
+				request := requestTo(route, 0)
+				if test.authorization != "" {
+					request.Header.Set("Authorization", test.authorization)
+				}
+				recorder := httptest.NewRecorder()
+				s.ServeHTTP(recorder, request)
+
+				if recorder.Code != test.expectedStatus {
+					t.Errorf("expected %v, got %v", test.expectedStatus, recorder.Code)
+				}
+			})
+		}
+	}
+}
+
+func TestAdminRoutesAreDisabledWithoutAdminToken(t *testing.T) {
+	cfg := config.Default()
+	cfg.Jwt.Secret = "test-secret"
+	cfg.Logging.AccessLog.Enabled = false
+	s := NewServer(persistence.NewMemoryRepositories(), events.NewBroker(), cfg, metrics.New())
+
+	for _, route := range adminRoutes {
+		t.Run(route, func(t *testing.T) {
+			request := requestTo(route, 0)
+			request.Header.Set("Authorization", "Bearer ")
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, request)
+
+			if recorder.Code != http.StatusNotFound {
+				t.Errorf("expected 404, got %v", recorder.Code)
+			}
+		})
+	}
+}
+
+func TestStrangersAreForbiddenOnTheServer(t *testing.T) {
+	s, modelId := newAuthorizationTestServer(t)
+
+	for _, route := range s.routeTable() {
+		if !strings.Contains(route.pattern, "{modelId}") {
+			continue
+		}
+		t.Run(route.pattern, func(t *testing.T) {
+			// the event stream would stay open if the stranger got through
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			request := requestTo(route.pattern, modelId).WithContext(ctx)
+			authenticateAs(t, s, request, stranger)
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, request)
+
+			if recorder.Code != http.StatusForbidden {
+				t.Errorf("expected 403, got %v", recorder.Code)
+			}
+			if recorder.Body.Len() > 0 {
+				t.Errorf("expected an empty body, got %v", recorder.Body.String())
+			}
+		})
+	}
+
+	ctx := context.WithValue(context.Background(), middleware.LanguageKey, "en")
+	parameters, err := s.parameterRepository.FindAllByModelId(ctx, modelId, "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parameters) > 0 {
+		t.Errorf("expected the model to be unchanged, got the parameters %v", parameters)
+	}
+}
+
+// TestNestedResourcesOfOtherModelsAreNotFound lets a stranger use the IDs of another model's resources through the own
+// model, which the stranger is authorized for.
+func TestNestedResourcesOfOtherModelsAreNotFound(t *testing.T) {
+	s, modelId := newAuthorizationTestServer(t)
+	ctx := context.WithValue(context.Background(), middleware.LanguageKey, "en")
+	ownModelId, err := s.modelRepository.SaveModel(ctx, stranger, domain.ModelCreationRequest{Name: "bike"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parameterId, err := s.parameterRepository.SaveParameter(ctx, modelId, domain.ParameterCreationRequest{Name: "color"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	groupId, err := s.parameterGroupRepository.SaveGroup(ctx, modelId, domain.ParameterGroupCreationRequest{Name: "exterior"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method, path, body string
+	}{
+		{http.MethodGet, "/models/%v/parameters/%v/translations", ""},
+		{http.MethodPatch, "/models/%v/parameters/%v/translations", `{"newTranslations": [{"field": "name", "language": "de", "value": "Farbe"}]}`},
+		{http.MethodPatch, "/models/%v/parameters/%v/values", `{"newValues": ["red"]}`},
+		{http.MethodPost, "/models/%v/parameters/%v/values", "value=red"},
+		{http.MethodPut, "/models/%v/parameters/%v/values/1", "value=red"},
+		{http.MethodDelete, "/models/%v/parameters/%v/values/1", ""},
+		{http.MethodGet, "/models/%v/groups/%v/translations", ""},
+		{http.MethodPatch, "/models/%v/groups/%v/translations", `{"newTranslations": [{"language": "de", "value": "Außen"}]}`},
+	}
+	for _, test := range tests {
+		nestedId := parameterId
+		if strings.Contains(test.path, "/groups/") {
+			nestedId = groupId
+		}
+		path := fmt.Sprintf(test.path, ownModelId, nestedId)
+		t.Run(test.method+" "+path, func(t *testing.T) {
+			request := httptest.NewRequest(test.method, path, strings.NewReader(test.body))
+			if strings.Contains(test.body, "=") {
+				request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			authenticateAs(t, s, request, stranger)
+			recorder := httptest.NewRecorder()
+			s.ServeHTTP(recorder, request)
+
+			if recorder.Code != http.StatusNotFound {
+				t.Errorf("expected 404, got %v", recorder.Code)
+			}
+		})
+	}
+
+	parameter, err := s.parameterRepository.FindById(ctx, modelId, parameterId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parameter.Value.Values) > 0 || parameter.Translation != "" || parameter.Version != 1 {
+		t.Errorf("expected the parameter to be unchanged, got %v", parameter)
+	}
+	translations, err := s.parameterGroupRepository.FindAllTranslations(ctx, modelId, groupId)
+	if err != nil || len(translations) > 0 {
+		t.Errorf("expected the group to be untranslated, got %v, %v", translations, err)
+	}
+}
+
+func TestLoggedOutSessionsAreUnauthorized(t *testing.T) {
+	s, _ := newAuthorizationTestServer(t)
+	logout := httptest.NewRequest(http.MethodPost, "/logout", nil)
+	authenticateAs(t, s, logout, modelOwner)
+
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, logout)
+	if recorder.Code != http.StatusSeeOther || recorder.Header().Get("Location") != "/" {
+		t.Errorf("expected a redirect to the login page, got %v %v", recorder.Code, recorder.Header().Get("Location"))
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/models", nil)
+	for _, cookie := range logout.Cookies() {
+		request.AddCookie(cookie)
+	}
+	recorder = httptest.NewRecorder()
+	s.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %v", recorder.Code)
+	}
+}
+
+var apiToken = regexp.MustCompile(`mmpat_(\d+)_[\w-]+`)
+
+func TestApiTokensFromTheSettingsPageAuthenticateScripts(t *testing.T) {
+	s, modelId := newAuthorizationTestServer(t)
+
+	create := httptest.NewRequest(http.MethodPost, "/settings/tokens", strings.NewReader("name=ci&scope=read&lifetimeDays=30"))
+	create.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	authenticateAs(t, s, create, modelOwner)
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, create)
+	match := apiToken.FindStringSubmatch(recorder.Body.String())
+	if recorder.Code != http.StatusOK || match == nil {
+		t.Fatalf("expected the new token to be shown, got %v %v", recorder.Code, recorder.Body.String())
+	}
+	token, tokenId := match[0], match[1]
+
+	script := func(method, path string) int {
+		request := httptest.NewRequest(method, path, nil)
+		request.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+	tests := []struct {
+		method, path   string
+		expectedStatus int
+	}{
+		{http.MethodGet, fmt.Sprintf("/models/%v/export", modelId), http.StatusOK},
+		{http.MethodPost, fmt.Sprintf("/models/%v/publish", modelId), http.StatusForbidden},
+		{http.MethodGet, "/settings/tokens", http.StatusUnauthorized},
+		{http.MethodDelete, "/settings/tokens/" + tokenId, http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		if status := script(test.method, test.path); status != test.expectedStatus {
+			t.Errorf("expected %v for %v %v, got %v", test.expectedStatus, test.method, test.path, status)
+		}
+	}
+
+	revoke := httptest.NewRequest(http.MethodDelete, "/settings/tokens/"+tokenId, nil)
+	authenticateAs(t, s, revoke, stranger)
+	recorder = httptest.NewRecorder()
+	s.ServeHTTP(recorder, revoke)
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("expected strangers not to find the token, got %v", recorder.Code)
+	}
+
+	revoke = httptest.NewRequest(http.MethodDelete, "/settings/tokens/"+tokenId, nil)
+	authenticateAs(t, s, revoke, modelOwner)
+	recorder = httptest.NewRecorder()
+	s.ServeHTTP(recorder, revoke)
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "Widerrufen am") {
+		t.Errorf("expected the token to be listed as revoked, got %v %v", recorder.Code, recorder.Body.String())
+	}
+	if status := script(http.MethodGet, fmt.Sprintf("/models/%v/export", modelId)); status != http.StatusUnauthorized {
+		t.Errorf("expected the revoked token to be rejected, got %v", status)
+	}
+}
+
+func TestEventStreamsEndWithTheSession(t *testing.T) {
+	interval := keepAliveInterval
+	keepAliveInterval = 10 * time.Millisecond
+	t.Cleanup(func() { keepAliveInterval = interval })
+
+	s, modelId := newAuthorizationTestServer(t)
+	stream := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/models/%v/events", modelId), nil)
+	authenticateAs(t, s, stream, modelOwner)
+
+	done := make(chan struct{})
+	recorder := httptest.NewRecorder()
+	go func() {
+		defer close(done)
+		s.ServeHTTP(recorder, stream)
+	}()
+
+	select {
+	case <-done:
+		t.Fatalf("expected the stream to stay open, got %v", recorder.Code)
+	case <-time.After(5 * keepAliveInterval):
+	}
+
+	if err := s.sessions.Logout(httptest.NewRecorder(), stream); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the stream to end after the logout")
+	}
+	if !strings.Contains(recorder.Body.String(), ": keep-alive") {
+		t.Errorf("expected keep-alives while the session was active, got %v", recorder.Body.String())
+	}
+}
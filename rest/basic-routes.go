@@ -1,77 +1,89 @@
 package rest
 
 import (
-	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
-	"time"
 
-	"github.com/golang-jwt/jwt"
+	"github.com/gossie/modelling-service/domain"
 	"github.com/gossie/modelling-service/views"
 )
 
+type indexPage struct {
+	SingleSignOn bool
+}
+
 func (s *Server) GetIndex(v *views.View) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		slog.InfoContext(r.Context(), "retrieving index page")
-		v.Render(r.Context(), w, nil)
+		v.Render(r.Context(), w, indexPage{SingleSignOn: s.oidc != nil})
 	}
 }
 
-func (s *Server) Login(secret string, v *views.View) http.HandlerFunc {
+func (s *Server) Login(v *views.View) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		email := r.FormValue("email")
 
 		slog.InfoContext(r.Context(), fmt.Sprintf("loging in %v", email))
 		// check username & password
 
-		var userId int
-		row := s.db.QueryRowContext(r.Context(), "SELECT id FROM users WHERE email = $1", email)
-		switch err := row.Scan(&userId); err {
-		case sql.ErrNoRows:
+		_, err := s.userRepository.FindByEmail(r.Context(), email)
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
 			slog.InfoContext(r.Context(), fmt.Sprintf("could not find user with email %v", email))
 			w.WriteHeader(http.StatusNotFound)
-		case nil:
+		case err != nil:
+			slog.WarnContext(r.Context(), fmt.Sprintf("could not retrieve user with email %v: %v", email, err.Error()))
+			http.Error(w, err.Error(), 500)
+		default:
 			slog.InfoContext(r.Context(), fmt.Sprintf("found user with email %v", email))
-			token, err := createToken(secret, email)
-			if err != nil {
-				slog.WarnContext(r.Context(), fmt.Sprintf("could not create token: %v", err.Error()))
-				http.Error(w, err.Error(), 500)
-				return
-			}
-
-			expiration := time.Now().Add(24 * time.Hour)
-			cookie := http.Cookie{Name: "accessToken", Value: token, Expires: expiration}
-			http.SetCookie(w, &cookie)
-
-			models, err := s.modelRepository.FindAllByUser(r.Context(), email)
+			err = s.sessions.Login(r.Context(), w, email)
 			if err != nil {
-				slog.WarnContext(r.Context(), fmt.Sprintf("error retrieving models from database: %v", err.Error()))
+				slog.WarnContext(r.Context(), fmt.Sprintf("could not start a session: %v", err.Error()))
 				http.Error(w, err.Error(), 500)
 				return
 			}
 
-			renderModels := make([]RenderModel, len(models))
-			for i := range len(models) {
-				renderModels[i] = RenderModel{Id: models[i].Id, Name: valueOrDefault(models[i].Name, models[i].Translation)}
-			}
-
-			v.Render(r.Context(), w, ModelCatalogRenderContext{Models: renderModels})
+			renderModelCatalog(v, w, r, s.modelRepository, email)
 		}
 	}
 }
 
-func createToken(secret string, email string) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256,
-		jwt.MapClaims{
-			"sub": email,
-			"exp": time.Now().Add(time.Hour * 24).Unix(),
-		})
+// Logout ends the session of the request, the browser is sent to the login page.
+func (s *Server) Logout(w http.ResponseWriter, r *http.Request) {
+	err := s.sessions.Logout(w, r)
+	if err != nil {
+		slog.WarnContext(r.Context(), fmt.Sprintf("could not revoke the session: %v", err.Error()))
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
 
-	tokenString, err := token.SignedString([]byte(secret))
+// StartOidcLogin sends the browser to the identity provider.
+func (s *Server) StartOidcLogin(w http.ResponseWriter, r *http.Request) {
+	location, err := s.oidc.StartLogin(r.Context(), w)
 	if err != nil {
-		return "", err
+		slog.WarnContext(r.Context(), fmt.Sprintf("could not start the login at the identity provider: %v", err.Error()))
+		http.Error(w, err.Error(), 500)
+		return
 	}
+	http.Redirect(w, r, location, http.StatusFound)
+}
 
-	return tokenString, nil
+// FinishOidcLogin is where the identity provider sends the browser back to, the user lands on the model catalog.
+func (s *Server) FinishOidcLogin(w http.ResponseWriter, r *http.Request) {
+	email, err := s.oidc.FinishLogin(w, r)
+	switch {
+	case errors.Is(err, domain.ErrUnauthenticated):
+		slog.InfoContext(r.Context(), fmt.Sprintf("login through the identity provider failed: %v", err.Error()))
+		w.WriteHeader(http.StatusUnauthorized)
+	case err != nil:
+		slog.WarnContext(r.Context(), fmt.Sprintf("could not finish the login through the identity provider: %v", err.Error()))
+		http.Error(w, err.Error(), 500)
+	default:
+		slog.InfoContext(r.Context(), fmt.Sprintf("logged in %v through the identity provider", email))
+		http.Redirect(w, r, "/models", http.StatusSeeOther)
+	}
 }
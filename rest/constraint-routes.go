@@ -2,6 +2,7 @@ package rest
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -24,6 +25,11 @@ func (s *Server) PostConstraint(w http.ResponseWriter, r *http.Request) {
 	}
 
 	parameterId, err := s.constraintRepository.SaveConstraint(r.Context(), r.PathValue("modelId"), ccr)
+	if errors.Is(err, domain.ErrNotFound) {
+		slog.InfoContext(r.Context(), "constraint refers to parameters or values of another model")
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		slog.WarnContext(r.Context(), fmt.Sprintf("error creating new constraint: %v", err.Error()))
 		w.WriteHeader(http.StatusInternalServerError)
@@ -43,7 +49,22 @@ func (s *Server) DeleteConstraint(w http.ResponseWriter, r *http.Request) {
 	modelId, constraintId := r.PathValue("modelId"), r.PathValue("constraintId")
 	slog.InfoContext(r.Context(), fmt.Sprintf("deleting constraint - modelId: %v, constraintId: %v", modelId, constraintId))
 
-	err := s.constraintRepository.DeleteConstraint(r.Context(), modelId, constraintId)
+	version, err := expectedVersion(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = s.constraintRepository.DeleteConstraint(r.Context(), modelId, constraintId, version)
+	if errors.Is(err, domain.ErrConflict) {
+		slog.InfoContext(r.Context(), fmt.Sprintf("constraint with id %v was modified concurrently", constraintId))
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, domain.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		slog.WarnContext(r.Context(), fmt.Sprintf("error deleting constraint - modelId = %v, parameterId = %v: %v", modelId, constraintId, err.Error()))
 		w.WriteHeader(http.StatusInternalServerError)
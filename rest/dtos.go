@@ -1,31 +1,175 @@
 package rest
 
+import configurationmodel "github.com/gossie/configuration-model"
+
 type RenderModel struct {
 	Id   int
 	Name string
 }
 
 type RenderParameter struct {
-	Id      int
+	Id        int
+	ModelId   int
+	GroupId   int
+	Name      string
+	ValueType configurationmodel.ValueType
+	Unit      string
+	Required  bool
+	Values    []string
+	Version   int
+}
+
+type RenderValue struct {
+	Id          int
+	Value       string
+	Translation string
+	Min         string
+	Max         string
+	Version     int
+}
+
+type ValueEditorRenderContext struct {
+	ModelId       int
+	ParameterId   int
+	ValueType     configurationmodel.ValueType
+	ValueTypeName string
+	InputType     string
+	IsRange       bool
+	CanAdd        bool
+	Description   string
+	Unit          string
+	HasUnit       bool
+	Required      bool
+	DefaultValue  string
+	Values        []RenderValue
+	Error         string
+	// ConflictingValueId is set when deleting the value failed because constraints reference it
+	ConflictingValueId int
+	// Version is the version of the parameter that the editor shows
+	Version int
+	// Conflict is set when the edit was based on an outdated version of the parameter
+	Conflict *EditConflict
+	// etagValueId names the value whose version is sent as ETag instead of the parameter's
+	etagValueId int
+}
+
+// EditConflict describes the rejected request, so that the modeller can send it again based on the current version.
+type EditConflict struct {
+	Method string
+	Url    string
+	Fields []FormField
+	// ValueId is set when a single value was edited, its current version is sent again instead of the parameter's
+	ValueId      int
+	ValueVersion int
+}
+
+type FormField struct {
+	Name  string
+	Value string
+}
+
+type RenderParameterGroup struct {
+	Id   int
+	Name string
+}
+
+// RenderParameterRow is either the header of a group or a parameter.
+type RenderParameterRow struct {
+	Group     *RenderParameterGroup
+	Parameter *RenderParameter
+}
+
+type ParameterQuery struct {
+	Search         string
+	Sort           string
+	Order          string
+	ValueType      string
+	HasValues      string
+	HasConstraints string
+	Untranslated   bool
+}
+
+type ParameterListRenderContext struct {
 	ModelId int
-	Name    string
-	Values  []string
+	// Layout is true if the parameters are shown in the order defined by the modeller, grouped and rearrangeable
+	Layout  bool
+	Query   ParameterQuery
+	Rows    []RenderParameterRow
+	NextUrl string
+	// Conflict is set when a change was rejected because the parameter was modified in the meantime
+	Conflict string
 }
 
 type RenderConstraint struct {
 }
 
+type ModelQuery struct {
+	Sort           string
+	Order          string
+	Untranslated   bool
+	HasConstraints bool
+}
+
 type ModelCatalogRenderContext struct {
-	Models []RenderModel
+	Query   ModelQuery
+	Models  []RenderModel
+	NextUrl string
 }
 
 type ModelRenderContext struct {
-	Model       RenderModel
-	Parameters  []RenderParameter
-	Constraints []RenderConstraint
+	Model         RenderModel
+	ParameterList ParameterListRenderContext
+	Constraints   []RenderConstraint
+	// Viewers are the users who currently look at the model
+	Viewers []string
 }
 
 // TODO: delete
 type parameterCreationResponse struct {
 	ParameterId int
 }
+
+type RenderWebhook struct {
+	Id     int
+	Url    string
+	Secret string
+}
+
+type RenderDelivery struct {
+	Id             int
+	Url            string
+	Event          string
+	Status         string
+	Dead           bool
+	Attempts       int
+	ResponseStatus int
+	LastError      string
+	CreatedAt      string
+	// NextAttemptAt is only set for deliveries that failed and will be retried
+	NextAttemptAt string
+}
+
+type RenderApiToken struct {
+	Id        int
+	Name      string
+	Scopes    string
+	CreatedAt string
+	ExpiresAt string
+	LastUsed  string
+	Status    string
+	Active    bool
+}
+
+// ApiTokensRenderContext shows a new token once, right after it was created.
+type ApiTokensRenderContext struct {
+	Tokens   []RenderApiToken
+	NewToken string
+	Error    string
+}
+
+type WebhooksRenderContext struct {
+	Model      RenderModel
+	Webhooks   []RenderWebhook
+	Deliveries []RenderDelivery
+	Error      string
+}
@@ -0,0 +1,42 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var errInvalidIfMatch = errors.New("If-Match must contain a single entity tag")
+
+// etag formats the version of an entity as a strong entity tag.
+func etag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// expectedVersion returns the version from the If-Match header, 0 without a header or for *.
+func expectedVersion(r *http.Request) (int, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	tag, err := strconv.Unquote(strings.TrimPrefix(header, "W/"))
+	if err != nil {
+		return 0, errInvalidIfMatch
+	}
+	version, err := strconv.Atoi(tag)
+	if err != nil || version <= 0 {
+		return 0, errInvalidIfMatch
+	}
+	return version, nil
+}
+
+// versionFromRequest prefers the version from the If-Match header over the version sent in the request body.
+func versionFromRequest(r *http.Request, bodyVersion int) (int, error) {
+	version, err := expectedVersion(r)
+	if err != nil || version != 0 {
+		return version, err
+	}
+	return bodyVersion, nil
+}
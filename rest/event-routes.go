@@ -0,0 +1,138 @@
+package rest
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gossie/modelling-service/domain"
+	"github.com/gossie/modelling-service/events"
+	"github.com/gossie/modelling-service/middleware"
+	"github.com/gossie/modelling-service/views"
+)
+
+// keepAliveInterval keeps proxies from closing idle event streams and is how often a stream checks its viewer.
+var keepAliveInterval = 30 * time.Second
+
+// GetEvents streams the changes of a model as server-sent events. The event name matches the hx-sse swap or sse trigger
+// attribute in model.html. Most events carry the re-rendered partial that the client swaps in. Parameter events only
+// tell the client to reload the list, because only the client knows its filters, the loaded pages and the open editors.
+func (s *Server) GetEvents(constraintList, presence *views.View) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		modelId, _ := strconv.Atoi(r.PathValue("modelId"))
+		email, _ := r.Context().Value(middleware.UserIdentifierKey).(string)
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			slog.WarnContext(r.Context(), "streaming is not supported by the response writer")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		// the stream lives longer than the write timeout of the server allows
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+		slog.InfoContext(r.Context(), fmt.Sprintf("subscribing to events of model with ID %v", modelId))
+		subscription := s.broker.Subscribe(modelId, email)
+		defer s.broker.Unsubscribe(subscription)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(keepAliveInterval)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				slog.InfoContext(r.Context(), fmt.Sprintf("viewer left model with ID %v", modelId))
+				return
+			case <-s.health.draining:
+				// the client reconnects to another instance
+				return
+			case <-keepAlive.C:
+				if err := s.checkViewer(r, modelId, email); err != nil {
+					// the client reconnects and logs in again if necessary
+					slog.InfoContext(r.Context(), fmt.Sprintf("closing the events of model with ID %v: %v", modelId, err.Error()))
+					return
+				}
+				fmt.Fprint(w, ": keep-alive\n\n")
+			case event, open := <-subscription.Events():
+				if !open {
+					return
+				}
+
+				data, err := s.renderEvent(r, event, constraintList, presence)
+				if err != nil {
+					slog.WarnContext(r.Context(), fmt.Sprintf("could not render %v event of model with ID %v: %v", event.Kind, modelId, err.Error()))
+					continue
+				}
+				if data == nil {
+					// the page has no part that shows this kind of event
+					continue
+				}
+				writeEvent(w, string(event.Kind), data)
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// checkViewer makes sure that the viewer of a stream is still logged in and still has access to the model.
+func (s *Server) checkViewer(r *http.Request, modelId int, email string) error {
+	user, err := s.apiTokens.Revalidate(r)
+	if err != nil {
+		return err
+	}
+	if user != email {
+		return fmt.Errorf("%w: the credentials belong to %v", domain.ErrUnauthenticated, user)
+	}
+	hasAccess, err := s.modelRepository.HasAccess(r.Context(), modelId, email)
+	if err != nil {
+		return err
+	}
+	if !hasAccess {
+		return fmt.Errorf("%w: no access to model with ID %v anymore", domain.ErrForbidden, modelId)
+	}
+	return nil
+}
+
+func (s *Server) renderEvent(r *http.Request, event events.Event, constraintList, presence *views.View) ([]byte, error) {
+	var (
+		data bytes.Buffer
+		err  error
+	)
+
+	switch event.Kind {
+	case events.ParametersChanged:
+		data.WriteString("reload")
+	case events.ConstraintsChanged:
+		var model domain.Model
+		model, err = s.modelRepository.FindById(r.Context(), event.ModelId)
+		if err == nil {
+			err = constraintList.Execute(&data, toRenderConstraints(model.Constraints))
+		}
+	case events.PresenceChanged:
+		err = presence.Execute(&data, s.broker.Viewers(event.ModelId))
+	default:
+		return nil, nil
+	}
+
+	return data.Bytes(), err
+}
+
+// writeEvent sends a server-sent event with a data field for every line of the data.
+func writeEvent(w http.ResponseWriter, name string, data []byte) {
+	fmt.Fprintf(w, "event: %v\n", name)
+	for _, line := range strings.Split(string(data), "\n") {
+		fmt.Fprintf(w, "data: %v\n", line)
+	}
+	fmt.Fprint(w, "\n")
+}
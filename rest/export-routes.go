@@ -0,0 +1,87 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gossie/modelling-service/domain"
+)
+
+func (s *Server) GetExport(w http.ResponseWriter, r *http.Request) {
+	modelId, _ := strconv.Atoi(r.PathValue("modelId"))
+	slog.InfoContext(r.Context(), fmt.Sprintf("exporting model with ID %v", modelId))
+
+	model, err := retrieveData(nil, func() (domain.Model, error) {
+		return s.modelRepository.FindById(r.Context(), modelId)
+	})
+
+	groups, err := retrieveData(err, func() ([]domain.ParameterGroup, error) {
+		return s.parameterGroupRepository.FindAllByModelId(r.Context(), modelId)
+	})
+
+	parameters, err := retrieveData(err, func() ([]domain.Parameter, error) {
+		return s.parameterRepository.FindAllByModelId(r.Context(), modelId, "")
+	})
+
+	if err != nil {
+		slog.WarnContext(r.Context(), fmt.Sprintf("could not export model with id %v: %v", modelId, err.Error()))
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(toExport(model, groups, parameters))
+	if err != nil {
+		slog.WarnContext(r.Context(), fmt.Sprintf("could not encode json: %v", err.Error()))
+		http.Error(w, err.Error(), 500)
+		return
+	}
+}
+
+func toExport(model domain.Model, groups []domain.ParameterGroup, parameters []domain.Parameter) domain.ConfigurationModelExport {
+	exportedGroups := make([]domain.ExportedParameterGroup, len(groups))
+	groupIndices := make(map[int]int, len(groups))
+	for i, group := range groups {
+		groupIndices[group.Id] = i
+		exportedGroups[i] = domain.ExportedParameterGroup{
+			Id:           group.Id,
+			Name:         group.Name,
+			Translation:  group.Translation,
+			ParameterIds: make([]int, 0),
+		}
+	}
+
+	exportedParameters := make([]domain.ExportedParameter, len(parameters))
+	for i, parameter := range parameters {
+		values := make([]string, len(parameter.Value.Values))
+		for j, value := range parameter.Value.Values {
+			values[j] = value.Value
+		}
+
+		exportedParameters[i] = domain.ExportedParameter{
+			Id:           parameter.Id,
+			Name:         parameter.Name,
+			ValueType:    parameter.ValueType,
+			Values:       values,
+			Description:  parameter.Description,
+			Unit:         parameter.Unit,
+			Required:     parameter.Required,
+			DefaultValue: parameter.DefaultValue,
+		}
+
+		if index, ok := groupIndices[parameter.GroupId]; ok {
+			exportedGroups[index].ParameterIds = append(exportedGroups[index].ParameterIds, parameter.Id)
+		}
+	}
+
+	return domain.ConfigurationModelExport{
+		Id:          model.Id,
+		Name:        model.Name,
+		Parameters:  exportedParameters,
+		Constraints: model.Constraints,
+		Metadata:    domain.ExportMetadata{Groups: exportedGroups},
+	}
+}
@@ -0,0 +1,261 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gossie/modelling-service/domain"
+	"github.com/gossie/modelling-service/views"
+)
+
+func (s *Server) PostGroup(v *views.View) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		modelId, _ := strconv.Atoi(r.PathValue("modelId"))
+		name := r.FormValue("groupName")
+		slog.InfoContext(r.Context(), fmt.Sprintf("creating new parameter group with name %v for model with ID %v", name, modelId))
+
+		_, err := s.parameterGroupRepository.SaveGroup(r.Context(), modelId, domain.ParameterGroupCreationRequest{Name: name})
+		if err != nil {
+			slog.WarnContext(r.Context(), fmt.Sprintf("error creating new parameter group: %v", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		renderParameters(v, w, r, s.parameterRepository, s.parameterGroupRepository, modelId)
+	}
+}
+
+func (s *Server) DeleteGroup(v *views.View) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		modelId, _ := strconv.Atoi(r.PathValue("modelId"))
+		groupId, _ := strconv.Atoi(r.PathValue("groupId"))
+		slog.InfoContext(r.Context(), fmt.Sprintf("deleting parameter group - modelId: %v, groupId: %v", modelId, groupId))
+
+		err := s.parameterGroupRepository.DeleteGroup(r.Context(), modelId, groupId)
+		if err != nil {
+			slog.WarnContext(r.Context(), fmt.Sprintf("error deleting parameter group - modelId = %v, groupId = %v: %v", modelId, groupId, err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		renderParameters(v, w, r, s.parameterRepository, s.parameterGroupRepository, modelId)
+	}
+}
+
+func (s *Server) GetGroupTranslations(w http.ResponseWriter, r *http.Request) {
+	modelId, groupId := r.PathValue("modelId"), r.PathValue("groupId")
+	slog.InfoContext(r.Context(), fmt.Sprintf("retrieving parameter group translations - modelId: %v, groupId: %v", modelId, groupId))
+
+	model, _ := strconv.Atoi(modelId)
+	group, _ := strconv.Atoi(groupId)
+	translations, err := s.parameterGroupRepository.FindAllTranslations(r.Context(), model, group)
+	if errors.Is(err, domain.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.WarnContext(r.Context(), fmt.Sprintf("could not retrieve translations: %v", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(translations)
+	if err != nil {
+		slog.WarnContext(r.Context(), fmt.Sprintf("could not encode json: %v", err.Error()))
+		http.Error(w, err.Error(), 500)
+		return
+	}
+}
+
+func (s *Server) PatchGroupTranslations(w http.ResponseWriter, r *http.Request) {
+	modelId, groupId := r.PathValue("modelId"), r.PathValue("groupId")
+	slog.InfoContext(r.Context(), fmt.Sprintf("saving parameter group translations - modelId: %v, groupId: %v", modelId, groupId))
+
+	decoder := json.NewDecoder(r.Body)
+	var tmr domain.TranslationModificationRequest
+	err := decoder.Decode(&tmr)
+	if err != nil {
+		slog.WarnContext(r.Context(), fmt.Sprintf("could not decode json: %v", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	model, _ := strconv.Atoi(modelId)
+	group, _ := strconv.Atoi(groupId)
+	err = s.parameterGroupRepository.SaveTranslations(r.Context(), model, group, tmr)
+	if errors.Is(err, domain.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.WarnContext(r.Context(), fmt.Sprintf("could not save translations: %v", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(200)
+}
+
+// PutParameterLayout moves a single parameter or group. The form value "entry" is the moved row, either
+// "p:<parameterId>" or "g:<groupId>". A parameter is moved into the group "group", 0 stands for no group, right after
+// the parameter "after" or to the start of the group if "after" is empty. A group is moved right after the group
+// "after" or to the start. The whole layout of the model is numbered again, because the list only loads some of the
+// parameters.
+func (s *Server) PutParameterLayout(v *views.View) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		modelId, _ := strconv.Atoi(r.PathValue("modelId"))
+		slog.InfoContext(r.Context(), fmt.Sprintf("saving parameter layout of model with ID %v", modelId))
+
+		move, err := parseLayoutMove(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), fmt.Sprintf("invalid parameter layout: %v", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// the layout is computed from the current one, so reading and writing it must not interleave with other changes
+		err = s.unitOfWork.Do(r.Context(), func(repositories domain.Repositories) error {
+			groups, err := repositories.ParameterGroups.FindAllByModelId(r.Context(), modelId)
+			if err != nil {
+				return err
+			}
+			parameters, err := repositories.Parameters.FindAllByModelId(r.Context(), modelId, "*")
+			if err != nil {
+				return err
+			}
+
+			layout, err := moveInLayout(currentLayout(groups, parameters), move)
+			if err != nil {
+				return err
+			}
+			return repositories.ParameterGroups.SaveLayout(r.Context(), modelId, layout)
+		})
+		if errors.Is(err, domain.ErrConflict) {
+			slog.InfoContext(r.Context(), fmt.Sprintf("parameter layout of model with ID %v was modified concurrently", modelId))
+			renderParameterList(v, w, r, s.parameterRepository, s.parameterGroupRepository, modelId, "Die Liste wurde in der Zwischenzeit geändert und nichts verschoben. Die Liste zeigt den aktuellen Stand.")
+			return
+		}
+		if errors.Is(err, domain.ErrNotFound) {
+			slog.InfoContext(r.Context(), fmt.Sprintf("parameter layout refers to a parameter or group of another model than %v", modelId))
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.WarnContext(r.Context(), fmt.Sprintf("could not save parameter layout: %v", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		renderParameters(v, w, r, s.parameterRepository, s.parameterGroupRepository, modelId)
+	}
+}
+
+// layoutMove is a parameter or group that was dropped at another place of the list.
+type layoutMove struct {
+	group bool
+	id    int
+	// target is the group of a moved parameter
+	target int
+	// after is the parameter or group that the moved one follows, 0 moves it to the start
+	after int
+}
+
+func parseLayoutMove(r *http.Request) (layoutMove, error) {
+	kind, id, _ := strings.Cut(r.FormValue("entry"), ":")
+	move := layoutMove{group: kind == "g"}
+	var err error
+	if kind != "g" && kind != "p" {
+		return layoutMove{}, fmt.Errorf("invalid entry %v", r.FormValue("entry"))
+	}
+	if move.id, err = strconv.Atoi(id); err != nil {
+		return layoutMove{}, fmt.Errorf("invalid entry %v: %w", r.FormValue("entry"), err)
+	}
+	if move.target, err = optionalId(r.FormValue("group")); err != nil {
+		return layoutMove{}, fmt.Errorf("invalid group: %w", err)
+	}
+	if move.after, err = optionalId(r.FormValue("after")); err != nil {
+		return layoutMove{}, fmt.Errorf("invalid after: %w", err)
+	}
+	return move, nil
+}
+
+func optionalId(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// currentLayout lists all groups in their order, followed by the parameters without a group.
+func currentLayout(groups []domain.ParameterGroup, parameters []domain.Parameter) []domain.ParameterLayout {
+	layout := make([]domain.ParameterLayout, 0, len(groups)+1)
+	indices := make(map[int]int, len(groups))
+	for _, group := range groups {
+		indices[group.Id] = len(layout)
+		layout = append(layout, domain.ParameterLayout{GroupId: group.Id, ParameterIds: make([]int, 0)})
+	}
+	ungrouped := len(layout)
+	layout = append(layout, domain.ParameterLayout{GroupId: 0, ParameterIds: make([]int, 0)})
+
+	for _, parameter := range parameters {
+		index, ok := indices[parameter.GroupId]
+		if !ok {
+			index = ungrouped
+		}
+		layout[index].ParameterIds = append(layout[index].ParameterIds, parameter.Id)
+	}
+	return layout
+}
+
+// moveInLayout returns ErrNotFound for moves outside the layout and ErrConflict if the layout changed in between.
+func moveInLayout(layout []domain.ParameterLayout, move layoutMove) ([]domain.ParameterLayout, error) {
+	if move.group {
+		// the parameters without a group stay at the end
+		groups, ungrouped := layout[:len(layout)-1], layout[len(layout)-1]
+		from := slices.IndexFunc(groups, func(g domain.ParameterLayout) bool { return g.GroupId == move.id })
+		if from < 0 {
+			return nil, domain.ErrNotFound
+		}
+		moved := groups[from]
+		groups = slices.Delete(slices.Clone(groups), from, from+1)
+		to := 0
+		if move.after != 0 {
+			to = slices.IndexFunc(groups, func(g domain.ParameterLayout) bool { return g.GroupId == move.after }) + 1
+			if to == 0 {
+				return nil, domain.ErrConflict
+			}
+		}
+		return append(slices.Insert(groups, to, moved), ungrouped), nil
+	}
+
+	from, target := -1, -1
+	for i, group := range layout {
+		if slices.Contains(group.ParameterIds, move.id) {
+			from = i
+		}
+		if group.GroupId == move.target {
+			target = i
+		}
+	}
+	if from < 0 || target < 0 {
+		return nil, domain.ErrNotFound
+	}
+
+	layout = slices.Clone(layout)
+	layout[from].ParameterIds = slices.DeleteFunc(slices.Clone(layout[from].ParameterIds), func(id int) bool { return id == move.id })
+	to := 0
+	if move.after != 0 {
+		to = slices.Index(layout[target].ParameterIds, move.after) + 1
+		if to == 0 {
+			return nil, domain.ErrConflict
+		}
+	}
+	layout[target].ParameterIds = slices.Insert(slices.Clone(layout[target].ParameterIds), to, move.id)
+	return layout, nil
+}
@@ -0,0 +1,93 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gossie/modelling-service/domain"
+	"github.com/gossie/modelling-service/middleware"
+)
+
+func TestMovesRenumberTheWholeLayout(t *testing.T) {
+	s, modelId := newAuthorizationTestServer(t)
+	ctx := context.WithValue(context.Background(), middleware.LanguageKey, "en")
+
+	frame, err := s.parameterGroupRepository.SaveGroup(ctx, modelId, domain.ParameterGroupCreationRequest{Name: "frame"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wheels, err := s.parameterGroupRepository.SaveGroup(ctx, modelId, domain.ParameterGroupCreationRequest{Name: "wheels"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// more parameters than the list loads at once
+	parameterIds := make([]int, 0)
+	for i := range domain.DefaultPageSize + 10 {
+		parameterId, err := s.parameterRepository.SaveParameter(ctx, modelId, domain.ParameterCreationRequest{Name: fmt.Sprintf("parameter %v", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		parameterIds = append(parameterIds, parameterId)
+	}
+	first, unloaded, last := parameterIds[0], parameterIds[domain.DefaultPageSize+5], parameterIds[len(parameterIds)-1]
+
+	move := func(values url.Values) int {
+		request := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/models/%v/parameter-layout", modelId), strings.NewReader(values.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		authenticateAs(t, s, request, modelOwner)
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+	moves := []struct {
+		values         url.Values
+		expectedStatus int
+	}{
+		{url.Values{"entry": {fmt.Sprintf("p:%v", unloaded)}, "group": {fmt.Sprint(frame)}}, http.StatusOK},
+		{url.Values{"entry": {fmt.Sprintf("p:%v", first)}, "group": {fmt.Sprint(frame)}, "after": {fmt.Sprint(unloaded)}}, http.StatusOK},
+		{url.Values{"entry": {fmt.Sprintf("g:%v", wheels)}}, http.StatusOK},
+		{url.Values{"entry": {fmt.Sprintf("p:%v", last)}, "group": {fmt.Sprint(wheels)}, "after": {fmt.Sprint(first)}}, http.StatusConflict},
+		{url.Values{"entry": {"p:999"}, "group": {fmt.Sprint(wheels)}}, http.StatusNotFound},
+		{url.Values{"entry": {"x:1"}}, http.StatusBadRequest},
+	}
+	for _, m := range moves {
+		if status := move(m.values); status != m.expectedStatus {
+			t.Errorf("expected %v for the move %v, got %v", m.expectedStatus, m.values, status)
+		}
+	}
+
+	groups, err := s.parameterGroupRepository.FindAllByModelId(ctx, modelId)
+	if err != nil || len(groups) != 2 || groups[0].Id != wheels || groups[1].Id != frame {
+		t.Errorf("expected the wheels before the frame, got %v, %v", groups, err)
+	}
+	parameters, err := s.parameterRepository.FindAllByModelId(ctx, modelId, "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	positions := make(map[int][]int)
+	inFrame := make([]int, 0)
+	for _, parameter := range parameters {
+		positions[parameter.GroupId] = append(positions[parameter.GroupId], parameter.Position)
+		if parameter.GroupId == frame {
+			inFrame = append(inFrame, parameter.Id)
+		}
+	}
+	if !slices.Equal(inFrame, []int{unloaded, first}) {
+		t.Errorf("expected the frame to hold the moved parameters in order, got %v", inFrame)
+	}
+	for groupId, numbers := range positions {
+		slices.Sort(numbers)
+		for i, position := range numbers {
+			if position != i {
+				t.Errorf("expected the positions of group %v to be numbered from 0 without gaps, got %v", groupId, numbers)
+				break
+			}
+		}
+	}
+}
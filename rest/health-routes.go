@@ -0,0 +1,142 @@
+package rest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// healthCheckTimeout bounds a single check, probes should not pile up when a dependency hangs.
+const healthCheckTimeout = 2 * time.Second
+
+// HealthCheck reports whether a dependency of the server, like the database, is usable.
+type HealthCheck func(context.Context) error
+
+type namedHealthCheck struct {
+	name  string
+	check HealthCheck
+}
+
+// health keeps the checks of the server and whether it is shutting down.
+type health struct {
+	mu       sync.Mutex
+	checks   []namedHealthCheck
+	draining chan struct{}
+	drain    sync.Once
+	// databaseStats is nil if the server has no database
+	databaseStats func() sql.DBStats
+}
+
+func newHealth() *health {
+	return &health{draining: make(chan struct{})}
+}
+
+// AddHealthCheck adds a check to /healthz and /readyz.
+func (s *Server) AddHealthCheck(name string, check HealthCheck) {
+	s.health.mu.Lock()
+	defer s.health.mu.Unlock()
+
+	s.health.checks = append(s.health.checks, namedHealthCheck{name, check})
+}
+
+// SetDatabaseStats exposes the statistics of the connection pool on /stats/database to requests with the admin token.
+func (s *Server) SetDatabaseStats(stats func() sql.DBStats) {
+	s.health.mu.Lock()
+	defer s.health.mu.Unlock()
+
+	s.health.databaseStats = stats
+}
+
+// Drain prepares the shutdown: /readyz fails from now on, so that no new requests are routed to the server,
+// and the event streams of the viewers end, because they would keep the shutdown from completing.
+func (s *Server) Drain() {
+	s.health.drain.Do(func() { close(s.health.draining) })
+}
+
+func (s *Server) isDraining() bool {
+	select {
+	case <-s.health.draining:
+		return true
+	default:
+		return false
+	}
+}
+
+// GetHealthz tells whether the server works at all.
+func (s *Server) GetHealthz(w http.ResponseWriter, r *http.Request) {
+	s.writeHealth(w, r, false)
+}
+
+// GetReadyz tells whether the server should receive requests. It fails while the server is shutting down.
+func (s *Server) GetReadyz(w http.ResponseWriter, r *http.Request) {
+	s.writeHealth(w, r, s.isDraining())
+}
+
+func (s *Server) writeHealth(w http.ResponseWriter, r *http.Request, draining bool) {
+	s.health.mu.Lock()
+	checks := s.health.checks
+	s.health.mu.Unlock()
+
+	healthy := !draining
+	results := make(map[string]string, len(checks))
+	for _, c := range checks {
+		ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+		err := c.check(ctx)
+		cancel()
+
+		if err != nil {
+			slog.WarnContext(r.Context(), fmt.Sprintf("health check %v failed: %v", c.name, err.Error()))
+			results[c.name] = err.Error()
+			healthy = false
+			continue
+		}
+		results[c.name] = "ok"
+	}
+
+	status := "ok"
+	if draining {
+		status = "shutting down"
+	} else if !healthy {
+		status = "unavailable"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if !healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"status": status, "checks": results})
+}
+
+// GetDatabaseStats shows how busy the connection pool is. A growing waitCount means that requests wait for connections
+// and maxOpenConnections is too low.
+func (s *Server) GetDatabaseStats(w http.ResponseWriter, r *http.Request) {
+	s.health.mu.Lock()
+	databaseStats := s.health.databaseStats
+	s.health.mu.Unlock()
+
+	if databaseStats == nil {
+		http.Error(w, "the server has no database", http.StatusNotFound)
+		return
+	}
+
+	stats := databaseStats()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"maxOpenConnections": stats.MaxOpenConnections,
+		"openConnections":    stats.OpenConnections,
+		"inUse":              stats.InUse,
+		"idle":               stats.Idle,
+		"waitCount":          stats.WaitCount,
+		"waitDuration":       stats.WaitDuration.String(),
+		"maxIdleClosed":      stats.MaxIdleClosed,
+		"maxIdleTimeClosed":  stats.MaxIdleTimeClosed,
+		"maxLifetimeClosed":  stats.MaxLifetimeClosed,
+	})
+}
@@ -1,6 +1,7 @@
 package rest
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -31,10 +32,21 @@ func (s *Server) PostModel(v *views.View) http.HandlerFunc {
 	}
 }
 
-func (s *Server) GetModels(v *views.View) http.HandlerFunc {
+func (s *Server) GetModels(catalog, list, page *views.View) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		slog.InfoContext(r.Context(), "retrieving models")
 		email := r.Context().Value(middleware.UserIdentifierKey).(string)
+
+		var v *views.View
+		switch {
+		case r.URL.Query().Get("cursor") != "":
+			v = page
+		case r.Header.Get("HX-Request") == "true":
+			v = list
+		default:
+			v = catalog
+		}
+
 		renderModelCatalog(v, w, r, s.modelRepository, email)
 	}
 }
@@ -44,33 +56,49 @@ func (s *Server) GetModel(v *views.View) http.HandlerFunc {
 		modelId, _ := strconv.Atoi(r.PathValue("modelId"))
 		slog.InfoContext(r.Context(), fmt.Sprintf("retrieving model with id %v", modelId))
 
-		renderModel(v, w, r, s.modelRepository, s.parameterRepository, modelId)
+		renderModel(v, w, r, s.modelRepository, s.parameterRepository, s.parameterGroupRepository, modelId, s.broker.Viewers(modelId))
 	}
 }
 
 func renderModelCatalog(v *views.View, w http.ResponseWriter, r *http.Request, repo domain.ModelRepository, email string) {
-	models, err := repo.FindAllByUser(r.Context(), email)
+	filter := modelFilterFromQuery(r)
+	page, err := repo.FindPageByUser(r.Context(), email, filter, pageRequestFromQuery(r))
+	if errors.Is(err, domain.ErrInvalidCursor) {
+		slog.InfoContext(r.Context(), "invalid cursor for models")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		slog.WarnContext(r.Context(), fmt.Sprintf("error retrieving models from database: %v", err.Error()))
 		http.Error(w, err.Error(), 500)
 		return
 	}
 
-	renderModels := make([]RenderModel, len(models))
-	for i := range len(models) {
-		renderModels[i] = RenderModel{Id: models[i].Id, Name: valueOrDefault(models[i].Name, models[i].Translation)}
+	renderModels := make([]RenderModel, len(page.Items))
+	for i, model := range page.Items {
+		renderModels[i] = RenderModel{Id: model.Id, Name: valueOrDefault(model.Translation, model.Name)}
 	}
 
-	v.Render(r.Context(), w, ModelCatalogRenderContext{Models: renderModels})
+	query := r.URL.Query()
+	v.Render(r.Context(), w, ModelCatalogRenderContext{
+		Query: ModelQuery{
+			Sort:           query.Get("sort"),
+			Order:          query.Get("order"),
+			Untranslated:   filter.Untranslated,
+			HasConstraints: filter.HasConstraints,
+		},
+		Models:  renderModels,
+		NextUrl: nextPageUrl(r, "/models", page.NextCursor, nil),
+	})
 }
 
-func renderModel(v *views.View, w http.ResponseWriter, r *http.Request, modelRepo domain.ModelRepository, paramRepo domain.ParameterRepository, modelId int) {
+func renderModel(v *views.View, w http.ResponseWriter, r *http.Request, modelRepo domain.ModelRepository, paramRepo domain.ParameterRepository, groupRepo domain.ParameterGroupRepository, modelId int, viewers []string) {
 	model, err := retrieveData(nil, func() (domain.Model, error) {
 		return modelRepo.FindById(r.Context(), modelId)
 	})
 
-	parameters, err := retrieveData(err, func() ([]domain.Parameter, error) {
-		return paramRepo.FindAllByModelId(r.Context(), modelId, "")
+	parameterList, err := retrieveData(err, func() (ParameterListRenderContext, error) {
+		return retrieveParameterList(r, paramRepo, groupRepo, modelId)
 	})
 
 	if err != nil {
@@ -79,31 +107,20 @@ func renderModel(v *views.View, w http.ResponseWriter, r *http.Request, modelRep
 		return
 	}
 
-	parametersToRender := make([]RenderParameter, len(parameters))
-	for i := range parameters {
-		values := make([]string, len(parameters[i].Value.Values))
-		for j := range parameters[i].Value.Values {
-			values[j] = parameters[i].Value.Values[j].Translation
-		}
-
-		parametersToRender[i] = RenderParameter{
-			Id:      parameters[i].Id,
-			ModelId: modelId,
-			Name:    valueOrDefault(parameters[i].Translation, parameters[i].Name),
-			Values:  values,
-		}
-	}
+	v.Render(r.Context(), w, ModelRenderContext{
+		Model:         RenderModel{Id: model.Id, Name: valueOrDefault(model.Translation, model.Name)},
+		ParameterList: parameterList,
+		Constraints:   toRenderConstraints(model.Constraints),
+		Viewers:       viewers,
+	})
+}
 
-	constraintsToRender := make([]RenderConstraint, len(model.Constraints))
-	for i := range model.Constraints {
+func toRenderConstraints(constraints []domain.Constraint) []RenderConstraint {
+	constraintsToRender := make([]RenderConstraint, len(constraints))
+	for i := range constraints {
 		constraintsToRender[i] = RenderConstraint{}
 	}
-
-	v.Render(r.Context(), w, ModelRenderContext{
-		Model:       RenderModel{Id: model.Id, Name: valueOrDefault(model.Translation, model.Name)},
-		Parameters:  parametersToRender,
-		Constraints: constraintsToRender,
-	})
+	return constraintsToRender
 }
 
 func retrieveData[T any](err error, retriever func() (T, error)) (T, error) {
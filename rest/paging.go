@@ -0,0 +1,78 @@
+package rest
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	configurationmodel "github.com/gossie/configuration-model"
+	"github.com/gossie/modelling-service/domain"
+)
+
+func pageRequestFromQuery(r *http.Request) domain.PageRequest {
+	query := r.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	return domain.PageRequest{
+		Cursor:     query.Get("cursor"),
+		Limit:      limit,
+		SortBy:     domain.SortField(query.Get("sort")),
+		Descending: query.Get("order") == "desc",
+	}
+}
+
+func modelFilterFromQuery(r *http.Request) domain.ModelFilter {
+	query := r.URL.Query()
+	return domain.ModelFilter{
+		Untranslated:   isChecked(query.Get("untranslated")),
+		HasConstraints: isChecked(query.Get("hasConstraints")),
+	}
+}
+
+func parameterFilterFromQuery(r *http.Request) domain.ParameterFilter {
+	query := r.URL.Query()
+	filter := domain.ParameterFilter{
+		SearchValue:    query.Get("search"),
+		HasValues:      optionalBool(query.Get("hasValues")),
+		HasConstraints: optionalBool(query.Get("hasConstraints")),
+		Untranslated:   isChecked(query.Get("untranslated")),
+	}
+
+	if valueType, err := strconv.Atoi(query.Get("valueType")); err == nil {
+		vt := configurationmodel.ValueType(valueType)
+		filter.ValueType = &vt
+	}
+	return filter
+}
+
+// isLayoutQuery reports whether the parameters are requested unfiltered in the order defined by the modeller.
+func isLayoutQuery(filter domain.ParameterFilter, pageRequest domain.PageRequest) bool {
+	sortedByPosition := pageRequest.SortBy == "" || pageRequest.SortBy == domain.SortByPosition
+	unfiltered := filter.SearchValue == "" && filter.ValueType == nil && filter.HasValues == nil && filter.HasConstraints == nil && !filter.Untranslated
+	return sortedByPosition && !pageRequest.Descending && unfiltered
+}
+
+// nextPageUrl returns the URL of the next page with the query of the current request, or "" on the last page.
+func nextPageUrl(r *http.Request, path, nextCursor string, extra map[string]string) string {
+	if nextCursor == "" {
+		return ""
+	}
+
+	query := r.URL.Query()
+	query.Set("cursor", nextCursor)
+	for key, value := range extra {
+		query.Set(key, value)
+	}
+	return (&url.URL{Path: path, RawQuery: query.Encode()}).String()
+}
+
+func optionalBool(value string) *bool {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil
+	}
+	return &b
+}
+
+func isChecked(value string) bool {
+	return value == "on" || value == "true"
+}
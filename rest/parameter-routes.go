@@ -2,28 +2,30 @@ package rest
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 
 	configurationmodel "github.com/gossie/configuration-model"
 	"github.com/gossie/modelling-service/domain"
 	"github.com/gossie/modelling-service/views"
 )
 
-func (s *Server) GetParameters(v *views.View) http.HandlerFunc {
+func (s *Server) GetParameters(list, page *views.View) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		slog.InfoContext(r.Context(), "retrieving parameters")
 
 		modelId, _ := strconv.Atoi(r.PathValue("modelId"))
 
-		searchValue := r.URL.Query().Get("parameterName")
-		if searchValue == "" {
-			searchValue = "*"
+		v := list
+		if r.URL.Query().Get("cursor") != "" {
+			v = page
 		}
 
-		renderParameters(v, w, r, s.parameterRepository, modelId)
+		renderParameters(v, w, r, s.parameterRepository, s.parameterGroupRepository, modelId)
 	}
 }
 
@@ -46,7 +48,7 @@ func (s *Server) PostParameter(view *views.View) http.HandlerFunc {
 			return
 		}
 
-		renderParameters(view, w, r, s.parameterRepository, modelId)
+		renderParameters(view, w, r, s.parameterRepository, s.parameterGroupRepository, modelId)
 	}
 }
 
@@ -57,28 +59,138 @@ func (s *Server) DeleteParameter(view *views.View) http.HandlerFunc {
 
 		slog.InfoContext(r.Context(), fmt.Sprintf("deleting parameter - modelId: %v, parameterId: %v", modelId, parameterId))
 
-		err := s.parameterRepository.DeleteParameter(r.Context(), modelId, parameterId)
+		version, err := expectedVersion(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = s.parameterRepository.DeleteParameter(r.Context(), modelId, parameterId, version)
+		if errors.Is(err, domain.ErrConflict) {
+			slog.InfoContext(r.Context(), fmt.Sprintf("parameter with id %v was modified concurrently", parameterId))
+			renderParameterList(view, w, r, s.parameterRepository, s.parameterGroupRepository, modelId, "Der Parameter wurde in der Zwischenzeit geändert und nicht gelöscht. Die Liste zeigt den aktuellen Stand.")
+			return
+		}
+		if errors.Is(err, domain.ErrNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
 		if err != nil {
 			slog.WarnContext(r.Context(), fmt.Sprintf("error deleting parameter - modelId = %v, parameterId = %v: %v", modelId, parameterId, err.Error()))
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 
-		renderParameters(view, w, r, s.parameterRepository, modelId)
+		renderParameters(view, w, r, s.parameterRepository, s.parameterGroupRepository, modelId)
 	}
 }
 
+func (s *Server) PutParameter(v *views.View) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		modelId, _ := strconv.Atoi(r.PathValue("modelId"))
+		parameterId, _ := strconv.Atoi(r.PathValue("parameterId"))
+		slog.InfoContext(r.Context(), fmt.Sprintf("updating parameter - modelId: %v, parameterId: %v", modelId, parameterId))
+
+		version, err := expectedVersion(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		parameter, err := s.parameterRepository.FindById(r.Context(), modelId, parameterId)
+		if err != nil {
+			slog.WarnContext(r.Context(), fmt.Sprintf("could not find parameter with id %v: %v", parameterId, err.Error()))
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		pmr := domain.ParameterModificationRequest{
+			Description:  r.FormValue("description"),
+			Unit:         r.FormValue("unit"),
+			Required:     r.FormValue("required") == "on",
+			DefaultValue: r.FormValue("defaultValue"),
+			Version:      version,
+		}
+
+		if parameter.ValueType == configurationmodel.StringSetType {
+			pmr.Unit = ""
+		}
+
+		if pmr.DefaultValue != "" && !acceptsDefault(parameter, pmr.DefaultValue) {
+			message := "Der Standardwert muss einer der Werte des Parameters sein"
+			if parameter.ValueType == configurationmodel.IntRangeType {
+				message = "Der Standardwert muss eine ganze Zahl in einem der Bereiche des Parameters sein"
+			}
+			renderValueEditor(v, w, r, s.parameterRepository, modelId, parameterId, message)
+			return
+		}
+
+		err = s.parameterRepository.UpdateParameter(r.Context(), modelId, parameterId, pmr)
+		if errors.Is(err, domain.ErrConflict) {
+			renderValueEditorWithEditConflict(v, w, r, s.parameterRepository, modelId, parameterId)
+			return
+		}
+		if err != nil {
+			slog.WarnContext(r.Context(), fmt.Sprintf("error updating parameter - modelId = %v, parameterId = %v: %v", modelId, parameterId, err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		renderValueEditor(v, w, r, s.parameterRepository, modelId, parameterId, "")
+	}
+}
+
+func acceptsDefault(parameter domain.Parameter, defaultValue string) bool {
+	switch parameter.ValueType {
+	case configurationmodel.IntRangeType:
+		value, err := strconv.Atoi(defaultValue)
+		if err != nil {
+			return false
+		}
+		for _, v := range parameter.Value.Values {
+			min, max, _ := strings.Cut(v.Value, "..")
+			lower, errMin := strconv.Atoi(min)
+			upper, errMax := strconv.Atoi(max)
+			if errMin == nil && errMax == nil && lower <= value && value <= upper {
+				return true
+			}
+		}
+		return false
+	default:
+		return hasValue(parameter, defaultValue)
+	}
+}
+
+func hasValue(parameter domain.Parameter, value string) bool {
+	for _, v := range parameter.Value.Values {
+		if v.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *Server) GetParameterTranslations(w http.ResponseWriter, r *http.Request) {
 	modelId, parameterId := r.PathValue("modelId"), r.PathValue("parameterId")
 	slog.InfoContext(r.Context(), fmt.Sprintf("retrieving parameter translations - modelId: %v, parameterId: %v", modelId, parameterId))
 
-	translations, err := s.parameterRepository.FindAllTranslations(r.Context(), parameterId)
+	model, _ := strconv.Atoi(modelId)
+	parameter, _ := strconv.Atoi(parameterId)
+	found, err := s.parameterRepository.FindById(r.Context(), model, parameter)
+	if err != nil {
+		slog.WarnContext(r.Context(), fmt.Sprintf("could not find parameter with id %v: %v", parameterId, err.Error()))
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	translations, err := s.parameterRepository.FindAllTranslations(r.Context(), model, parameter)
 	if err != nil {
 		slog.WarnContext(r.Context(), fmt.Sprintf("could not retrieve translations: %v", err.Error()))
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Set("ETag", etag(found.Version))
 	err = json.NewEncoder(w).Encode(translations)
 	if err != nil {
 		slog.WarnContext(r.Context(), fmt.Sprintf("could not encode json: %v", err.Error()))
@@ -100,7 +212,24 @@ func (s *Server) PatchParameterTranslations(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	err = s.parameterRepository.SaveTranslations(r.Context(), parameterId, tmr)
+	tmr.Version, err = versionFromRequest(r, tmr.Version)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	model, _ := strconv.Atoi(modelId)
+	parameter, _ := strconv.Atoi(parameterId)
+	err = s.parameterRepository.SaveTranslations(r.Context(), model, parameter, tmr)
+	if errors.Is(err, domain.ErrConflict) {
+		slog.InfoContext(r.Context(), fmt.Sprintf("parameter with id %v was modified concurrently", parameterId))
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, domain.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		slog.WarnContext(r.Context(), fmt.Sprintf("could not save translations: %v", err.Error()))
 		w.WriteHeader(http.StatusInternalServerError)
@@ -123,7 +252,24 @@ func (s *Server) PatchParameterValues(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = s.parameterRepository.SaveValues(r.Context(), parameterId, vmr)
+	vmr.Version, err = versionFromRequest(r, vmr.Version)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	model, _ := strconv.Atoi(modelId)
+	parameter, _ := strconv.Atoi(parameterId)
+	err = s.parameterRepository.SaveValues(r.Context(), model, parameter, vmr)
+	if errors.Is(err, domain.ErrValueInUse) || errors.Is(err, domain.ErrConflict) {
+		slog.InfoContext(r.Context(), fmt.Sprintf("could not save values: %v", err.Error()))
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, domain.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		slog.WarnContext(r.Context(), fmt.Sprintf("could not save translations: %v", err.Error()))
 		w.WriteHeader(http.StatusInternalServerError)
@@ -133,31 +279,130 @@ func (s *Server) PatchParameterValues(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(200)
 }
 
-func renderParameters(v *views.View, w http.ResponseWriter, r *http.Request, paramRepo domain.ParameterRepository, modelId int) {
-	parameters, err := retrieveData(nil, func() ([]domain.Parameter, error) {
-		return paramRepo.FindAllByModelId(r.Context(), modelId, "*")
-	})
+func renderParameters(v *views.View, w http.ResponseWriter, r *http.Request, paramRepo domain.ParameterRepository, groupRepo domain.ParameterGroupRepository, modelId int) {
+	renderParameterList(v, w, r, paramRepo, groupRepo, modelId, "")
+}
 
+// renderParameterList renders the current parameters, with status 409 and the message if conflict is set.
+func renderParameterList(v *views.View, w http.ResponseWriter, r *http.Request, paramRepo domain.ParameterRepository, groupRepo domain.ParameterGroupRepository, modelId int, conflict string) {
+	parameterList, err := retrieveParameterList(r, paramRepo, groupRepo, modelId)
+	if errors.Is(err, domain.ErrInvalidCursor) {
+		slog.InfoContext(r.Context(), fmt.Sprintf("invalid cursor for parameters of model with id %v", modelId))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		slog.WarnContext(r.Context(), fmt.Sprintf("could not find parameters for model with id %v: %v", modelId, err.Error()))
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
 
-	parametersToRender := make([]RenderParameter, len(parameters))
-	for i := range parameters {
-		values := make([]string, len(parameters[i].Value.Values))
-		for j := range parameters[i].Value.Values {
-			values[j] = parameters[i].Value.Values[j].Translation
+	if conflict != "" {
+		parameterList.Conflict = conflict
+		w.WriteHeader(http.StatusConflict)
+	}
+	v.Render(r.Context(), w, parameterList)
+}
+
+func retrieveParameterList(r *http.Request, paramRepo domain.ParameterRepository, groupRepo domain.ParameterGroupRepository, modelId int) (ParameterListRenderContext, error) {
+	filter := parameterFilterFromQuery(r)
+	pageRequest := pageRequestFromQuery(r)
+	layout := isLayoutQuery(filter, pageRequest)
+
+	groups, err := retrieveData(nil, func() ([]domain.ParameterGroup, error) {
+		if !layout {
+			return nil, nil
 		}
+		return groupRepo.FindAllByModelId(r.Context(), modelId)
+	})
+
+	page, err := retrieveData(err, func() (domain.Page[domain.Parameter], error) {
+		return paramRepo.FindPageByModelId(r.Context(), modelId, filter, pageRequest)
+	})
+
+	if err != nil {
+		return ParameterListRenderContext{}, err
+	}
+
+	afterGroup, err := strconv.Atoi(r.URL.Query().Get("afterGroup"))
+	if err != nil {
+		afterGroup = -1
+	}
+
+	rows, lastGroup := toParameterRows(modelId, layout, groups, page, afterGroup)
+	query := r.URL.Query()
+	return ParameterListRenderContext{
+		ModelId: modelId,
+		Layout:  layout,
+		Query: ParameterQuery{
+			Search:         filter.SearchValue,
+			Sort:           query.Get("sort"),
+			Order:          query.Get("order"),
+			ValueType:      query.Get("valueType"),
+			HasValues:      query.Get("hasValues"),
+			HasConstraints: query.Get("hasConstraints"),
+			Untranslated:   filter.Untranslated,
+		},
+		Rows:    rows,
+		NextUrl: nextPageUrl(r, fmt.Sprintf("/models/%v/parameters", modelId), page.NextCursor, map[string]string{"afterGroup": strconv.Itoa(lastGroup)}),
+	}, nil
+}
 
-		parametersToRender[i] = RenderParameter{
-			Id:      parameters[i].Id,
-			ModelId: modelId,
-			Name:    valueOrDefault(parameters[i].Translation, parameters[i].Name),
-			Values:  values,
+// toParameterRows adds a header row for every group in layout mode, afterGroup is the last group of the previous page.
+func toParameterRows(modelId int, layout bool, groups []domain.ParameterGroup, page domain.Page[domain.Parameter], afterGroup int) ([]RenderParameterRow, int) {
+	groupsInOrder := make([]RenderParameterGroup, 0, len(groups)+1)
+	groupIndices := make(map[int]int, len(groups))
+	for _, group := range groups {
+		groupIndices[group.Id] = len(groupsInOrder)
+		groupsInOrder = append(groupsInOrder, RenderParameterGroup{Id: group.Id, Name: valueOrDefault(group.Translation, group.Name)})
+	}
+	ungrouped := len(groupsInOrder)
+	groupsInOrder = append(groupsInOrder, RenderParameterGroup{Name: "Ohne Gruppe"})
+
+	rows := make([]RenderParameterRow, 0, len(page.Items)+len(groupsInOrder))
+	lastGroup := afterGroup
+	addHeadersUntil := func(index int) {
+		for lastGroup < index {
+			lastGroup++
+			rows = append(rows, RenderParameterRow{Group: &groupsInOrder[lastGroup]})
+		}
+	}
+
+	for _, parameter := range page.Items {
+		if layout {
+			index, ok := groupIndices[parameter.GroupId]
+			if !ok {
+				index = ungrouped
+			}
+			addHeadersUntil(index)
 		}
+
+		renderParameter := toRenderParameter(modelId, parameter)
+		rows = append(rows, RenderParameterRow{Parameter: &renderParameter})
+	}
+
+	if layout && page.NextCursor == "" {
+		addHeadersUntil(ungrouped)
+	}
+
+	return rows, lastGroup
+}
+
+func toRenderParameter(modelId int, parameter domain.Parameter) RenderParameter {
+	values := make([]string, len(parameter.Value.Values))
+	for j := range parameter.Value.Values {
+		values[j] = parameter.Value.Values[j].Translation
 	}
 
-	v.Render(r.Context(), w, parametersToRender)
+	return RenderParameter{
+		Id:        parameter.Id,
+		ModelId:   modelId,
+		GroupId:   parameter.GroupId,
+		Name:      valueOrDefault(parameter.Translation, parameter.Name),
+		ValueType: parameter.ValueType,
+		Unit:      parameter.Unit,
+		Required:  parameter.Required,
+		Values:    values,
+		Version:   parameter.Version,
+	}
 }
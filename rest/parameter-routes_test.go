@@ -0,0 +1,49 @@
+package rest
+
+import (
+	"testing"
+
+	configurationmodel "github.com/gossie/configuration-model"
+	"github.com/gossie/modelling-service/domain"
+)
+
+func TestDefaultValuesAreCheckedByValueType(t *testing.T) {
+	parameter := func(valueType configurationmodel.ValueType, values ...string) domain.Parameter {
+		p := domain.Parameter{ValueType: valueType}
+		for _, value := range values {
+			p.Value.Values = append(p.Value.Values, domain.Value{Value: value})
+		}
+		return p
+	}
+	ranges := parameter(configurationmodel.IntRangeType, "1..10", "20..30")
+	numbers := parameter(configurationmodel.IntSetType, "5", "7")
+	final := parameter(configurationmodel.FinalInt, "42")
+	texts := parameter(configurationmodel.StringSetType, "red", "blue")
+
+	tests := []struct {
+		name         string
+		parameter    domain.Parameter
+		defaultValue string
+		accepted     bool
+	}{
+		{"inside a range", ranges, "5", true},
+		{"lower bound", ranges, "1", true},
+		{"second range", ranges, "30", true},
+		{"between the ranges", ranges, "15", false},
+		{"range literal", ranges, "1..10", false},
+		{"no number in a range", ranges, "five", false},
+		{"member of the numbers", numbers, "7", true},
+		{"not a member of the numbers", numbers, "6", false},
+		{"the final number", final, "42", true},
+		{"another number", final, "41", false},
+		{"member of the texts", texts, "red", true},
+		{"not a member of the texts", texts, "green", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if accepted := acceptsDefault(test.parameter, test.defaultValue); accepted != test.accepted {
+				t.Errorf("expected %q to be accepted: %v, got %v", test.defaultValue, test.accepted, accepted)
+			}
+		})
+	}
+}
@@ -1,70 +1,180 @@
 package rest
 
 import (
-	"database/sql"
 	"net/http"
+	"time"
 
+	"github.com/gossie/modelling-service/auth"
+	"github.com/gossie/modelling-service/config"
 	"github.com/gossie/modelling-service/domain"
+	"github.com/gossie/modelling-service/events"
+	"github.com/gossie/modelling-service/metrics"
 	"github.com/gossie/modelling-service/middleware"
-	"github.com/gossie/modelling-service/persistence"
 	"github.com/gossie/modelling-service/views"
+	"github.com/gossie/modelling-service/webhooks"
 )
 
 type Server struct {
-	db                   *sql.DB
-	modelRepository      domain.ModelRepository
-	constraintRepository domain.ConstraintRepository
-	parameterRepository  domain.ParameterRepository
-	jwtSecrect           string
+	userRepository           domain.UserRepository
+	modelRepository          domain.ModelRepository
+	constraintRepository     domain.ConstraintRepository
+	parameterRepository      domain.ParameterRepository
+	parameterGroupRepository domain.ParameterGroupRepository
+	webhookRepository        domain.WebhookRepository
+	outboxRepository         domain.OutboxRepository
+	apiTokenRepository       domain.ApiTokenRepository
+	unitOfWork               domain.UnitOfWork
+	sessions                 *auth.Sessions
+	apiTokens                *auth.ApiTokens
+	// oidc is nil unless users can log in through an identity provider
+	oidc           *auth.Provider
+	webhookTargets webhooks.Targets
+	broker         *events.Broker
+	health         *health
+	config         config.Config
+	metrics        *metrics.Metrics
+	mux            *http.ServeMux
+	handler        http.HandlerFunc
 }
 
-func NewServer(db *sql.DB, jwtSecrect string) *Server {
-	modelRepo := persistence.NewPsqlModelRepository(db)
-	paramRepo := persistence.NewPsqlParameterRepository(db)
-	constRepo := persistence.NewPsqlConstraintRepository(db)
-
+// NewServer creates the server. The repositories should record their changes in the outbox, see events.Recording,
+// and the broker should receive the published events, because the event streams of the viewers subscribe to it.
+// The server records the metrics of its routes and serves all metrics on /metrics to requests with the admin token.
+func NewServer(repositories domain.Repositories, broker *events.Broker, cfg config.Config, m *metrics.Metrics) *Server {
+	sessions := auth.NewSessions(cfg.Jwt, repositories.Sessions)
 	s := Server{
-		db,
-		&modelRepo,
-		&constRepo,
-		&paramRepo,
-		jwtSecrect,
+		repositories.Users,
+		middleware.NewPermissionCache(repositories.Models, cfg.Server.PermissionCacheTtl),
+		repositories.Constraints,
+		repositories.Parameters,
+		repositories.ParameterGroups,
+		repositories.Webhooks,
+		repositories.Outbox,
+		repositories.ApiTokens,
+		repositories.UnitOfWork,
+		sessions,
+		auth.NewApiTokens(repositories.ApiTokens, sessions),
+		nil,
+		webhooks.NewTargets(cfg.Webhooks.AllowedHosts),
+		broker,
+		newHealth(),
+		cfg,
+		m,
+		nil,
+		nil,
+	}
+	if cfg.Oidc.Enabled() {
+		s.oidc = auth.NewProvider(cfg.Oidc, &http.Client{Timeout: 10 * time.Second}, repositories.Users, s.modelRepository, sessions)
 	}
-	s.routes()
+	s.mux = s.routes()
+	s.handler = middleware.Cors(cfg.Cors.AllowedOrigins)(s.mux.ServeHTTP)
 	return &s
 }
 
-func (s *Server) routes() {
-	http.HandleFunc("GET /", middleware.Any(s.GetIndex(views.NewView("index.html"))))
-	http.HandleFunc("POST /login", middleware.Any(s.Login(s.jwtSecrect, views.NewView("model-catalog.html"))))
-	http.HandleFunc("POST /models", middleware.Any(middleware.AuthenticatedRequest(s.jwtSecrect, s.PostModel(views.NewView("model-list")))))
-	http.HandleFunc("GET /models", middleware.Any(middleware.AuthenticatedRequest(s.jwtSecrect, s.GetModels(views.NewView("model-catalog.html")))))
-	http.HandleFunc("GET /models/{modelId}", middleware.Any(middleware.AuthenticatedRequest(s.jwtSecrect, middleware.Authorized(s.db, s.GetModel(views.NewView("model.html"))))))
-	http.HandleFunc("POST /models/{modelId}/constraints", middleware.Any(middleware.AuthenticatedRequest(s.jwtSecrect, middleware.Authorized(s.db, s.PostConstraint))))
-	http.HandleFunc("DELETE /models/{modelId}/constraints/{constraintId}", middleware.Any(middleware.AuthenticatedRequest(s.jwtSecrect, middleware.Authorized(s.db, s.DeleteConstraint))))
-	http.HandleFunc("POST /models/{modelId}/parameters", middleware.Any(middleware.AuthenticatedRequest(s.jwtSecrect, middleware.Authorized(s.db, s.PostParameter(views.NewView("parameter-list"))))))
-	http.HandleFunc("GET /models/{modelId}/parameters", middleware.Any(middleware.AuthenticatedRequest(s.jwtSecrect, middleware.Authorized(s.db, s.GetParameters(views.NewView("parameter-list"))))))
-	http.HandleFunc("DELETE /models/{modelId}/parameters/{parameterId}", middleware.Any(middleware.AuthenticatedRequest(s.jwtSecrect, middleware.Authorized(s.db, s.DeleteParameter(views.NewView("parameter-list"))))))
-	http.HandleFunc("GET /models/{modelId}/parameters/{parameterId}/translations", middleware.Any(middleware.AuthenticatedRequest(s.jwtSecrect, middleware.Authorized(s.db, s.GetParameterTranslations))))
-	http.HandleFunc("PATCH /models/{modelId}/parameters/{parameterId}/translations", middleware.Any(middleware.AuthenticatedRequest(s.jwtSecrect, middleware.Authorized(s.db, s.PatchParameterTranslations))))
-	http.HandleFunc("PATCH /models/{modelId}/parameters/{parameterId}/values", middleware.Any(middleware.AuthenticatedRequest(s.jwtSecrect, middleware.Authorized(s.db, s.PatchParameterValues))))
-
-	// http.HandleFunc("GET /configuration-models/{modelId}", func(w http.ResponseWriter, r *http.Request) {
-	// 	confModel := configurationmodel.Model{}
-
-	// 	_, _ = s.modelRepository.FindById(r.Context(), r.PathValue("modelId"))
-	// 	parameters, _ := s.parameterRepository.FindAllByModelId(r.Context(), r.PathValue("modelId"))
-
-	// 	for _, p := range parameters {
-	// 		var value configurationmodel.ValueModel
-	// 		confModel.AddParameter(p.Name, value)
-	// 	}
-
-	// 	// encoder := json.NewEncoder(w)
-	// 	// err = encoder.Encode(confModel)
-	// })
+// route is an entry of the route table, its middleware runs in the given order before the handler.
+type route struct {
+	pattern    string
+	middleware []middleware.Middleware
+	handler    http.HandlerFunc
+}
+
+func (s *Server) authenticated(next http.HandlerFunc) http.HandlerFunc {
+	return middleware.AuthenticatedRequest(s.apiTokens, next)
+}
+
+// sessionAuthenticated does not accept API tokens, scripts must not manage tokens.
+func (s *Server) sessionAuthenticated(next http.HandlerFunc) http.HandlerFunc {
+	return middleware.AuthenticatedRequest(s.sessions, next)
+}
+
+func (s *Server) admin(next http.HandlerFunc) http.HandlerFunc {
+	return middleware.AdminToken(s.config.Server.AdminToken)(next)
+}
+
+func (s *Server) accessLog(next http.HandlerFunc) http.HandlerFunc {
+	return middleware.AccessLog(s.config.Logging.AccessLog)(next)
+}
+
+func (s *Server) withLanguage(next http.HandlerFunc) http.HandlerFunc {
+	return middleware.WithLanguage(s.config.Languages.Default, s.config.Languages.Supported)(next)
+}
+
+func (s *Server) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return middleware.Authorized(s.modelRepository, next)
+}
+
+func (s *Server) routeTable() []route {
+	// probes are neither logged nor authenticated
+	probe := []middleware.Middleware{}
+	// admin routes are scraped by monitoring, they are not logged either
+	admin := []middleware.Middleware{s.admin}
+	public := []middleware.Middleware{s.accessLog, s.withLanguage}
+	authenticated := []middleware.Middleware{s.accessLog, s.withLanguage, s.authenticated}
+	settings := []middleware.Middleware{s.accessLog, s.withLanguage, s.sessionAuthenticated}
+	// modelMember routes need the path value modelId, only users of the model may access them
+	modelMember := []middleware.Middleware{s.accessLog, s.withLanguage, s.authenticated, s.authorized}
+
+	routes := []route{
+		{"GET /healthz", probe, s.GetHealthz},
+		{"GET /readyz", probe, s.GetReadyz},
+		{"GET /stats/database", admin, s.GetDatabaseStats},
+		{"GET /metrics", admin, s.metrics.Handler().ServeHTTP},
+		{"GET /", public, s.GetIndex(views.NewView("index.html"))},
+		{"POST /login", public, s.Login(views.NewView("model-catalog.html"))},
+		{"POST /logout", public, s.Logout},
+		{"GET /settings/tokens", settings, s.GetApiTokens(views.NewView("settings.html"))},
+		{"POST /settings/tokens", settings, s.PostApiToken(views.NewView("api-token-list"))},
+		{"DELETE /settings/tokens/{tokenId}", settings, s.DeleteApiToken(views.NewView("api-token-list"))},
+		{"POST /models", authenticated, s.PostModel(views.NewView("model-list"))},
+		{"GET /models", authenticated, s.GetModels(views.NewView("model-catalog.html"), views.NewView("model-list"), views.NewView("model-page"))},
+		{"GET /models/{modelId}", modelMember, s.GetModel(views.NewView("model.html"))},
+		{"GET /models/{modelId}/events", modelMember, s.GetEvents(views.NewView("constraint-list"), views.NewView("presence"))},
+		{"POST /models/{modelId}/constraints", modelMember, s.PostConstraint},
+		{"DELETE /models/{modelId}/constraints/{constraintId}", modelMember, s.DeleteConstraint},
+		{"POST /models/{modelId}/parameters", modelMember, s.PostParameter(views.NewView("parameter-list"))},
+		{"GET /models/{modelId}/parameters", modelMember, s.GetParameters(views.NewView("parameter-list"), views.NewView("parameter-page"))},
+		{"PUT /models/{modelId}/parameters/{parameterId}", modelMember, s.PutParameter(views.NewView("value-editor"))},
+		{"DELETE /models/{modelId}/parameters/{parameterId}", modelMember, s.DeleteParameter(views.NewView("parameter-list"))},
+		{"GET /models/{modelId}/parameters/{parameterId}/translations", modelMember, s.GetParameterTranslations},
+		{"PATCH /models/{modelId}/parameters/{parameterId}/translations", modelMember, s.PatchParameterTranslations},
+		{"PATCH /models/{modelId}/parameters/{parameterId}/values", modelMember, s.PatchParameterValues},
+		{"GET /models/{modelId}/parameters/{parameterId}/values", modelMember, s.GetValues(views.NewView("value-editor"))},
+		{"POST /models/{modelId}/parameters/{parameterId}/values", modelMember, s.PostValue(views.NewView("value-editor"))},
+		{"PUT /models/{modelId}/parameters/{parameterId}/values/{valueId}", modelMember, s.PutValue(views.NewView("value-editor"))},
+		{"DELETE /models/{modelId}/parameters/{parameterId}/values/{valueId}", modelMember, s.DeleteValue(views.NewView("value-editor"))},
+		{"POST /models/{modelId}/parameters/{parameterId}/values/{valueId}/position", modelMember, s.MoveValue(views.NewView("value-editor"))},
+		{"POST /models/{modelId}/groups", modelMember, s.PostGroup(views.NewView("parameter-list"))},
+		{"DELETE /models/{modelId}/groups/{groupId}", modelMember, s.DeleteGroup(views.NewView("parameter-list"))},
+		{"GET /models/{modelId}/groups/{groupId}/translations", modelMember, s.GetGroupTranslations},
+		{"PATCH /models/{modelId}/groups/{groupId}/translations", modelMember, s.PatchGroupTranslations},
+		{"PUT /models/{modelId}/parameter-layout", modelMember, s.PutParameterLayout(views.NewView("parameter-list"))},
+		{"POST /models/{modelId}/publish", modelMember, s.PublishModel},
+		{"GET /models/{modelId}/webhooks", modelMember, s.GetWebhooks(views.NewView("webhooks.html"))},
+		{"POST /models/{modelId}/webhooks", modelMember, s.PostWebhook(views.NewView("webhook-list"))},
+		{"DELETE /models/{modelId}/webhooks/{webhookId}", modelMember, s.DeleteWebhook(views.NewView("webhook-list"))},
+		{"GET /models/{modelId}/webhooks/deliveries", modelMember, s.GetWebhooks(views.NewView("delivery-log"))},
+		{"POST /models/{modelId}/webhooks/deliveries/{deliveryId}/retry", modelMember, s.RetryDelivery(views.NewView("delivery-log"))},
+		{"GET /models/{modelId}/export", modelMember, s.GetExport},
+	}
+	if s.oidc != nil {
+		routes = append(routes,
+			route{"GET /oidc/login", public, s.StartOidcLogin},
+			route{"GET /oidc/callback", public, s.FinishOidcLogin},
+		)
+	}
+	return routes
+}
+
+func (s *Server) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	for _, r := range s.routeTable() {
+		instrumented := append([]middleware.Middleware{middleware.Trace(r.pattern), s.metrics.Instrument(r.pattern)}, r.middleware...)
+		mux.HandleFunc(r.pattern, middleware.Chain(r.handler, instrumented...))
+	}
+
+	return mux
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	http.DefaultServeMux.ServeHTTP(w, r)
+	s.handler(w, r)
 }
@@ -0,0 +1,255 @@
+package rest_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gossie/modelling-service/config"
+	"github.com/gossie/modelling-service/events"
+	"github.com/gossie/modelling-service/metrics"
+	"github.com/gossie/modelling-service/persistence"
+	"github.com/gossie/modelling-service/rest"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const owner = "owner@example.com"
+
+func testConfig() config.Config {
+	cfg := config.Default()
+	cfg.Jwt.Secret = "test-secret"
+	return cfg
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	repositories := events.Recording(persistence.NewMemoryRepositories(owner), func() {})
+	server := httptest.NewServer(rest.NewServer(repositories, events.NewBroker(), testConfig(), metrics.New()))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestServersCoexist(t *testing.T) {
+	for _, name := range []string{"first", "second", "third"} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			server := newTestServer(t)
+			client := server.Client()
+
+			response, err := client.Get(server.URL + "/healthz")
+			if err != nil {
+				t.Fatal(err)
+			}
+			response.Body.Close()
+			if response.StatusCode != http.StatusOK {
+				t.Errorf("expected /healthz to answer 200, got %v", response.StatusCode)
+			}
+
+			response, err = client.Get(server.URL + "/models")
+			if err != nil {
+				t.Fatal(err)
+			}
+			response.Body.Close()
+			if response.StatusCode != http.StatusUnauthorized {
+				t.Errorf("expected /models to require a login, got %v", response.StatusCode)
+			}
+
+			response, err = client.PostForm(server.URL+"/login", url.Values{"email": {owner}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			response.Body.Close()
+			if response.StatusCode != http.StatusOK || len(response.Cookies()) == 0 {
+				t.Fatalf("expected the login to set a cookie, got %v", response.StatusCode)
+			}
+
+			request, _ := http.NewRequest(http.MethodGet, server.URL+"/models", nil)
+			request.AddCookie(response.Cookies()[0])
+			response, err = client.Do(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			response.Body.Close()
+			if response.StatusCode != http.StatusOK {
+				t.Errorf("expected the logged in user to see the models, got %v", response.StatusCode)
+			}
+		})
+	}
+}
+
+func TestReadyzFailsWhileDraining(t *testing.T) {
+	repositories := events.Recording(persistence.NewMemoryRepositories(owner), func() {})
+	svr := rest.NewServer(repositories, events.NewBroker(), testConfig(), metrics.New())
+
+	recorder := httptest.NewRecorder()
+	svr.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected a ready server, got %v", recorder.Code)
+	}
+
+	svr.Drain()
+	recorder = httptest.NewRecorder()
+	svr.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected a draining server not to be ready, got %v", recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	svr.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("expected a draining server to be healthy, got %v", recorder.Code)
+	}
+}
+
+func TestCorsAllowsTheConfiguredOrigins(t *testing.T) {
+	cfg := testConfig()
+	cfg.Cors.AllowedOrigins = []string{"https://ui.example.com"}
+	repositories := events.Recording(persistence.NewMemoryRepositories(owner), func() {})
+	svr := rest.NewServer(repositories, events.NewBroker(), cfg, metrics.New())
+
+	preflight := httptest.NewRequest(http.MethodOptions, "/models", nil)
+	preflight.Header.Set("Origin", "https://ui.example.com")
+	preflight.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	recorder := httptest.NewRecorder()
+	svr.ServeHTTP(recorder, preflight)
+	if recorder.Code != http.StatusNoContent || recorder.Header().Get("Access-Control-Allow-Origin") != "https://ui.example.com" {
+		t.Errorf("expected the preflight of an allowed origin to succeed, got %v %v", recorder.Code, recorder.Header())
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	request.Header.Set("Origin", "https://evil.example.com")
+	recorder = httptest.NewRecorder()
+	svr.ServeHTTP(recorder, request)
+	if recorder.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("expected no CORS headers for an unknown origin, got %v", recorder.Header())
+	}
+}
+
+func TestIncomingTraceIsContinued(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	})
+
+	db, err := persistence.OpenSqlite(context.Background(), t.TempDir()+"/modelling.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	migrator, err := persistence.NewSqliteMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = migrator.Up(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec("INSERT INTO users (email) VALUES ($1)", owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repositories := events.Recording(persistence.NewSqliteRepositories(db), func() {})
+	svr := rest.NewServer(repositories, events.NewBroker(), testConfig(), metrics.New())
+
+	request := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(url.Values{"email": {owner}}.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	svr.ServeHTTP(httptest.NewRecorder(), request)
+
+	spans := recorder.Ended()
+	var server sdktrace.ReadOnlySpan
+	names := make([]string, 0)
+	for _, span := range spans {
+		names = append(names, span.Name())
+		if span.Name() == "POST /login" {
+			server = span
+		}
+	}
+	if server == nil {
+		t.Fatalf("expected a span of the route, got %v", names)
+	}
+	if server.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || server.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("expected the span to continue the incoming trace, got %v with parent %v", server.SpanContext(), server.Parent())
+	}
+
+	children := make(map[string]bool)
+	for _, span := range spans {
+		if span.Parent().SpanID() == server.SpanContext().SpanID() {
+			children[span.Name()] = true
+		}
+	}
+	if !children["SELECT"] || !children["render model-catalog.html"] {
+		t.Errorf("expected spans of the statements and the rendering below the request, got %v", names)
+	}
+}
+
+func TestOneAccessLogLinePerRequest(t *testing.T) {
+	var logged bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logged, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	cfg := testConfig()
+	cfg.Logging.AccessLog.Headers = []string{"Cookie", "Referer"}
+	repositories := events.Recording(persistence.NewMemoryRepositories(owner), func() {})
+	svr := rest.NewServer(repositories, events.NewBroker(), cfg, metrics.New())
+
+	login := httptest.NewRecorder()
+	svr.ServeHTTP(login, httptest.NewRequest(http.MethodPost, "/login?email="+url.QueryEscape(owner), nil))
+	request := httptest.NewRequest(http.MethodGet, "/models", nil)
+	request.AddCookie(login.Result().Cookies()[0])
+	request.Header.Set("Referer", "https://example.com/catalog")
+	svr.ServeHTTP(httptest.NewRecorder(), request)
+	svr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/models", nil))
+	svr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	type line struct {
+		Level   string
+		Msg     string
+		Path    string
+		Status  int
+		Bytes   int
+		User    string
+		Headers map[string]string
+	}
+	lines := make([]line, 0)
+	decoder := json.NewDecoder(&logged)
+	for decoder.More() {
+		var l line
+		err := decoder.Decode(&l)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l.Msg == "request" {
+			lines = append(lines, l)
+		}
+	}
+
+	if len(lines) != 3 {
+		t.Fatalf("expected a line per request except the probe, got %+v", lines)
+	}
+	models := lines[1]
+	if models.Level != "INFO" || models.Path != "/models" || models.Status != http.StatusOK || models.Bytes == 0 || models.User != owner {
+		t.Errorf("unexpected line of the logged in request %+v", models)
+	}
+	if models.Headers["Cookie"] != "REDACTED" || models.Headers["Referer"] != "https://example.com/catalog" {
+		t.Errorf("expected the cookie to be redacted and the referer to be logged, got %v", models.Headers)
+	}
+	if anonymous := lines[2]; anonymous.Level != "WARN" || anonymous.Status != http.StatusUnauthorized || anonymous.User != "" {
+		t.Errorf("expected the unauthorized request to be logged as warning, got %+v", anonymous)
+	}
+}
@@ -0,0 +1,352 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	configurationmodel "github.com/gossie/configuration-model"
+	"github.com/gossie/modelling-service/domain"
+	"github.com/gossie/modelling-service/views"
+)
+
+func (s *Server) GetValues(v *views.View) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		modelId, _ := strconv.Atoi(r.PathValue("modelId"))
+		parameterId, _ := strconv.Atoi(r.PathValue("parameterId"))
+		slog.InfoContext(r.Context(), fmt.Sprintf("retrieving values - modelId: %v, parameterId: %v", modelId, parameterId))
+
+		renderValueEditor(v, w, r, s.parameterRepository, modelId, parameterId, "")
+	}
+}
+
+func (s *Server) PostValue(v *views.View) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		modelId, _ := strconv.Atoi(r.PathValue("modelId"))
+		parameterId, _ := strconv.Atoi(r.PathValue("parameterId"))
+		slog.InfoContext(r.Context(), fmt.Sprintf("adding value - modelId: %v, parameterId: %v", modelId, parameterId))
+
+		version, err := expectedVersion(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		parameter, err := s.parameterRepository.FindById(r.Context(), modelId, parameterId)
+		if err != nil {
+			slog.WarnContext(r.Context(), fmt.Sprintf("could not find parameter with id %v: %v", parameterId, err.Error()))
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if parameter.ValueType == configurationmodel.FinalInt && len(parameter.Value.Values) > 0 {
+			renderValueEditor(v, w, r, s.parameterRepository, modelId, parameterId, "Der Parameter hat bereits einen festen Wert")
+			return
+		}
+
+		value, err := valueFromForm(r, parameter.ValueType)
+		if err != nil {
+			renderValueEditor(v, w, r, s.parameterRepository, modelId, parameterId, err.Error())
+			return
+		}
+
+		err = s.parameterRepository.SaveValues(r.Context(), modelId, parameterId, domain.ValueModificationRequest{NewValues: []string{value}, Version: version})
+		if errors.Is(err, domain.ErrConflict) {
+			renderValueEditorWithEditConflict(v, w, r, s.parameterRepository, modelId, parameterId)
+			return
+		}
+		if err != nil {
+			slog.WarnContext(r.Context(), fmt.Sprintf("could not save value: %v", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		renderValueEditor(v, w, r, s.parameterRepository, modelId, parameterId, "")
+	}
+}
+
+func (s *Server) PutValue(v *views.View) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		modelId, _ := strconv.Atoi(r.PathValue("modelId"))
+		parameterId, _ := strconv.Atoi(r.PathValue("parameterId"))
+		valueId, _ := strconv.Atoi(r.PathValue("valueId"))
+		slog.InfoContext(r.Context(), fmt.Sprintf("updating value - modelId: %v, parameterId: %v, valueId: %v", modelId, parameterId, valueId))
+
+		version, err := expectedVersion(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		parameter, err := s.parameterRepository.FindById(r.Context(), modelId, parameterId)
+		if err != nil {
+			slog.WarnContext(r.Context(), fmt.Sprintf("could not find parameter with id %v: %v", parameterId, err.Error()))
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		value, err := valueFromForm(r, parameter.ValueType)
+		if err != nil {
+			renderValueEditor(v, w, r, s.parameterRepository, modelId, parameterId, err.Error())
+			return
+		}
+
+		// the If-Match header carries the version of the value, edits of other values do not conflict
+		updatedValue := domain.Value{Id: valueId, Value: value, Translation: r.FormValue("translation"), Version: version}
+		vmr := domain.ValueModificationRequest{UpdatedValues: []domain.Value{updatedValue}}
+		err = s.parameterRepository.SaveValues(r.Context(), modelId, parameterId, vmr)
+		if errors.Is(err, domain.ErrConflict) {
+			slog.InfoContext(r.Context(), fmt.Sprintf("value with id %v was modified concurrently", valueId))
+			conflict := editConflict(r)
+			conflict.ValueId = valueId
+			renderValueEditorContext(v, w, r, s.parameterRepository, modelId, parameterId, ValueEditorRenderContext{Conflict: conflict, etagValueId: valueId})
+			return
+		}
+		if errors.Is(err, domain.ErrNotFound) {
+			slog.InfoContext(r.Context(), fmt.Sprintf("value with id %v does not belong to parameter with id %v", valueId, parameterId))
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.WarnContext(r.Context(), fmt.Sprintf("could not save value: %v", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		renderValueEditorContext(v, w, r, s.parameterRepository, modelId, parameterId, ValueEditorRenderContext{etagValueId: valueId})
+	}
+}
+
+func (s *Server) DeleteValue(v *views.View) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		modelId, _ := strconv.Atoi(r.PathValue("modelId"))
+		parameterId, _ := strconv.Atoi(r.PathValue("parameterId"))
+		valueId, _ := strconv.Atoi(r.PathValue("valueId"))
+		cascade := r.URL.Query().Get("cascade") == "true"
+		slog.InfoContext(r.Context(), fmt.Sprintf("deleting value - modelId: %v, parameterId: %v, valueId: %v, cascade: %v", modelId, parameterId, valueId, cascade))
+
+		version, err := expectedVersion(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		vmr := domain.ValueModificationRequest{DeletedValues: []int{valueId}, Cascade: cascade, Version: version}
+		err = s.parameterRepository.SaveValues(r.Context(), modelId, parameterId, vmr)
+		if errors.Is(err, domain.ErrValueInUse) {
+			renderValueEditorWithConflict(v, w, r, s.parameterRepository, modelId, parameterId, valueId)
+			return
+		}
+		if errors.Is(err, domain.ErrConflict) {
+			renderValueEditorWithEditConflict(v, w, r, s.parameterRepository, modelId, parameterId)
+			return
+		}
+		if errors.Is(err, domain.ErrNotFound) {
+			slog.InfoContext(r.Context(), fmt.Sprintf("value with id %v of parameter with id %v does not belong to model with id %v", valueId, parameterId, modelId))
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.WarnContext(r.Context(), fmt.Sprintf("could not delete value: %v", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		renderValueEditor(v, w, r, s.parameterRepository, modelId, parameterId, "")
+	}
+}
+
+func (s *Server) MoveValue(v *views.View) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		modelId, _ := strconv.Atoi(r.PathValue("modelId"))
+		parameterId, _ := strconv.Atoi(r.PathValue("parameterId"))
+		valueId, _ := strconv.Atoi(r.PathValue("valueId"))
+		direction := r.FormValue("direction")
+		slog.InfoContext(r.Context(), fmt.Sprintf("moving value - modelId: %v, parameterId: %v, valueId: %v, direction: %v", modelId, parameterId, valueId, direction))
+
+		version, err := expectedVersion(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// the order is computed from the current values, so reading and writing it must not interleave with other changes
+		err = s.unitOfWork.Do(r.Context(), func(repositories domain.Repositories) error {
+			parameter, err := repositories.Parameters.FindById(r.Context(), modelId, parameterId)
+			if err != nil {
+				return err
+			}
+
+			order := moveValue(parameter.Value.Values, valueId, direction)
+			return repositories.Parameters.SaveValues(r.Context(), modelId, parameterId, domain.ValueModificationRequest{ValueOrder: order, Version: version})
+		})
+		if errors.Is(err, domain.ErrConflict) {
+			renderValueEditorWithEditConflict(v, w, r, s.parameterRepository, modelId, parameterId)
+			return
+		}
+		if errors.Is(err, domain.ErrNotFound) {
+			slog.WarnContext(r.Context(), fmt.Sprintf("could not find parameter with id %v: %v", parameterId, err.Error()))
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.WarnContext(r.Context(), fmt.Sprintf("could not save value order: %v", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		renderValueEditor(v, w, r, s.parameterRepository, modelId, parameterId, "")
+	}
+}
+
+func moveValue(values []domain.Value, valueId int, direction string) []int {
+	order := make([]int, len(values))
+	index := -1
+	for i, value := range values {
+		order[i] = value.Id
+		if value.Id == valueId {
+			index = i
+		}
+	}
+
+	switch {
+	case index < 0:
+	case direction == "up" && index > 0:
+		order[index-1], order[index] = order[index], order[index-1]
+	case direction == "down" && index < len(order)-1:
+		order[index], order[index+1] = order[index+1], order[index]
+	}
+	return order
+}
+
+func valueFromForm(r *http.Request, valueType configurationmodel.ValueType) (string, error) {
+	switch valueType {
+	case configurationmodel.IntRangeType:
+		min, err := strconv.Atoi(r.FormValue("min"))
+		if err != nil {
+			return "", errors.New("Das Minimum muss eine ganze Zahl sein")
+		}
+		max, err := strconv.Atoi(r.FormValue("max"))
+		if err != nil {
+			return "", errors.New("Das Maximum muss eine ganze Zahl sein")
+		}
+		if min > max {
+			return "", errors.New("Das Minimum darf nicht größer als das Maximum sein")
+		}
+		return fmt.Sprintf("%v..%v", min, max), nil
+	case configurationmodel.IntSetType, configurationmodel.FinalInt:
+		value, err := strconv.Atoi(r.FormValue("value"))
+		if err != nil {
+			return "", errors.New("Der Wert muss eine ganze Zahl sein")
+		}
+		return strconv.Itoa(value), nil
+	default:
+		value := r.FormValue("value")
+		if value == "" {
+			return "", errors.New("Der Wert darf nicht leer sein")
+		}
+		return value, nil
+	}
+}
+
+func renderValueEditor(v *views.View, w http.ResponseWriter, r *http.Request, paramRepo domain.ParameterRepository, modelId, parameterId int, errorMessage string) {
+	renderValueEditorContext(v, w, r, paramRepo, modelId, parameterId, ValueEditorRenderContext{Error: errorMessage})
+}
+
+func renderValueEditorWithConflict(v *views.View, w http.ResponseWriter, r *http.Request, paramRepo domain.ParameterRepository, modelId, parameterId, valueId int) {
+	renderValueEditorContext(v, w, r, paramRepo, modelId, parameterId, ValueEditorRenderContext{
+		Error:              "Der Wert wird von mindestens einer Regel verwendet",
+		ConflictingValueId: valueId,
+	})
+}
+
+// renderValueEditorWithEditConflict shows the current parameter together with the rejected request.
+func renderValueEditorWithEditConflict(v *views.View, w http.ResponseWriter, r *http.Request, paramRepo domain.ParameterRepository, modelId, parameterId int) {
+	slog.InfoContext(r.Context(), fmt.Sprintf("parameter with id %v was modified concurrently", parameterId))
+	renderValueEditorContext(v, w, r, paramRepo, modelId, parameterId, ValueEditorRenderContext{Conflict: editConflict(r)})
+}
+
+func editConflict(r *http.Request) *EditConflict {
+	fields := make([]FormField, 0, len(r.PostForm))
+	names := make([]string, 0, len(r.PostForm))
+	for name := range r.PostForm {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range r.PostForm[name] {
+			fields = append(fields, FormField{Name: name, Value: value})
+		}
+	}
+
+	return &EditConflict{Method: r.Method, Url: r.URL.RequestURI(), Fields: fields}
+}
+
+func renderValueEditorContext(v *views.View, w http.ResponseWriter, r *http.Request, paramRepo domain.ParameterRepository, modelId, parameterId int, editor ValueEditorRenderContext) {
+	parameter, err := paramRepo.FindById(r.Context(), modelId, parameterId)
+	if err != nil {
+		slog.WarnContext(r.Context(), fmt.Sprintf("could not find parameter with id %v: %v", parameterId, err.Error()))
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("ETag", etag(parameter.Version))
+
+	valuesToRender := make([]RenderValue, len(parameter.Value.Values))
+	for i, value := range parameter.Value.Values {
+		min, max, _ := strings.Cut(value.Value, "..")
+		valuesToRender[i] = RenderValue{Id: value.Id, Value: value.Value, Translation: value.Translation, Min: min, Max: max, Version: value.Version}
+		if editor.Conflict != nil && editor.Conflict.ValueId == value.Id {
+			editor.Conflict.ValueVersion = value.Version
+		}
+		if editor.etagValueId == value.Id {
+			w.Header().Set("ETag", etag(value.Version))
+		}
+	}
+
+	editor.ModelId = modelId
+	editor.ParameterId = parameterId
+	editor.ValueType = parameter.ValueType
+	editor.ValueTypeName = valueTypeName(parameter.ValueType)
+	editor.InputType = inputType(parameter.ValueType)
+	editor.IsRange = parameter.ValueType == configurationmodel.IntRangeType
+	editor.CanAdd = parameter.ValueType != configurationmodel.FinalInt || len(valuesToRender) == 0
+	editor.Description = parameter.Description
+	editor.Unit = parameter.Unit
+	editor.HasUnit = parameter.ValueType != configurationmodel.StringSetType
+	editor.Required = parameter.Required
+	editor.DefaultValue = parameter.DefaultValue
+	editor.Values = valuesToRender
+	editor.Version = parameter.Version
+
+	if editor.Conflict != nil || editor.ConflictingValueId != 0 {
+		w.WriteHeader(http.StatusConflict)
+	}
+	v.Render(r.Context(), w, editor)
+}
+
+func valueTypeName(valueType configurationmodel.ValueType) string {
+	switch valueType {
+	case configurationmodel.IntSetType:
+		return "Liste von Zahlen"
+	case configurationmodel.IntRangeType:
+		return "Zahlenbereich"
+	case configurationmodel.FinalInt:
+		return "Fester Zahlenwert"
+	case configurationmodel.StringSetType:
+		return "Liste von Texten"
+	default:
+		return "Unbekannt"
+	}
+}
+
+func inputType(valueType configurationmodel.ValueType) string {
+	if valueType == configurationmodel.StringSetType {
+		return "text"
+	}
+	return "number"
+}
@@ -0,0 +1,97 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	configurationmodel "github.com/gossie/configuration-model"
+	"github.com/gossie/modelling-service/domain"
+	"github.com/gossie/modelling-service/middleware"
+)
+
+func TestDeletingAValueInUseIsAConflict(t *testing.T) {
+	s, modelId := newAuthorizationTestServer(t)
+	ctx := context.WithValue(context.Background(), middleware.LanguageKey, "en")
+
+	parameterId, err := s.parameterRepository.SaveParameter(ctx, modelId, domain.ParameterCreationRequest{Name: "color"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.parameterRepository.SaveValues(ctx, modelId, parameterId, domain.ValueModificationRequest{NewValues: []string{"red", "blue"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parameter, err := s.parameterRepository.FindById(ctx, modelId, parameterId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	red, blue := parameter.Value.Values[0].Id, parameter.Value.Values[1].Id
+	_, err = s.constraintRepository.SaveConstraint(ctx, strconv.Itoa(modelId), domain.ConstraintCreationRequest{FromId: parameterId, FromValueId: red, TargetId: parameterId, TargetValueId: red})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deleteValue := func(valueId int) int {
+		request := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/models/%v/parameters/%v/values/%v", modelId, parameterId, valueId), nil)
+		authenticateAs(t, s, request, modelOwner)
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+	if status := deleteValue(red); status != http.StatusConflict {
+		t.Errorf("expected 409 for a value in use, got %v", status)
+	}
+	if status := deleteValue(blue); status != http.StatusOK {
+		t.Errorf("expected 200 for an unused value, got %v", status)
+	}
+}
+
+func TestValuesAreEditedWithTheirOwnVersion(t *testing.T) {
+	s, modelId := newAuthorizationTestServer(t)
+	ctx := context.WithValue(context.Background(), middleware.LanguageKey, "en")
+
+	parameterId, err := s.parameterRepository.SaveParameter(ctx, modelId, domain.ParameterCreationRequest{Name: "color", ValueType: configurationmodel.StringSetType})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.parameterRepository.SaveValues(ctx, modelId, parameterId, domain.ValueModificationRequest{NewValues: []string{"red", "blue"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parameter, err := s.parameterRepository.FindById(ctx, modelId, parameterId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	red, blue := parameter.Value.Values[0], parameter.Value.Values[1]
+
+	putValue := func(value domain.Value, newValue string) *httptest.ResponseRecorder {
+		form := url.Values{"value": {newValue}}
+		request := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/models/%v/parameters/%v/values/%v", modelId, parameterId, value.Id), strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.Header.Set("If-Match", etag(value.Version))
+		authenticateAs(t, s, request, modelOwner)
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	response := putValue(red, "dark red")
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected 200 for the first edit, got %v", response.Code)
+	}
+	if response.Header().Get("ETag") == etag(red.Version) {
+		t.Errorf("expected the ETag to carry the new version of the value, got %v", response.Header().Get("ETag"))
+	}
+	if response := putValue(blue, "dark blue"); response.Code != http.StatusOK {
+		t.Errorf("expected 200 for the edit of another value, got %v", response.Code)
+	}
+	if response := putValue(red, "light red"); response.Code != http.StatusConflict {
+		t.Errorf("expected 409 for an outdated version of the value, got %v", response.Code)
+	}
+}
@@ -0,0 +1,192 @@
+package rest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gossie/modelling-service/domain"
+	"github.com/gossie/modelling-service/events"
+	"github.com/gossie/modelling-service/views"
+)
+
+// deliveryLogSize is the number of deliveries shown in the delivery log.
+const deliveryLogSize = 50
+
+func (s *Server) GetWebhooks(v *views.View) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		modelId, _ := strconv.Atoi(r.PathValue("modelId"))
+		slog.InfoContext(r.Context(), fmt.Sprintf("retrieving webhooks of model with ID %v", modelId))
+
+		s.renderWebhooks(v, w, r, modelId, "")
+	}
+}
+
+func (s *Server) PostWebhook(v *views.View) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		modelId, _ := strconv.Atoi(r.PathValue("modelId"))
+		slog.InfoContext(r.Context(), fmt.Sprintf("creating webhook for model with ID %v", modelId))
+
+		receiver, err := url.Parse(r.FormValue("url"))
+		if err != nil || (receiver.Scheme != "http" && receiver.Scheme != "https") || receiver.Host == "" {
+			s.renderWebhooks(v, w, r, modelId, "Die URL muss mit http:// oder https:// beginnen")
+			return
+		}
+		if err = s.webhookTargets.Check(r.Context(), receiver); err != nil {
+			slog.InfoContext(r.Context(), fmt.Sprintf("refusing webhook target %v: %v", receiver.Host, err.Error()))
+			s.renderWebhooks(v, w, r, modelId, "Webhooks können nur an öffentliche Adressen gesendet werden")
+			return
+		}
+
+		secret := r.FormValue("secret")
+		if secret == "" {
+			secret, err = generateSecret()
+			if err != nil {
+				slog.WarnContext(r.Context(), fmt.Sprintf("could not generate webhook secret: %v", err.Error()))
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+
+		_, err = s.webhookRepository.SaveWebhook(r.Context(), modelId, domain.WebhookCreationRequest{Url: receiver.String(), Secret: secret})
+		if err != nil {
+			slog.WarnContext(r.Context(), fmt.Sprintf("error creating webhook: %v", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		s.renderWebhooks(v, w, r, modelId, "")
+	}
+}
+
+func (s *Server) DeleteWebhook(v *views.View) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		modelId, _ := strconv.Atoi(r.PathValue("modelId"))
+		webhookId, _ := strconv.Atoi(r.PathValue("webhookId"))
+		slog.InfoContext(r.Context(), fmt.Sprintf("deleting webhook - modelId: %v, webhookId: %v", modelId, webhookId))
+
+		err := s.webhookRepository.DeleteWebhook(r.Context(), modelId, webhookId)
+		if err != nil {
+			slog.WarnContext(r.Context(), fmt.Sprintf("error deleting webhook - modelId = %v, webhookId = %v: %v", modelId, webhookId, err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		s.renderWebhooks(v, w, r, modelId, "")
+	}
+}
+
+func (s *Server) RetryDelivery(v *views.View) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		modelId, _ := strconv.Atoi(r.PathValue("modelId"))
+		deliveryId, _ := strconv.Atoi(r.PathValue("deliveryId"))
+		slog.InfoContext(r.Context(), fmt.Sprintf("retrying webhook delivery - modelId: %v, deliveryId: %v", modelId, deliveryId))
+
+		err := s.webhookRepository.RetryDelivery(r.Context(), modelId, deliveryId)
+		if errors.Is(err, domain.ErrNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.WarnContext(r.Context(), fmt.Sprintf("error retrying delivery with ID %v: %v", deliveryId, err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		s.renderWebhooks(v, w, r, modelId, "")
+	}
+}
+
+// PublishModel tells the webhooks of the model that a new state of the model is ready for use.
+func (s *Server) PublishModel(w http.ResponseWriter, r *http.Request) {
+	modelId, _ := strconv.Atoi(r.PathValue("modelId"))
+	slog.InfoContext(r.Context(), fmt.Sprintf("publishing model with ID %v", modelId))
+
+	err := events.Append(r.Context(), s.outboxRepository, modelId, events.ModelPublished)
+	if err != nil {
+		slog.WarnContext(r.Context(), fmt.Sprintf("could not publish model with ID %v: %v", modelId, err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) renderWebhooks(v *views.View, w http.ResponseWriter, r *http.Request, modelId int, errorMessage string) {
+	model, err := retrieveData(nil, func() (domain.Model, error) {
+		return s.modelRepository.FindById(r.Context(), modelId)
+	})
+
+	webhooks, err := retrieveData(err, func() ([]domain.Webhook, error) {
+		return s.webhookRepository.FindAllByModelId(r.Context(), modelId)
+	})
+
+	deliveries, err := retrieveData(err, func() ([]domain.WebhookDelivery, error) {
+		return s.webhookRepository.FindDeliveriesByModelId(r.Context(), modelId, deliveryLogSize)
+	})
+
+	if err != nil {
+		slog.WarnContext(r.Context(), fmt.Sprintf("could not retrieve webhooks of model with ID %v: %v", modelId, err.Error()))
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	webhooksToRender := make([]RenderWebhook, len(webhooks))
+	for i, webhook := range webhooks {
+		webhooksToRender[i] = RenderWebhook{Id: webhook.Id, Url: webhook.Url, Secret: webhook.Secret}
+	}
+
+	deliveriesToRender := make([]RenderDelivery, len(deliveries))
+	for i, delivery := range deliveries {
+		deliveriesToRender[i] = RenderDelivery{
+			Id:             delivery.Id,
+			Url:            delivery.Url,
+			Event:          delivery.Event,
+			Status:         deliveryStatusName(delivery.Status),
+			Dead:           delivery.Status == domain.DeliveryDead,
+			Attempts:       delivery.Attempts,
+			ResponseStatus: delivery.ResponseStatus,
+			LastError:      delivery.LastError,
+			CreatedAt:      formatTime(delivery.CreatedAt),
+		}
+		if delivery.Status == domain.DeliveryPending && delivery.Attempts > 0 {
+			deliveriesToRender[i].NextAttemptAt = formatTime(delivery.NextAttemptAt)
+		}
+	}
+
+	v.Render(r.Context(), w, WebhooksRenderContext{
+		Model:      RenderModel{Id: model.Id, Name: valueOrDefault(model.Translation, model.Name)},
+		Webhooks:   webhooksToRender,
+		Deliveries: deliveriesToRender,
+		Error:      errorMessage,
+	})
+}
+
+func deliveryStatusName(status domain.DeliveryStatus) string {
+	switch status {
+	case domain.DeliveryDelivered:
+		return "Zugestellt"
+	case domain.DeliveryDead:
+		return "Fehlgeschlagen"
+	default:
+		return "Ausstehend"
+	}
+}
+
+func formatTime(t time.Time) string {
+	return t.Local().Format("02.01.2006 15:04:05")
+}
+
+func generateSecret() (string, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
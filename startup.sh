@@ -1 +1 @@
-export JWT_SECRET=jwt-secret && export DB_PASSWORD=db-secret && go run ./cmd/web
+export JWT_SECRET=jwt-secret && export SECURE_COOKIES=false && export DB_PASSWORD=db-secret && go run ./cmd/web
@@ -5,10 +5,13 @@ import (
 	"embed"
 	"fmt"
 	"html/template"
+	"io"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/gossie/modelling-service/views/components"
+	"go.opentelemetry.io/otel"
 )
 
 //go:embed layouts/*
@@ -39,6 +42,14 @@ var tmpl = template.Must(template.New("").Funcs(template.FuncMap{
 	},
 }).ParseFS(htmlTemplates, "layouts/*.html"))
 
+// observeRendering is called with the duration of every rendered view.
+var observeRendering = func(layout string, d time.Duration) {}
+
+// ObserveRendering registers a function that gets the duration of every rendered view, for example to record metrics.
+func ObserveRendering(observe func(layout string, d time.Duration)) {
+	observeRendering = observe
+}
+
 func NewView(layout string) *View {
 	return &View{
 		layout: layout,
@@ -50,13 +61,24 @@ type View struct {
 }
 
 func (v *View) Render(ctx context.Context, w http.ResponseWriter, data any) {
-	err := tmpl.ExecuteTemplate(w, v.layout, data)
+	ctx, span := otel.Tracer("github.com/gossie/modelling-service/views").Start(ctx, "render "+v.layout)
+	defer span.End()
+
+	err := v.Execute(w, data)
 	if err != nil {
 		slog.WarnContext(ctx, fmt.Sprintf("could not render template %v", v.Layout()), "err", err.Error())
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
+// Execute renders the view into w. Unlike Render, it leaves handling errors to the caller.
+func (v *View) Execute(w io.Writer, data any) error {
+	start := time.Now()
+	defer func() { observeRendering(v.layout, time.Since(start)) }()
+
+	return tmpl.ExecuteTemplate(w, v.layout, data)
+}
+
 func (v *View) Layout() string {
 	return v.layout
 }
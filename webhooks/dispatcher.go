@@ -0,0 +1,230 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gossie/modelling-service/domain"
+	"github.com/gossie/modelling-service/events"
+)
+
+const (
+	ModelChanged   = "model.changed"
+	ModelPublished = "model.published"
+)
+
+// The headers of a delivery. The signature is the hex encoded HMAC-SHA256 of "<timestamp>.<body>" with the webhook's secret,
+// prefixed with "sha256=". Receivers should reject deliveries with an old timestamp to prevent replays. An event can
+// arrive more than once, the idempotency key is the same for all of its deliveries.
+const (
+	EventHeader          = "X-Webhook-Event"
+	DeliveryHeader       = "X-Webhook-Delivery"
+	IdempotencyKeyHeader = "X-Webhook-Idempotency-Key"
+	TimestampHeader      = "X-Webhook-Timestamp"
+	SignatureHeader      = "X-Webhook-Signature"
+)
+
+// Payload is the JSON body of a delivery.
+type Payload struct {
+	Id      string `json:"id"`
+	Event   string `json:"event"`
+	ModelId int    `json:"modelId"`
+	// Change names the part of the model that changed, it is empty for other events
+	Change     string    `json:"change,omitempty"`
+	OccurredAt time.Time `json:"occurredAt"`
+}
+
+// Dispatcher delivers the events of models to their webhooks. Deliveries are stored first and sent in the background,
+// failed attempts are repeated with exponential backoff until MaxAttempts is reached. Then the delivery is dead. Like the
+// relay, only one dispatcher may run per database, otherwise deliveries are sent twice.
+type Dispatcher struct {
+	repository domain.WebhookRepository
+	client     *http.Client
+	wake       chan struct{}
+	now        func() time.Time
+
+	MaxAttempts  int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	PollInterval time.Duration
+	BatchSize    int
+}
+
+func NewDispatcher(repository domain.WebhookRepository, client *http.Client) *Dispatcher {
+	return &Dispatcher{
+		repository:   repository,
+		client:       client,
+		wake:         make(chan struct{}, 1),
+		now:          time.Now,
+		MaxAttempts:  8,
+		BaseDelay:    10 * time.Second,
+		MaxDelay:     time.Hour,
+		PollInterval: 5 * time.Second,
+		BatchSize:    50,
+	}
+}
+
+// Enqueue stores deliveries of the event for all webhooks of its model, it is meant to be an events.Handler of the relay.
+// Enqueueing an event with the same key again does not add deliveries.
+func (d *Dispatcher) Enqueue(ctx context.Context, event events.Event) error {
+	payload := Payload{Id: event.Key, ModelId: event.ModelId, OccurredAt: event.OccurredAt.UTC()}
+	if event.OccurredAt.IsZero() {
+		payload.OccurredAt = d.now().UTC()
+	}
+	switch event.Kind {
+	case events.ParametersChanged, events.ConstraintsChanged:
+		payload.Event = ModelChanged
+		payload.Change = string(event.Kind)
+	case events.ModelPublished:
+		payload.Event = ModelPublished
+	default:
+		return nil
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	err = d.repository.EnqueueDeliveries(ctx, event.ModelId, event.Key, payload.Event, string(body))
+	if err != nil {
+		return fmt.Errorf("could not enqueue %v deliveries of model with ID %v: %w", payload.Event, event.ModelId, err)
+	}
+
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Run delivers due deliveries until ctx is done. It checks for due deliveries every PollInterval and right after an Enqueue.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		err := d.DeliverDue(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.WarnContext(ctx, fmt.Sprintf("could not deliver webhooks: %v", err.Error()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// DeliverDue makes one attempt for every delivery that is due.
+func (d *Dispatcher) DeliverDue(ctx context.Context) error {
+	for {
+		due, err := d.repository.FindDueDeliveries(ctx, d.now(), d.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, dueDelivery := range due {
+			err = d.repository.UpdateDelivery(ctx, d.attempt(ctx, dueDelivery))
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(due) < d.BatchSize {
+			return nil
+		}
+	}
+}
+
+// attempt sends the delivery and returns it with the outcome.
+func (d *Dispatcher) attempt(ctx context.Context, dueDelivery domain.DueDelivery) domain.WebhookDelivery {
+	delivery := dueDelivery.Delivery
+	delivery.Attempts++
+
+	status, err := d.send(ctx, dueDelivery.Webhook, delivery)
+	delivery.ResponseStatus = status
+	if err == nil {
+		slog.InfoContext(ctx, fmt.Sprintf("delivered %v to webhook with ID %v", delivery.Event, delivery.WebhookId))
+		delivery.Status = domain.DeliveryDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = d.now()
+		return delivery
+	}
+
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= d.MaxAttempts {
+		slog.WarnContext(ctx, fmt.Sprintf("giving up delivery with ID %v after %v attempts: %v", delivery.Id, delivery.Attempts, err.Error()))
+		delivery.Status = domain.DeliveryDead
+		return delivery
+	}
+
+	delivery.NextAttemptAt = d.now().Add(d.backoff(delivery.Attempts))
+	slog.InfoContext(ctx, fmt.Sprintf("delivery with ID %v failed, retrying at %v: %v", delivery.Id, delivery.NextAttemptAt, err.Error()))
+	return delivery
+}
+
+// backoff doubles the delay with every failed attempt.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.BaseDelay
+	for i := 1; i < attempts && delay < d.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, d.MaxDelay)
+}
+
+func (d *Dispatcher) send(ctx context.Context, webhook domain.Webhook, delivery domain.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(d.now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(EventHeader, delivery.Event)
+	request.Header.Set(DeliveryHeader, strconv.Itoa(delivery.Id))
+	if delivery.IdempotencyKey != "" {
+		request.Header.Set(IdempotencyKeyHeader, delivery.IdempotencyKey)
+	}
+	request.Header.Set(TimestampHeader, timestamp)
+	request.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, body))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024))
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("receiver answered with status %v", response.StatusCode)
+	}
+	return response.StatusCode, nil
+}
+
+// Sign computes the value of the signature header.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature of a delivery. Receivers written in Go can use it.
+func Verify(secret, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
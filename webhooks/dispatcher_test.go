@@ -0,0 +1,242 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gossie/modelling-service/domain"
+	"github.com/gossie/modelling-service/events"
+	"github.com/gossie/modelling-service/persistence"
+)
+
+const (
+	owner  = "owner@example.com"
+	secret = "top-secret"
+)
+
+// receiver records the requests it gets and answers with the configured status codes, the last one repeats.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []receivedRequest
+}
+
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.requests = append(rc.requests, receivedRequest{header: r.Header.Clone(), body: body})
+	status := rc.statuses[min(len(rc.requests), len(rc.statuses))-1]
+	w.WriteHeader(status)
+}
+
+func (rc *receiver) received() []receivedRequest {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]receivedRequest(nil), rc.requests...)
+}
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// setup creates a model with a webhook that points to a test server answering with the statuses.
+func setup(t *testing.T, statuses ...int) (*Dispatcher, *receiver, *clock, domain.WebhookRepository, int) {
+	t.Helper()
+	ctx := context.Background()
+
+	rc := &receiver{statuses: statuses}
+	server := httptest.NewServer(rc)
+	t.Cleanup(server.Close)
+
+	repositories := persistence.NewMemoryRepositories(owner)
+	modelId, err := repositories.Models.SaveModel(ctx, owner, domain.ModelCreationRequest{Name: "Bike"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repositories.Webhooks.SaveWebhook(ctx, modelId, domain.WebhookCreationRequest{Url: server.URL, Secret: secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the deliveries are enqueued with the real time, the dispatcher's clock starts slightly after it
+	c := &clock{now: time.Now().Add(time.Second)}
+	dispatcher := NewDispatcher(repositories.Webhooks, server.Client())
+	dispatcher.now = func() time.Time { return c.now }
+	return dispatcher, rc, c, repositories.Webhooks, modelId
+}
+
+func enqueue(t *testing.T, dispatcher *Dispatcher, event events.Event) {
+	t.Helper()
+	err := dispatcher.Enqueue(context.Background(), event)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func deliveries(t *testing.T, repository domain.WebhookRepository, modelId int) []domain.WebhookDelivery {
+	t.Helper()
+	found, err := repository.FindDeliveriesByModelId(context.Background(), modelId, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return found
+}
+
+func TestDeliveryIsSigned(t *testing.T) {
+	dispatcher, rc, _, repository, modelId := setup(t, http.StatusNoContent)
+
+	enqueue(t, dispatcher, events.Event{ModelId: modelId, Kind: events.ParametersChanged, Key: "event-1"})
+	err := dispatcher.DeliverDue(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := rc.received()
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 request, got %v", len(requests))
+	}
+
+	request := requests[0]
+	if !Verify(secret, request.header.Get(TimestampHeader), request.body, request.header.Get(SignatureHeader)) {
+		t.Errorf("signature %v does not match the body", request.header.Get(SignatureHeader))
+	}
+	if Verify("wrong-secret", request.header.Get(TimestampHeader), request.body, request.header.Get(SignatureHeader)) {
+		t.Error("signature matches a different secret")
+	}
+	if request.header.Get(EventHeader) != ModelChanged {
+		t.Errorf("expected event header %v, got %v", ModelChanged, request.header.Get(EventHeader))
+	}
+	if request.header.Get(IdempotencyKeyHeader) != "event-1" {
+		t.Errorf("expected the key of the event as idempotency key, got %v", request.header.Get(IdempotencyKeyHeader))
+	}
+
+	var payload Payload
+	err = json.Unmarshal(request.body, &payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.Id != "event-1" || payload.Event != ModelChanged || payload.ModelId != modelId || payload.Change != string(events.ParametersChanged) {
+		t.Errorf("unexpected payload %+v", payload)
+	}
+
+	logged := deliveries(t, repository, modelId)
+	if len(logged) != 1 || logged[0].Status != domain.DeliveryDelivered || logged[0].ResponseStatus != http.StatusNoContent {
+		t.Errorf("expected a delivered delivery, got %+v", logged)
+	}
+}
+
+func TestFailedDeliveryIsRetriedWithBackoff(t *testing.T) {
+	dispatcher, rc, c, repository, modelId := setup(t, http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK)
+	dispatcher.BaseDelay = time.Minute
+	ctx := context.Background()
+
+	enqueue(t, dispatcher, events.Event{ModelId: modelId, Kind: events.ModelPublished, Key: "event-1"})
+	err := dispatcher.DeliverDue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logged := deliveries(t, repository, modelId)[0]
+	if logged.Status != domain.DeliveryPending || logged.Attempts != 1 || logged.ResponseStatus != http.StatusInternalServerError {
+		t.Fatalf("expected a pending delivery after the first attempt, got %+v", logged)
+	}
+	if !logged.NextAttemptAt.Equal(c.now.Add(time.Minute)) {
+		t.Errorf("expected the next attempt at %v, got %v", c.now.Add(time.Minute), logged.NextAttemptAt)
+	}
+
+	// not due yet
+	c.advance(30 * time.Second)
+	_ = dispatcher.DeliverDue(ctx)
+	if len(rc.received()) != 1 {
+		t.Fatalf("expected no attempt before the backoff elapsed, got %v requests", len(rc.received()))
+	}
+
+	c.advance(30 * time.Second)
+	_ = dispatcher.DeliverDue(ctx)
+	logged = deliveries(t, repository, modelId)[0]
+	if logged.Attempts != 2 || !logged.NextAttemptAt.Equal(c.now.Add(2*time.Minute)) {
+		t.Fatalf("expected the delay to double after the second attempt, got %+v", logged)
+	}
+
+	c.advance(2 * time.Minute)
+	_ = dispatcher.DeliverDue(ctx)
+	logged = deliveries(t, repository, modelId)[0]
+	if logged.Status != domain.DeliveryDelivered || logged.Attempts != 3 || logged.LastError != "" {
+		t.Errorf("expected the third attempt to succeed, got %+v", logged)
+	}
+	if len(rc.received()) != 3 {
+		t.Errorf("expected 3 requests, got %v", len(rc.received()))
+	}
+}
+
+func TestDeliveryIsDeadAfterMaxAttempts(t *testing.T) {
+	dispatcher, rc, c, repository, modelId := setup(t, http.StatusServiceUnavailable)
+	dispatcher.MaxAttempts = 3
+	ctx := context.Background()
+
+	enqueue(t, dispatcher, events.Event{ModelId: modelId, Kind: events.ConstraintsChanged, Key: "event-1"})
+	for range 5 {
+		_ = dispatcher.DeliverDue(ctx)
+		c.advance(dispatcher.MaxDelay)
+	}
+
+	logged := deliveries(t, repository, modelId)[0]
+	if logged.Status != domain.DeliveryDead || logged.Attempts != 3 {
+		t.Fatalf("expected a dead delivery after 3 attempts, got %+v", logged)
+	}
+	if len(rc.received()) != 3 {
+		t.Errorf("expected 3 requests, got %v", len(rc.received()))
+	}
+
+	err := repository.RetryDelivery(ctx, modelId, logged.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = dispatcher.DeliverDue(ctx)
+	if len(rc.received()) != 4 {
+		t.Errorf("expected the retried dead letter to be sent again, got %v requests", len(rc.received()))
+	}
+}
+
+func TestPresenceIsNotDelivered(t *testing.T) {
+	dispatcher, rc, _, repository, modelId := setup(t, http.StatusOK)
+
+	enqueue(t, dispatcher, events.Event{ModelId: modelId, Kind: events.PresenceChanged, Key: "event-1"})
+	_ = dispatcher.DeliverDue(context.Background())
+
+	if len(deliveries(t, repository, modelId)) != 0 || len(rc.received()) != 0 {
+		t.Error("expected presence changes not to reach webhooks")
+	}
+}
+
+func TestRepeatedEventIsDeliveredOnce(t *testing.T) {
+	dispatcher, rc, _, repository, modelId := setup(t, http.StatusOK)
+
+	event := events.Event{ModelId: modelId, Kind: events.ModelPublished, Key: "published-1", OccurredAt: time.Now()}
+	enqueue(t, dispatcher, event)
+	_ = dispatcher.DeliverDue(context.Background())
+	enqueue(t, dispatcher, event)
+	_ = dispatcher.DeliverDue(context.Background())
+
+	if len(deliveries(t, repository, modelId)) != 1 || len(rc.received()) != 1 {
+		t.Errorf("expected the repeated event to be delivered once, got %v requests", len(rc.received()))
+	}
+}
@@ -0,0 +1,108 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrInternalTarget is returned for webhook targets in internal networks that are not allowed explicitly.
+var ErrInternalTarget = errors.New("the target is not a public address")
+
+// internalPrefixes are internal networks that the methods of netip.Addr do not cover.
+var internalPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+}
+
+// Targets decides which hosts may receive webhooks. Hosts on the loopback interface, in private networks or at
+// link-local addresses like the metadata service of a cloud are refused unless they are allowed explicitly, so that
+// webhooks cannot reach the services behind the firewall.
+type Targets struct {
+	allowedHosts []string
+}
+
+func NewTargets(allowedHosts []string) Targets {
+	hosts := make([]string, 0, len(allowedHosts))
+	for _, host := range allowedHosts {
+		hosts = append(hosts, strings.ToLower(host))
+	}
+	return Targets{hosts}
+}
+
+// Check refuses a URL whose host is or resolves to an internal address. A host that cannot be resolved is accepted,
+// the client checks the address again when it connects, because the host may resolve differently by then.
+func (t Targets) Check(ctx context.Context, target *url.URL) error {
+	host := target.Hostname()
+	if t.allowed(host) {
+		return nil
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return checkAddr(addr)
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if err = checkAddr(addr); err != nil {
+			return fmt.Errorf("%v: %w", host, err)
+		}
+	}
+	return nil
+}
+
+// Client returns the HTTP client for the deliveries. It does not connect to internal addresses of hosts that are not
+// allowed, which also covers redirects. Proxies are not used, they would connect on behalf of the client.
+func (t Targets) Client(timeout time.Duration) *http.Client {
+	guarded := &net.Dialer{Timeout: 30 * time.Second, Control: refuseInternal}
+	open := &net.Dialer{Timeout: 30 * time.Second}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, _, _ := net.SplitHostPort(address)
+		if t.allowed(host) {
+			return open.DialContext(ctx, network, address)
+		}
+		return guarded.DialContext(ctx, network, address)
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+func (t Targets) allowed(host string) bool {
+	return slices.Contains(t.allowedHosts, strings.ToLower(host))
+}
+
+// refuseInternal checks the resolved address right before it is dialed.
+func refuseInternal(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	return checkAddr(addrPort.Addr())
+}
+
+func checkAddr(addr netip.Addr) error {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() || addr.IsLinkLocalUnicast() || addr.IsMulticast() {
+		return fmt.Errorf("%w: %v", ErrInternalTarget, addr)
+	}
+	for _, prefix := range internalPrefixes {
+		if prefix.Contains(addr) {
+			return fmt.Errorf("%w: %v", ErrInternalTarget, addr)
+		}
+	}
+	return nil
+}
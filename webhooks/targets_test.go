@@ -0,0 +1,71 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestTargetsRefuseInternalAddresses(t *testing.T) {
+	targets := NewTargets([]string{"pim.internal", "192.168.1.20"})
+
+	tests := []struct {
+		url      string
+		internal bool
+	}{
+		{"http://127.0.0.1:8080/hook", true},
+		{"http://localhost/hook", true},
+		{"http://[::1]/hook", true},
+		{"http://[::ffff:127.0.0.1]/hook", true},
+		{"http://10.1.2.3/hook", true},
+		{"http://172.16.0.1/hook", true},
+		{"http://192.168.1.21/hook", true},
+		{"http://169.254.169.254/latest/meta-data", true},
+		{"http://[fd00::1]/hook", true},
+		{"http://0.0.0.0/hook", true},
+		{"http://100.64.0.1/hook", true},
+		{"https://93.184.216.34/hook", false},
+		{"https://[2606:2800:220:1::1]/hook", false},
+		{"http://192.168.1.20/hook", false},
+		{"http://PIM.internal/hook", false},
+	}
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			target, err := url.Parse(test.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = targets.Check(context.Background(), target)
+			if internal := errors.Is(err, ErrInternalTarget); internal != test.internal {
+				t.Errorf("expected the target to be internal: %v, got %v", test.internal, err)
+			}
+		})
+	}
+}
+
+func TestClientDoesNotConnectToInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	host := server.Listener.Addr().(*net.TCPAddr).IP.String()
+
+	_, err := NewTargets(nil).Client(time.Second).Get(server.URL)
+	if !errors.Is(err, ErrInternalTarget) {
+		t.Errorf("expected the connection to be refused, got %v", err)
+	}
+
+	response, err := NewTargets([]string{host}).Client(time.Second).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusNoContent {
+		t.Errorf("expected the allowed host to receive the request, got %v", response.StatusCode)
+	}
+}